@@ -4,8 +4,12 @@ import (
 	"github.com/injoyai/conv/cfg"
 	"github.com/injoyai/frame"
 	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/alert"
 	"github.com/injoyai/strategy/internal/api"
 	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/paper"
+	"github.com/injoyai/strategy/internal/quote"
+	"github.com/injoyai/strategy/internal/screener"
 	"github.com/injoyai/strategy/internal/strategy"
 )
 
@@ -20,13 +24,27 @@ func main() {
 	err := common.Init()
 	logs.PanicErr(err)
 
-	//自动更新数据
-	common.Data.Start()
-
-	//加载脚本
+	//加载脚本,定时选股可能用到脚本策略,需要在数据更新之前加载
 	err = strategy.Loading(scriptDir)
 	logs.PanicErr(err)
 
+	//定时选股,数据更新完成后执行
+	err = screener.StartSchedule()
+	logs.PanicErr(err)
+
+	//实时行情,盘中轮询订阅股票的快照行情
+	err = quote.Start()
+	logs.PanicErr(err)
+
+	//提醒规则,数据更新完成后和盘中行情变化后执行
+	alert.Start()
+
+	//模拟交易,数据更新完成后下单
+	paper.Start()
+
+	//自动更新数据
+	common.Data.Start()
+
 	//运行服务
 	err = api.Run(port)
 	logs.Err(err)
@@ -5,10 +5,10 @@ import (
 )
 
 var (
-	LimitUpThreshold    = 0.098 // 涨停阈值（默认0.098，即9.8%）
-	RecentDaysToCheck   = 20    // 检查最近多少个交易日（默认20天）
-	ConsecutiveBullDays = 2     // 跳空后要求的连续阳线天数（含跳空当天，默认2天）
-	VolumeAvgDays       = 5     // 成交量均线计算天数（默认5天）
+	LimitUpThreshold    = 0.098 // 涨停阈值（默认0.098，即9.8%） [0.01,0.3]
+	RecentDaysToCheck   = 20    // 检查最近多少个交易日（默认20天） [2,250]
+	ConsecutiveBullDays = 2     // 跳空后要求的连续阳线天数（含跳空当天，默认2天） [1,20]
+	VolumeAvgDays       = 5     // 成交量均线计算天数（默认5天） [1,60]
 )
 
 // Signal 选股策略，满足以下4个条件：
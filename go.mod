@@ -16,6 +16,7 @@ require (
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/traefik/yaegi v0.16.1
 	github.com/valyala/fasthttp v1.68.0
+	xorm.io/xorm v1.3.11
 )
 
 require (
@@ -96,5 +97,4 @@ require (
 	modernc.org/sqlite v1.28.0 // indirect
 	xorm.io/builder v0.3.13 // indirect
 	xorm.io/core v0.7.3 // indirect
-	xorm.io/xorm v1.3.11 // indirect
 )
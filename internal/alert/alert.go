@@ -0,0 +1,439 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/strategy/internal/notify"
+	"github.com/injoyai/strategy/internal/quote"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+// Source 提醒在推送日志中的来源
+const Source = "alert"
+
+const (
+	KindPriceAbove = "price_above" //收盘价(盘中为最新价)上穿Value
+	KindPriceBelow = "price_below" //收盘价(盘中为最新价)下穿Value
+	KindRSIBelow   = "rsi_below"   //RSI(Period)小于Value
+	KindRSIAbove   = "rsi_above"   //RSI(Period)大于Value
+	KindVolume     = "volume"      //成交量大于之前Period天平均成交量的Value倍
+	KindStrategy   = "strategy"    //策略发出买入或卖出信号
+)
+
+const (
+	TriggerUpdate   = "update"   //数据更新完成后
+	TriggerIntraday = "intraday" //盘中行情
+	TriggerManual   = "manual"   //手动执行
+)
+
+const (
+	DefaultRSIPeriod    = 14
+	DefaultVolumePeriod = 20
+	history             = 400 //计算指标读取的日线天数(自然日)
+)
+
+// Rule 单只股票的提醒规则,同一根K线最多提醒一次
+type Rule struct {
+	ID       int64           `xorm:"pk autoincr" json:"id"`
+	Code     string          `json:"code"` //股票代码,例sz000001
+	Kind     string          `json:"kind"` //price_above/price_below/rsi_below/rsi_above/volume/strategy
+	Value    float64         `json:"value"`
+	Period   int             `json:"period"`             //RSI和平均成交量的周期,默认14和20
+	Strategy string          `json:"strategy"`           //策略名称,kind为strategy时有效
+	Params   strategy.Params `xorm:"json" json:"params"` //策略参数覆盖
+	Intraday bool            `json:"intraday"`           //是否在盘中行情时也执行,默认只在数据更新完成后执行
+	Sinks    notify.Sinks    `xorm:"text" json:"sinks"`  //推送目标,为空时只记录提醒历史
+	Enable   bool            `json:"enable"`
+	Note     string          `json:"note"`     //备注
+	LastBar  int64           `json:"last_bar"` //最后一次提醒的K线时间
+	Created  int64           `xorm:"created" json:"created"`
+	Updated  int64           `xorm:"updated" json:"updated"`
+}
+
+// Check 检查配置并填充默认周期
+func (this *Rule) Check() error {
+	this.Code = strings.ToLower(strings.TrimSpace(this.Code))
+	if this.Code == "" {
+		return errors.New("股票代码不能为空")
+	}
+	switch this.Kind {
+	case KindPriceAbove, KindPriceBelow:
+		if this.Value <= 0 {
+			return errors.New("价格需要大于0")
+		}
+	case KindRSIBelow, KindRSIAbove:
+		if this.Value <= 0 || this.Value >= 100 {
+			return errors.New("RSI阈值需要在0到100之间")
+		}
+		if this.Period <= 0 {
+			this.Period = DefaultRSIPeriod
+		}
+	case KindVolume:
+		if this.Value <= 0 {
+			return errors.New("成交量倍数需要大于0")
+		}
+		if this.Period <= 0 {
+			this.Period = DefaultVolumePeriod
+		}
+	case KindStrategy:
+		if _, err := strategy.With(this.Strategy, this.Params); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("未知的提醒类型[%s],可选price_above/price_below/rsi_below/rsi_above/volume/strategy", this.Kind)
+	}
+	for _, sink := range this.Sinks {
+		if err := sink.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check 判断规则是否触发,day的最后一根为当前K线,返回当前的指标值和说明
+func (this *Rule) check(info extend.Info, day extend.Klines) (bool, float64, string, error) {
+	if len(day) == 0 {
+		return false, 0, "", nil
+	}
+	last := day[len(day)-1]
+	price := last.Close.Float64()
+	switch this.Kind {
+	case KindPriceAbove, KindPriceBelow:
+		if len(day) < 2 {
+			return false, price, "", nil
+		}
+		prev := day[len(day)-2].Close.Float64()
+		if this.Kind == KindPriceAbove {
+			return prev < this.Value && price >= this.Value, price, fmt.Sprintf("价格%.2f上穿%.2f", price, this.Value), nil
+		}
+		return prev > this.Value && price <= this.Value, price, fmt.Sprintf("价格%.2f下穿%.2f", price, this.Value), nil
+
+	case KindRSIBelow, KindRSIAbove:
+		if len(day) <= this.Period {
+			return false, 0, "", nil
+		}
+		rs := indicator.RSI(indicator.Closes(day), this.Period)
+		v := rs[len(rs)-1]
+		if this.Kind == KindRSIBelow {
+			return v < this.Value, v, fmt.Sprintf("RSI(%d)=%.2f小于%.2f", this.Period, v, this.Value), nil
+		}
+		return v > this.Value, v, fmt.Sprintf("RSI(%d)=%.2f大于%.2f", this.Period, v, this.Value), nil
+
+	case KindVolume:
+		if len(day) <= this.Period {
+			return false, 0, "", nil
+		}
+		var sum float64
+		for _, k := range day[len(day)-1-this.Period : len(day)-1] {
+			sum += float64(k.Volume)
+		}
+		avg := sum / float64(this.Period)
+		if avg <= 0 {
+			return false, 0, "", nil
+		}
+		v := float64(last.Volume) / avg
+		return v > this.Value, v, fmt.Sprintf("成交量为%d日均量的%.2f倍,大于%.2f倍", this.Period, v, this.Value), nil
+
+	case KindStrategy:
+		s, err := strategy.With(this.Strategy, this.Params)
+		if err != nil {
+			return false, 0, "", err
+		}
+		d := strategy.Decide(s, info, day, nil)
+		msg := fmt.Sprintf("策略[%s]发出%s信号", this.Strategy, map[strategy.Action]string{strategy.Buy: "买入", strategy.Sell: "卖出"}[d.Action])
+		if d.Reason != "" {
+			msg += ": " + d.Reason
+		}
+		return d.Action != strategy.Hold, float64(d.Action), msg, nil
+	}
+	return false, 0, "", nil
+}
+
+// Alert 提醒历史
+type Alert struct {
+	ID      int64   `xorm:"pk autoincr" json:"id"`
+	RuleID  int64   `json:"rule_id"`
+	Code    string  `json:"code"`
+	Name    string  `json:"name"`
+	Kind    string  `json:"kind"`
+	Trigger string  `json:"trigger"` //update/intraday/manual
+	Time    int64   `json:"time"`    //K线时间
+	Price   float64 `json:"price"`   //当前价格
+	Value   float64 `json:"value"`   //触发时的指标值,价格/RSI/成交量倍数/信号
+	Message string  `json:"message"`
+	Created int64   `xorm:"created" json:"created"`
+}
+
+// evalMu 数据更新和盘中行情可能同时执行,读取规则和提醒需要在一起,避免同一根K线重复提醒
+var evalMu sync.Mutex
+
+// Start 注册数据更新完成后和盘中行情变化后执行提醒规则,需要在数据开始更新之前,行情服务启动之后调用
+func Start() {
+	common.Data.OnUpdated(func() {
+		_, err := EvaluateAll(TriggerUpdate)
+		logs.PrintErr(err)
+	})
+
+	//盘中行情变化后执行允许盘中执行的规则,日线的最后一根为实时K线
+	if quote.Default == nil {
+		return
+	}
+	quote.OnQuote(func(l *quote.Live) {
+		now := time.Now()
+		day, err := quote.Default.GetDayKlinesAdjust(l.Code, now.AddDate(0, 0, -history), now, data.AdjustQFQ)
+		if err != nil {
+			logs.Err(err)
+			return
+		}
+		EvaluateIntraday(l.Code, day)
+	})
+	rules, err := ListRule("")
+	if err != nil {
+		logs.Err(err)
+		return
+	}
+	for _, r := range rules {
+		if r.Enable && r.Intraday {
+			logs.PrintErr(quote.Subscribe(r.Code))
+		}
+	}
+}
+
+// EvaluateAll 使用最新的日线执行全部启用的规则,返回触发的提醒
+func EvaluateAll(trigger string) ([]*Alert, error) {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+	rules, err := ListRule("")
+	if err != nil {
+		return nil, err
+	}
+	group := map[string][]*Rule{}
+	var codes []string
+	for _, r := range rules {
+		if !r.Enable {
+			continue
+		}
+		if _, ok := group[r.Code]; !ok {
+			codes = append(codes, r.Code)
+		}
+		group[r.Code] = append(group[r.Code], r)
+	}
+	now := time.Now()
+	var out []*Alert
+	for _, code := range codes {
+		day, err := common.Klines.GetDayKlinesAdjust(code, now.AddDate(0, 0, -history), now, data.AdjustQFQ)
+		if err != nil {
+			logs.Err(err)
+			continue
+		}
+		out = append(out, evaluate(code, day, group[code], trigger)...)
+	}
+	return out, nil
+}
+
+// EvaluateIntraday 盘中行情更新时执行该股票允许盘中执行的规则,day的最后一根为盘中实时K线
+func EvaluateIntraday(code string, day extend.Klines) []*Alert {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+	rules, err := ListRule(code)
+	if err != nil {
+		logs.Err(err)
+		return nil
+	}
+	var ls []*Rule
+	for _, r := range rules {
+		if r.Enable && r.Intraday {
+			ls = append(ls, r)
+		}
+	}
+	return evaluate(code, day, ls, TriggerIntraday)
+}
+
+// evaluate 执行规则,触发的保存提醒历史并推送
+func evaluate(code string, day extend.Klines, rules []*Rule, trigger string) []*Alert {
+	if len(day) == 0 || len(rules) == 0 {
+		return nil
+	}
+
+	last := day[len(day)-1]
+	name := common.Data.Codes.GetName(code)
+	info := data.NewInfo(code, name, last)
+	var out []*Alert
+	for _, r := range rules {
+		if data.SameDay(r.LastBar, last.Unix) {
+			continue
+		}
+		ok, v, msg, err := r.check(info, day)
+		if err != nil {
+			logs.Err(err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		a := &Alert{
+			RuleID:  r.ID,
+			Code:    code,
+			Name:    name,
+			Kind:    r.Kind,
+			Trigger: trigger,
+			Time:    last.Unix,
+			Price:   last.Close.Float64(),
+			Value:   v,
+			Message: fmt.Sprintf("%s %s %s", code, name, msg),
+		}
+		if _, err = common.DB.Insert(a); err != nil {
+			logs.Err(err)
+			continue
+		}
+		r.LastBar = last.Unix
+		if _, err = common.DB.ID(r.ID).Cols("LastBar").Update(r); err != nil {
+			logs.Err(err)
+		}
+		if len(r.Sinks) > 0 {
+			payload, _ := json.Marshal(a)
+			notify.Deliver(Source, r.ID, a.ID, r.Sinks, fmt.Sprintf("alert-%d", a.ID), payload)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// SaveRule 新建或修改提醒规则,ID为0时新建,修改后可以在同一根K线再次提醒
+func SaveRule(r *Rule) error {
+	if err := table(); err != nil {
+		return err
+	}
+	if err := r.Check(); err != nil {
+		return err
+	}
+	r.LastBar = 0
+	if r.Enable && r.Intraday {
+		//盘中执行需要订阅实时行情
+		if err := quote.Subscribe(r.Code); err != nil {
+			return err
+		}
+	}
+	if r.ID == 0 {
+		r.Sinks.KeepPassword(nil)
+		_, err := common.DB.Insert(r)
+		return err
+	}
+	old, err := GetRule(r.ID)
+	if err != nil {
+		return err
+	}
+	r.Sinks.KeepPassword(old.Sinks)
+	r.Created = old.Created
+	if _, err = common.DB.ID(r.ID).AllCols().Update(r); err != nil {
+		return err
+	}
+	if old.Enable && old.Intraday {
+		//修改了股票代码或者不再盘中执行
+		return release(old.Code)
+	}
+	return nil
+}
+
+// GetRule 获取提醒规则
+func GetRule(id int64) (*Rule, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	r := new(Rule)
+	has, err := common.DB.ID(id).Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("提醒规则[%d]不存在", id)
+	}
+	return r, nil
+}
+
+// ListRule 提醒规则列表,code为空时返回全部
+func ListRule(code string) ([]*Rule, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Rule(nil)
+	session := common.DB.Asc("ID")
+	if code != "" {
+		session = session.Where("Code=?", strings.ToLower(code))
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
+
+// DeleteRule 删除提醒规则,提醒历史保留
+func DeleteRule(id int64) error {
+	r, err := GetRule(id)
+	if err != nil {
+		return err
+	}
+	if _, err = common.DB.ID(id).Delete(new(Rule)); err != nil {
+		return err
+	}
+	if r.Enable && r.Intraday {
+		return release(r.Code)
+	}
+	return nil
+}
+
+// release 没有其它启用的盘中规则使用这只股票时取消订阅实时行情
+func release(code string) error {
+	has, err := common.DB.Where("Code=? and Enable=? and Intraday=?", code, true, true).Exist(new(Rule))
+	if err != nil || has {
+		return err
+	}
+	return quote.Unsubscribe(code)
+}
+
+// HistoryQuery 提醒历史查询条件,为空的条件不过滤
+type HistoryQuery struct {
+	Code   string
+	RuleID int64
+	Kind   string
+	Start  int64 //提醒时间
+	End    int64
+	Limit  int
+}
+
+// ListAlert 提醒历史,按时间倒序
+func ListAlert(q HistoryQuery) ([]*Alert, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Alert(nil)
+	session := common.DB.Desc("ID")
+	if q.Code != "" {
+		session = session.Where("Code=?", strings.ToLower(q.Code))
+	}
+	if q.RuleID > 0 {
+		session = session.Where("RuleID=?", q.RuleID)
+	}
+	if q.Kind != "" {
+		session = session.Where("Kind=?", q.Kind)
+	}
+	if q.Start > 0 {
+		session = session.Where("Created>=?", q.Start)
+	}
+	if q.End > 0 {
+		session = session.Where("Created<?", q.End)
+	}
+	if q.Limit > 0 {
+		session = session.Limit(q.Limit)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
@@ -0,0 +1,99 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/injoyai/strategy/internal/common"
+)
+
+// Watchlist 自选股分组
+type Watchlist struct {
+	ID      int64    `xorm:"pk autoincr" json:"id"`
+	Name    string   `json:"name"`
+	Codes   []string `xorm:"json" json:"codes"` //股票代码,例sz000001
+	Note    string   `json:"note"`
+	Created int64    `xorm:"created" json:"created"`
+	Updated int64    `xorm:"updated" json:"updated"`
+}
+
+var syncOnce struct {
+	sync.Once
+	err error
+}
+
+func table() error {
+	syncOnce.Do(func() {
+		syncOnce.err = common.DB.Sync2(new(Watchlist), new(Rule), new(Alert))
+	})
+	return syncOnce.err
+}
+
+// SaveWatchlist 新建或修改自选股分组,ID为0时新建,代码去重并保持顺序
+func SaveWatchlist(w *Watchlist) error {
+	if err := table(); err != nil {
+		return err
+	}
+	if w.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	codes := make([]string, 0, len(w.Codes))
+	set := map[string]struct{}{}
+	for _, code := range w.Codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if _, ok := set[code]; ok || code == "" {
+			continue
+		}
+		set[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	w.Codes = codes
+	if w.ID == 0 {
+		_, err := common.DB.Insert(w)
+		return err
+	}
+	old, err := GetWatchlist(w.ID)
+	if err != nil {
+		return err
+	}
+	w.Created = old.Created
+	_, err = common.DB.ID(w.ID).AllCols().Update(w)
+	return err
+}
+
+// GetWatchlist 获取自选股分组
+func GetWatchlist(id int64) (*Watchlist, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	w := new(Watchlist)
+	has, err := common.DB.ID(id).Get(w)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("自选股分组[%d]不存在", id)
+	}
+	return w, nil
+}
+
+// ListWatchlist 自选股分组列表
+func ListWatchlist() ([]*Watchlist, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Watchlist(nil)
+	err := common.DB.Asc("ID").Find(&ls)
+	return ls, err
+}
+
+// DeleteWatchlist 删除自选股分组,提醒规则按股票配置,不受影响
+func DeleteWatchlist(id int64) error {
+	if err := table(); err != nil {
+		return err
+	}
+	_, err := common.DB.ID(id).Delete(new(Watchlist))
+	return err
+}
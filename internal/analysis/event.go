@@ -0,0 +1,340 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/strategy/internal/screener"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+const (
+	DefaultBins  = 20  //默认收益分布的分组数量
+	DefaultBound = 0.3 //默认收益分布的范围,-30%~30%,超出的计入两端
+)
+
+// EventRequest 事件研究请求,策略在某只股票某天发出信号即为一个事件
+type EventRequest struct {
+	Strategies []string                   `json:"strategies"` // 策略名称列表
+	Tree       *strategy.Node             `json:"tree"`       // 组合策略树,优先于Strategies
+	Params     map[string]strategy.Params `json:"params"`     // 策略参数覆盖,策略名称->参数
+	StartTime  int64                      `json:"start_time"` // 事件开始时间(秒级时间戳)
+	EndTime    int64                      `json:"end_time"`   // 事件结束时间(秒级时间戳),默认当前时间
+	Warmup     int                        `json:"warmup"`     // 开始时间之前加载的自然日数量,用于指标预热,默认365
+	Horizons   []int                      `json:"horizons"`   // 持有交易日数量,默认1,5,10,20
+	Signal     int                        `json:"signal"`     // 研究的信号 1:买入(默认) -1:卖出
+	Adjust     string                     `json:"adjust"`     // 复权类型 qfq:前复权 hfq:后复权 空:不复权
+	Bins       int                        `json:"bins"`       // 收益分布的分组数量,默认20
+	Bound      float64                    `json:"bound"`      // 收益分布的范围,默认0.3
+	Detail     bool                       `json:"detail"`     // 是否返回每个事件的明细
+}
+
+// Event 一个事件
+type Event struct {
+	Code    string          `json:"code"`
+	Date    int64           `json:"date"`
+	Reason  string          `json:"reason"`
+	Returns map[int]float64 `json:"returns"` // 持有天数->收益率
+}
+
+// Bin 收益分布的一个分组
+type Bin struct {
+	Low      float64 `json:"low"`
+	High     float64 `json:"high"`
+	Event    float64 `json:"event"`    // 事件收益落在这个分组的比例
+	Baseline float64 `json:"baseline"` // 全市场收益落在这个分组的比例
+}
+
+// Baseline 无条件基准,区间内全部股票每个交易日的收益
+type Baseline struct {
+	Count   int     `json:"count"`
+	Mean    float64 `json:"mean"`
+	Std     float64 `json:"std"`
+	WinRate float64 `json:"win_rate"` // 和事件使用相同的胜负方向
+}
+
+// EventStats 某个持有天数的统计
+type EventStats struct {
+	Horizon     int      `json:"horizon"`      // 持有交易日数量
+	Count       int      `json:"count"`        // 有收益数据的事件数量
+	Mean        float64  `json:"mean"`         // 平均收益率
+	Median      float64  `json:"median"`       // 收益率中位数
+	Std         float64  `json:"std"`          // 收益率标准差
+	TStat       float64  `json:"t_stat"`       // 平均收益率的t统计量,检验是否不为0
+	WinRate     float64  `json:"win_rate"`     // 胜率,买入信号收益率大于0的比例,卖出信号收益率小于0的比例
+	MFE         float64  `json:"mfe"`          // 平均最大有利波动,买入信号按持有期内最高价,卖出信号按最低价,相对信号日收盘价
+	MAE         float64  `json:"mae"`          // 平均最大不利波动,买入信号按持有期内最低价,卖出信号按最高价,相对信号日收盘价
+	Baseline    Baseline `json:"baseline"`     // 无条件基准
+	Excess      float64  `json:"excess"`       // 超额收益率,平均收益率-基准平均收益率
+	ExcessTStat float64  `json:"excess_tstat"` // 超额收益率的t统计量(Welch),事件之间有重叠时会偏高
+	Histogram   []Bin    `json:"histogram"`    // 收益分布
+}
+
+// EventResult 事件研究结果
+type EventResult struct {
+	Events int          `json:"events"` // 事件数量
+	Stocks int          `json:"stocks"` // 出现过事件的股票数量
+	Stats  []EventStats `json:"stats"`
+	Detail []Event      `json:"detail,omitempty"` // 事件明细,按时间排序
+}
+
+// moments 流式统计,基准的样本量很大,不保存每个值
+type moments struct {
+	dir   float64 //方向 1:收益率大于0为胜 -1:收益率小于0为胜
+	count int
+	sum   float64
+	sumsq float64
+	wins  int
+	bins  []int
+}
+
+func (this *moments) add(r float64, bin int) {
+	this.count++
+	this.sum += r
+	this.sumsq += r * r
+	if r*this.dir > 0 {
+		this.wins++
+	}
+	this.bins[bin]++
+}
+
+func (this *moments) merge(o *moments) {
+	this.count += o.count
+	this.sum += o.sum
+	this.sumsq += o.sumsq
+	this.wins += o.wins
+	for i := range o.bins {
+		this.bins[i] += o.bins[i]
+	}
+}
+
+func (this *moments) mean() float64 {
+	if this.count == 0 {
+		return 0
+	}
+	return this.sum / float64(this.count)
+}
+
+// variance 样本方差
+func (this *moments) variance() float64 {
+	if this.count < 2 {
+		return 0
+	}
+	m := this.mean()
+	return math.Max(this.sumsq-float64(this.count)*m*m, 0) / float64(this.count-1)
+}
+
+// horizon 单个持有天数的数据
+type horizon struct {
+	rets     []float64
+	mfe, mae float64
+	event    *moments
+	baseline *moments
+}
+
+// RunEvent 事件研究,统计策略信号之后的收益,和同一时期全市场的无条件收益对比
+func RunEvent(req EventRequest) (*EventResult, error) {
+	if req.StartTime == 0 {
+		return nil, errors.New("开始时间不能为空")
+	}
+	if req.EndTime == 0 {
+		req.EndTime = time.Now().Unix()
+	}
+	if req.EndTime < req.StartTime {
+		return nil, errors.New("结束时间不能早于开始时间")
+	}
+	if err := data.CheckAdjust(req.Adjust); err != nil {
+		return nil, err
+	}
+	if len(req.Horizons) == 0 {
+		req.Horizons = screener.DefaultHorizons
+	}
+	for _, h := range req.Horizons {
+		if h <= 0 {
+			return nil, errors.New("持有天数需要大于0")
+		}
+	}
+	if req.Warmup <= 0 {
+		req.Warmup = 365
+	}
+	if req.Bins <= 0 {
+		req.Bins = DefaultBins
+	}
+	if req.Bound <= 0 {
+		req.Bound = DefaultBound
+	}
+	signal := strategy.Buy
+	if req.Signal < 0 {
+		signal = strategy.Sell
+	}
+	dir := float64(signal)
+
+	strat, err := strategy.Build(req.Tree, req.Strategies, nil, req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	bin := func(r float64) int {
+		i := int((r + req.Bound) / (2 * req.Bound) * float64(req.Bins))
+		return min(max(i, 0), req.Bins-1)
+	}
+	newHorizons := func() []*horizon {
+		hs := make([]*horizon, len(req.Horizons))
+		for i := range hs {
+			hs[i] = &horizon{
+				event:    &moments{dir: dir, bins: make([]int, req.Bins)},
+				baseline: &moments{dir: dir, bins: make([]int, req.Bins)},
+			}
+		}
+		return hs
+	}
+
+	start := time.Unix(req.StartTime, 0)
+	end := time.Unix(req.EndTime, 0)
+	mu := sync.Mutex{}
+	total := newHorizons()
+	stocks := 0
+	var events []Event
+
+	//持有期需要之后的数据,加载到当前时间
+	err = common.Klines.RangeKlinesAdjust(
+		100,
+		start.AddDate(0, 0, -req.Warmup),
+		time.Now().AddDate(0, 0, 1),
+		req.Adjust,
+		func(info extend.Info, day, _ extend.Klines) {
+			hs := newHorizons()
+			var es []Event
+			st := strategy.NewStream(strat, info, nil)
+			for i, k := range day {
+				d := st.OnBar(k)
+				if k.Unix > end.Unix() {
+					break
+				}
+				if k.Unix < start.Unix() {
+					continue
+				}
+				entry := k.Close.Float64()
+				if entry <= 0 {
+					continue
+				}
+				fired := d.Action == signal
+				var e Event
+				if fired {
+					e = Event{Code: info.Code, Date: k.Unix, Reason: d.Reason, Returns: map[int]float64{}}
+				}
+				for x, h := range req.Horizons {
+					if i+h >= len(day) {
+						continue
+					}
+					r := day[i+h].Close.Float64()/entry - 1
+					hs[x].baseline.add(r, bin(r))
+					if !fired {
+						continue
+					}
+					high, low := day[i+1].High, day[i+1].Low
+					for _, v := range day[i+1 : i+h+1] {
+						high, low = max(high, v.High), min(low, v.Low)
+					}
+					//卖出信号价格下跌是有利的
+					fav, adv := high, low
+					if signal == strategy.Sell {
+						fav, adv = low, high
+					}
+					hs[x].rets = append(hs[x].rets, r)
+					hs[x].mfe += dir * (fav.Float64()/entry - 1)
+					hs[x].mae += dir * (adv.Float64()/entry - 1)
+					hs[x].event.add(r, bin(r))
+					e.Returns[h] = r
+				}
+				if fired {
+					es = append(es, e)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for x, h := range hs {
+				total[x].rets = append(total[x].rets, h.rets...)
+				total[x].mfe += h.mfe
+				total[x].mae += h.mae
+				total[x].event.merge(h.event)
+				total[x].baseline.merge(h.baseline)
+			}
+			if len(es) > 0 {
+				stocks++
+				events = append(events, es...)
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EventResult{Events: len(events), Stocks: stocks}
+	for x, h := range req.Horizons {
+		t := total[x]
+		s := EventStats{
+			Horizon: h,
+			Count:   t.event.count,
+			Baseline: Baseline{
+				Count: t.baseline.count,
+				Mean:  t.baseline.mean(),
+				Std:   math.Sqrt(t.baseline.variance()),
+			},
+		}
+		if t.baseline.count > 0 {
+			s.Baseline.WinRate = float64(t.baseline.wins) / float64(t.baseline.count)
+		}
+		if s.Count > 0 {
+			n := float64(s.Count)
+			s.Mean = t.event.mean()
+			s.Median = indicator.Median(t.rets)
+			s.Std = math.Sqrt(t.event.variance())
+			s.WinRate = float64(t.event.wins) / n
+			s.MFE = t.mfe / n
+			s.MAE = t.mae / n
+			if s.Std > 0 {
+				s.TStat = s.Mean / (s.Std / math.Sqrt(n))
+			}
+			s.Excess = s.Mean - s.Baseline.Mean
+			if t.baseline.count > 0 {
+				se := math.Sqrt(t.event.variance()/n + t.baseline.variance()/float64(t.baseline.count))
+				if se > 0 {
+					s.ExcessTStat = s.Excess / se
+				}
+			}
+		}
+		width := 2 * req.Bound / float64(req.Bins)
+		for i := 0; i < req.Bins; i++ {
+			b := Bin{Low: -req.Bound + float64(i)*width, High: -req.Bound + float64(i+1)*width}
+			if t.event.count > 0 {
+				b.Event = float64(t.event.bins[i]) / float64(t.event.count)
+			}
+			if t.baseline.count > 0 {
+				b.Baseline = float64(t.baseline.bins[i]) / float64(t.baseline.count)
+			}
+			s.Histogram = append(s.Histogram, b)
+		}
+		result.Stats = append(result.Stats, s)
+	}
+
+	if req.Detail {
+		sort.Slice(events, func(i, j int) bool {
+			if events[i].Date != events[j].Date {
+				return events[i].Date < events[j].Date
+			}
+			return events[i].Code < events[j].Code
+		})
+		result.Detail = events
+	}
+
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// seller 每根K线都发出卖出信号的策略
+type seller struct{}
+
+func (seller) Name() string { return "测试事件研究卖出" }
+
+func (seller) Type() string { return strategy.DayKline }
+
+func (seller) Signal(info extend.Info, day, min extend.Klines) bool { return false }
+
+func (seller) Decide(info extend.Info, day, min extend.Klines) strategy.Decision {
+	return strategy.Decision{Action: strategy.Sell}
+}
+
+// fakeReader 只有一只股票的日线
+type fakeReader struct {
+	data.Reader
+	day extend.Klines
+}
+
+func (this *fakeReader) RangeKlinesAdjust(limit int, start, end time.Time, adjust string, f data.Handler) error {
+	f(extend.Info{Code: "sz000001", Name: "测试"}, this.day, nil)
+	return nil
+}
+
+func eventBar(day int, high, low, close float64) *extend.Kline {
+	t := time.Date(2024, 1, day, 15, 0, 0, 0, time.Local)
+	return &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{
+		High: protocol.Yuan(high), Low: protocol.Yuan(low), Close: protocol.Yuan(close), Time: t,
+	}}
+}
+
+func TestRunEventSell(t *testing.T) {
+	strategy.Register(seller{})
+	old := common.Klines
+	defer func() { common.Klines = old }()
+	common.Klines = &fakeReader{day: extend.Klines{
+		eventBar(2, 10, 10, 10),
+		eventBar(3, 9.5, 8.5, 9),
+		eventBar(4, 8.1, 7.2, 8),
+	}}
+
+	res, err := RunEvent(EventRequest{
+		Strategies: []string{"测试事件研究卖出"},
+		StartTime:  eventBar(2, 0, 0, 0).Unix,
+		Horizons:   []int{1},
+		Signal:     -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Events != 3 || len(res.Stats) != 1 {
+		t.Fatalf("事件 %d, 统计 %d", res.Events, len(res.Stats))
+	}
+	s := res.Stats[0]
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"数量", float64(s.Count), 2},
+		{"平均收益", s.Mean, (-0.1 + 8.0/9 - 1) / 2},
+		//价格下跌,卖出信号都算胜
+		{"胜率", s.WinRate, 1},
+		{"基准胜率", s.Baseline.WinRate, 1},
+		//最低价是有利波动,最高价是不利波动
+		{"MFE", s.MFE, ((1 - 0.85) + (1 - 7.2/9)) / 2},
+		{"MAE", s.MAE, ((1 - 0.95) + (1 - 8.1/9)) / 2},
+	} {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("[%s] %v, 期望 %v", c.name, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"time"
+
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/strategy/internal/alert"
+)
+
+// GetWatchlists
+// @Summary 自选股分组列表
+// @Tags 自选股
+// @Success 200 {array} alert.Watchlist
+// @Router /api/watchlist [get]
+func GetWatchlists(c fbr.Ctx) {
+	ls, err := alert.ListWatchlist()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetWatchlist
+// @Summary 自选股分组详情
+// @Tags 自选股
+// @Param id path int true "id"
+// @Success 200 {object} alert.Watchlist
+// @Router /api/watchlist/{id} [get]
+func GetWatchlist(c fbr.Ctx) {
+	w, err := alert.GetWatchlist(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(w)
+}
+
+// PostWatchlist
+// @Summary 新建自选股分组
+// @Tags 自选股
+// @Param data body alert.Watchlist true "body"
+// @Success 200 {object} alert.Watchlist
+// @Router /api/watchlist [post]
+func PostWatchlist(c fbr.Ctx) {
+	var w alert.Watchlist
+	c.Parse(&w)
+	w.ID = 0
+	c.CheckErr(alert.SaveWatchlist(&w))
+	c.Succ(w)
+}
+
+// PutWatchlist
+// @Summary 修改自选股分组
+// @Tags 自选股
+// @Param id path int true "id"
+// @Param data body alert.Watchlist true "body"
+// @Success 200 {object} alert.Watchlist
+// @Router /api/watchlist/{id} [put]
+func PutWatchlist(c fbr.Ctx) {
+	var w alert.Watchlist
+	c.Parse(&w)
+	w.ID = c.GetInt64("id")
+	c.CheckErr(alert.SaveWatchlist(&w))
+	c.Succ(w)
+}
+
+// DelWatchlist
+// @Summary 删除自选股分组
+// @Tags 自选股
+// @Param id path int true "id"
+// @Router /api/watchlist/{id} [delete]
+func DelWatchlist(c fbr.Ctx) {
+	c.CheckErr(alert.DeleteWatchlist(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// GetAlertRules
+// @Summary 提醒规则列表
+// @Tags 提醒
+// @Param code query string false "股票代码,为空返回全部"
+// @Success 200 {array} alert.Rule
+// @Router /api/alert/rules [get]
+func GetAlertRules(c fbr.Ctx) {
+	ls, err := alert.ListRule(c.GetString("code"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// PostAlertRule
+// @Summary 新建提醒规则
+// @Description kind: price_above:价格上穿value price_below:价格下穿value rsi_below:RSI(period)小于value rsi_above:RSI(period)大于value
+// @Description volume:成交量大于period日均量的value倍 strategy:策略发出买卖信号,数据更新完成后执行,intraday为true时盘中行情也执行
+// @Tags 提醒
+// @Param data body alert.Rule true "body"
+// @Success 200 {object} alert.Rule
+// @Router /api/alert/rules [post]
+func PostAlertRule(c fbr.Ctx) {
+	var r alert.Rule
+	c.Parse(&r)
+	r.ID = 0
+	c.CheckErr(alert.SaveRule(&r))
+	c.Succ(r)
+}
+
+// PutAlertRule
+// @Summary 修改提醒规则
+// @Tags 提醒
+// @Param id path int true "id"
+// @Param data body alert.Rule true "body"
+// @Success 200 {object} alert.Rule
+// @Router /api/alert/rules/{id} [put]
+func PutAlertRule(c fbr.Ctx) {
+	var r alert.Rule
+	c.Parse(&r)
+	r.ID = c.GetInt64("id")
+	c.CheckErr(alert.SaveRule(&r))
+	c.Succ(r)
+}
+
+// DelAlertRule
+// @Summary 删除提醒规则
+// @Description 提醒历史保留,没有其它启用的盘中规则使用这只股票时取消订阅实时行情
+// @Tags 提醒
+// @Param id path int true "id"
+// @Router /api/alert/rules/{id} [delete]
+func DelAlertRule(c fbr.Ctx) {
+	c.CheckErr(alert.DeleteRule(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// EvaluateAlerts
+// @Summary 立即执行提醒规则
+// @Description 使用最新的日线执行全部启用的规则,返回触发的提醒
+// @Tags 提醒
+// @Success 200 {array} alert.Alert
+// @Router /api/alert/evaluate [post]
+func EvaluateAlerts(c fbr.Ctx) {
+	ls, err := alert.EvaluateAll(alert.TriggerManual)
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetAlertHistory
+// @Summary 提醒历史
+// @Description 按时间倒序
+// @Tags 提醒
+// @Param code query string false "股票代码"
+// @Param rule_id query int false "规则id"
+// @Param kind query string false "提醒类型"
+// @Param start query string false "开始日期"
+// @Param end query string false "结束日期"
+// @Param limit query int false "数量,默认100"
+// @Success 200 {array} alert.Alert
+// @Router /api/alert/history [get]
+func GetAlertHistory(c fbr.Ctx) {
+	q := alert.HistoryQuery{
+		Code:   c.GetString("code"),
+		RuleID: c.GetInt64("rule_id"),
+		Kind:   c.GetString("kind"),
+		Limit:  c.GetInt("limit", 100),
+	}
+	if start := c.GetString("start"); start != "" {
+		t, err := time.Parse("2006-01-02", start)
+		c.CheckErr(err)
+		q.Start = t.Unix()
+	}
+	if end := c.GetString("end"); end != "" {
+		t, err := time.Parse("2006-01-02", end)
+		c.CheckErr(err)
+		q.End = t.AddDate(0, 0, 1).Unix()
+	}
+	ls, err := alert.ListAlert(q)
+	c.CheckErr(err)
+	c.Succ(ls)
+}
@@ -1,18 +1,59 @@
 package api
 
+import (
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/optimize"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
 type backtestReq struct {
-	Strategy   string   `json:"strategy"`
-	Strategies []string `json:"strategies"`
-	Code       string   `json:"code"`
-	Start      string   `json:"start"`
-	End        string   `json:"end"`
-	Cash       float64  `json:"cash"`
-	Size       int      `json:"size"`
-	FeeRate    float64  `json:"fee_rate"`
-	MinFee     float64  `json:"min_fee"`
-	Slippage   float64  `json:"slippage"`
-	StopLoss   float64  `json:"stop_loss"`
-	TakeProfit float64  `json:"take_profit"`
+	Strategy   string                     `json:"strategy"`
+	Strategies []string                   `json:"strategies"`
+	Tree       *strategy.Node             `json:"tree"`   //组合策略树,优先于Strategies
+	Params     map[string]strategy.Params `json:"params"` //策略参数覆盖,策略名称->参数
+	Code       string                     `json:"code"`
+	Start      string                     `json:"start"`
+	End        string                     `json:"end"`
+	Cash       float64                    `json:"cash"`
+	Size       int                        `json:"size"`
+	FeeRate    float64                    `json:"fee_rate"`
+	MinFee     float64                    `json:"min_fee"`
+	Slippage   float64                    `json:"slippage"`
+	StopLoss   float64                    `json:"stop_loss"`
+	TakeProfit float64                    `json:"take_profit"`
+	Sizer      *backtest.SizerConfig      `json:"sizer"`     //仓位模型,为空时按Size固定股数,Size也为空时全仓
+	Market     string                     `json:"market"`    //交易规则,cn:A股规则(T+1,整手,涨跌停,印花税,过户费,停牌),空不限制
+	RiskFree   float64                    `json:"risk_free"` //年化无风险利率
+	Periods    float64                    `json:"periods"`   //每年的K线数量,0按K线间隔自动推断
+	Benchmark  string                     `json:"benchmark"` //基准指数代码,例sh000300
+	Adjust     string                     `json:"adjust"`    //复权类型 qfq:前复权 hfq:后复权 空:不复权
+}
+
+type monteCarloReq struct {
+	backtestReq
+	MonteCarlo backtest.MonteCarloConfig `json:"monte_carlo"` //模拟配置
+}
+
+type portfolioReq struct {
+	Strategies   []string                   `json:"strategies"`
+	Tree         *strategy.Node             `json:"tree"`          //组合策略树,优先于Strategies
+	Params       map[string]strategy.Params `json:"params"`        //策略参数覆盖,策略名称->参数
+	Codes        []string                   `json:"codes"`         //股票池,为空时使用全市场
+	Start        string                     `json:"start"`         //开始时间
+	End          string                     `json:"end"`           //结束时间
+	Cash         float64                    `json:"cash"`          //初始资金
+	MaxPositions int                        `json:"max_positions"` //最大持仓数量
+	MaxWeight    float64                    `json:"max_weight"`    //单只股票最大仓位比例
+	Rebalance    int                        `json:"rebalance"`     //调仓周期(交易日)
+	FeeRate      float64                    `json:"fee_rate"`
+	MinFee       float64                    `json:"min_fee"`
+	Slippage     float64                    `json:"slippage"`
+	Market       string                     `json:"market"`    //交易规则,cn:A股规则
+	RiskFree     float64                    `json:"risk_free"` //年化无风险利率
+	Periods      float64                    `json:"periods"`   //每年的K线数量,0按K线间隔自动推断
+	Benchmark    string                     `json:"benchmark"` //基准指数代码,例sh000300
+	Adjust       string                     `json:"adjust"`    //复权类型 qfq:前复权 hfq:后复权 空:不复权
 }
 
 type CodesResp struct {
@@ -26,6 +67,63 @@ type BacktestItem struct {
 	Return      float64 `json:"return"`
 	MaxDrawdown float64 `json:"max_drawdown"`
 	Sharpe      float64 `json:"sharpe"`
+	//相对基准,设置了基准时有效
+	ExcessReturn float64 `json:"excess_return,omitempty"`
+	Alpha        float64 `json:"alpha,omitempty"`
+	Beta         float64 `json:"beta,omitempty"`
+}
+
+// benchmarkSum 全市场回测相对基准的汇总
+type benchmarkSum struct {
+	count                   int
+	beat                    int //跑赢基准的数量
+	excess, alpha, beta, ir float64
+	upCapture, downCapture  float64
+}
+
+func (this *benchmarkSum) add(b *backtest.Benchmark) {
+	if b == nil {
+		return
+	}
+	this.count++
+	if b.ExcessReturn > 0 {
+		this.beat++
+	}
+	this.excess += b.ExcessReturn
+	this.alpha += b.Alpha
+	this.beta += b.Beta
+	this.ir += b.InformationRatio
+	this.upCapture += b.UpCapture
+	this.downCapture += b.DownCapture
+}
+
+func (this *benchmarkSum) summary(code string, ks extend.Klines) map[string]any {
+	var ret float64
+	if len(ks) > 0 && ks[0].Close > 0 {
+		ret = ks[len(ks)-1].Close.Float64()/ks[0].Close.Float64() - 1
+	}
+	avg := func(v float64) float64 {
+		if this.count == 0 {
+			return 0
+		}
+		return v / float64(this.count)
+	}
+	return map[string]any{
+		"code":                  code,
+		"return":                ret,
+		"beat_count":            this.beat,
+		"avg_excess_return":     avg(this.excess),
+		"avg_alpha":             avg(this.alpha),
+		"avg_beta":              avg(this.beta),
+		"avg_information_ratio": avg(this.ir),
+		"avg_up_capture":        avg(this.upCapture),
+		"avg_down_capture":      avg(this.downCapture),
+	}
+}
+
+type rerunResp struct {
+	*backtest.Record
+	Changed []string `json:"changed"` //和原记录相比版本有变化的策略,例如脚本被修改过
 }
 
 type BacktestAllResp struct {
@@ -35,3 +133,15 @@ type BacktestAllResp struct {
 	Count          int            `json:"count"`
 	Items          []BacktestItem `json:"items"`
 }
+
+type optimizeReq struct {
+	optimize.Request
+	Codes    []string    `json:"codes"`    //股票池,为空时使用全市场
+	Backtest backtestReq `json:"backtest"` //回测配置,使用开始结束时间,资金,手续费,仓位模型等,策略相关的字段无效
+}
+
+type walkForwardReq struct {
+	optimize.WalkForwardRequest
+	Codes    []string    `json:"codes"`    //股票池,为空时使用全市场
+	Backtest backtestReq `json:"backtest"` //回测配置,同参数优化
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/optimize"
+)
+
+// OptimizeWS
+// @Summary 参数优化
+// @Description 网格搜索或随机搜索策略参数,并发回测,通过websocket推送进度,完成后保存结果
+// @Description 消息类型 progress:进度 result:完成 error:失败
+// @Tags 优化
+// @Param data query string true "optimizeReq的JSON字符串"
+// @Router /api/optimize/ws [get]
+func OptimizeWS(c fbr.Ctx) {
+	var req optimizeReq
+	c.CheckErr(json.Unmarshal([]byte(c.GetString("data")), &req))
+	c.CheckErr(optimize.Check(&req.Request))
+	series, settings, err := req.Backtest.load(req.Codes)
+	c.CheckErr(err)
+
+	c.Websocket(func(conn *fbr.Websocket) {
+		//连接断开后停止优化
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := optimize.Run(ctx, req.Request, series, settings, common.Data.Goroutines, func(p optimize.Progress) {
+			if err := conn.WriteJSON(map[string]any{"type": "progress", "progress": p}); err != nil {
+				cancel()
+			}
+		})
+		if err == nil {
+			err = optimize.Save(res)
+		}
+		if err != nil {
+			logs.Err(err)
+			_ = conn.WriteJSON(map[string]any{"type": "error", "error": err.Error()})
+			return
+		}
+
+		//结果较多时只推送前100个,完整结果通过id查询
+		rows := res.Rows
+		if len(rows) > 100 {
+			rows = rows[:100]
+		}
+		_ = conn.WriteJSON(map[string]any{
+			"type":    "result",
+			"id":      res.ID,
+			"best":    res.Best(),
+			"rows":    rows,
+			"total":   len(res.Rows),
+			"elapsed": res.Elapsed,
+		})
+	})
+}
+
+// GetOptimizeList
+// @Summary 参数优化记录
+// @Description 参数优化记录,不包含明细
+// @Tags 优化
+// @Success 200 {array} optimize.Optimization
+// @Router /api/optimize/list [get]
+func GetOptimizeList(c fbr.Ctx) {
+	ls, err := optimize.List()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetOptimize
+// @Summary 参数优化结果
+// @Description 参数优化结果,按优化目标排序
+// @Tags 优化
+// @Param id query int true "id"
+// @Success 200 {object} optimize.Optimization
+// @Router /api/optimize [get]
+func GetOptimize(c fbr.Ctx) {
+	o, err := optimize.Get(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(o)
+}
+
+// GetOptimizeHeatmap
+// @Summary 参数敏感度热力图
+// @Description 任意两个参数的热力图,其他参数取每个格子里最好的结果
+// @Tags 优化
+// @Param id query int true "id"
+// @Param x query string true "横轴参数"
+// @Param y query string true "纵轴参数"
+// @Success 200 {object} optimize.Heatmap
+// @Router /api/optimize/heatmap [get]
+func GetOptimizeHeatmap(c fbr.Ctx) {
+	o, err := optimize.Get(c.GetInt64("id"))
+	c.CheckErr(err)
+	h, err := o.Heatmap(c.GetString("x"), c.GetString("y"))
+	c.CheckErr(err)
+	c.Succ(h)
+}
+
+// DelOptimize
+// @Summary 删除参数优化结果
+// @Tags 优化
+// @Param id query int true "id"
+// @Router /api/optimize [delete]
+func DelOptimize(c fbr.Ctx) {
+	c.CheckErr(optimize.Delete(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// WalkForwardWS
+// @Summary 滚动优化
+// @Description 按滚动或锚定窗口,在每个样本内窗口优化参数并回测之后的样本外窗口,通过websocket推送进度
+// @Description 消息类型 progress:进度 window:一个窗口完成 result:完成 error:失败
+// @Tags 优化
+// @Param data query string true "walkForwardReq的JSON字符串"
+// @Router /api/optimize/walkforward/ws [get]
+func WalkForwardWS(c fbr.Ctx) {
+	var req walkForwardReq
+	c.CheckErr(json.Unmarshal([]byte(c.GetString("data")), &req))
+	c.CheckErr(optimize.CheckWalkForward(&req.WalkForwardRequest))
+	series, settings, err := req.Backtest.load(req.Codes)
+	c.CheckErr(err)
+
+	c.Websocket(func(conn *fbr.Websocket) {
+		//连接断开后停止优化
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := optimize.RunWalkForward(ctx, req.WalkForwardRequest, series, settings, common.Data.Goroutines, func(p optimize.WalkProgress) {
+			msg := map[string]any{"type": "progress", "progress": p}
+			if p.Result != nil {
+				msg = map[string]any{"type": "window", "window": p.Result}
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				cancel()
+			}
+		})
+		if err != nil {
+			logs.Err(err)
+			_ = conn.WriteJSON(map[string]any{"type": "error", "error": err.Error()})
+			return
+		}
+		_ = conn.WriteJSON(map[string]any{"type": "result", "result": res})
+	})
+}
+
+// load 按回测配置加载股票池的K线和回测参数,时间为空时使用全部数据
+func (this *backtestReq) load(codes []string) ([]backtest.Series, backtest.Settings, error) {
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Now()
+	var err error
+	if this.Start != "" {
+		if start, err = time.Parse("2006-01-02", this.Start); err != nil {
+			return nil, backtest.Settings{}, err
+		}
+	}
+	if this.End != "" {
+		if end, err = time.Parse("2006-01-02", this.End); err != nil {
+			return nil, backtest.Settings{}, err
+		}
+	}
+	settings, err := this.settings(start, end)
+	if err != nil {
+		return nil, settings, err
+	}
+	series, err := loadUniverse(codes, start, end, this.Adjust)
+	return series, settings, err
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/strategy/internal/paper"
+)
+
+// GetPaperAccounts
+// @Summary 模拟账户列表
+// @Description 包含可用现金,持仓市值,总资产和盈亏,持仓按最新价估值,实时行情服务启动后包含盘中价格
+// @Tags 模拟交易
+// @Success 200 {array} paper.Account
+// @Router /api/paper [get]
+func GetPaperAccounts(c fbr.Ctx) {
+	ls, err := paper.ListAccount()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetPaperAccount
+// @Summary 模拟账户详情
+// @Description 持仓按最新价估值,实时行情服务启动后包含盘中价格
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Success 200 {object} paper.Account
+// @Router /api/paper/{id} [get]
+func GetPaperAccount(c fbr.Ctx) {
+	a, err := paper.GetAccount(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(a)
+}
+
+// PostPaperAccount
+// @Summary 新建模拟账户
+// @Description 配置策略,股票池(codes或自选股分组),初始资金,手续费,滑点,止损止盈,仓位模型和交易规则,和回测的含义一致,
+// @Description 启用后每天数据更新完成后用最新日线下单,按收盘价加滑点成交
+// @Tags 模拟交易
+// @Param data body paper.Account true "body"
+// @Success 200 {object} paper.Account
+// @Router /api/paper [post]
+func PostPaperAccount(c fbr.Ctx) {
+	var a paper.Account
+	c.Parse(&a)
+	a.ID = 0
+	c.CheckErr(paper.SaveAccount(&a))
+	c.Succ(a)
+}
+
+// PutPaperAccount
+// @Summary 修改模拟账户
+// @Description 修改策略和交易配置,初始资金,现金和持仓不变
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Param data body paper.Account true "body"
+// @Success 200 {object} paper.Account
+// @Router /api/paper/{id} [put]
+func PutPaperAccount(c fbr.Ctx) {
+	var a paper.Account
+	c.Parse(&a)
+	a.ID = c.GetInt64("id")
+	c.CheckErr(paper.SaveAccount(&a))
+	c.Succ(a)
+}
+
+// PutPaperAccountEnable
+// @Summary 启用或暂停模拟账户
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Param enable query bool true "是否启用"
+// @Router /api/paper/{id}/enable [put]
+func PutPaperAccountEnable(c fbr.Ctx) {
+	c.CheckErr(paper.EnableAccount(c.GetInt64("id"), c.GetBool("enable")))
+	c.Succ(nil)
+}
+
+// DelPaperAccount
+// @Summary 删除模拟账户
+// @Description 同时删除持仓,订单和资产记录
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Router /api/paper/{id} [delete]
+func DelPaperAccount(c fbr.Ctx) {
+	c.CheckErr(paper.DeleteAccount(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// RunPaperAccount
+// @Summary 立即执行模拟交易
+// @Description 用最新的日线执行一次,每根K线只处理一次,已经处理过时time为0且没有订单
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Success 200 {object} paper.Step
+// @Router /api/paper/{id}/run [post]
+func RunPaperAccount(c fbr.Ctx) {
+	step, err := paper.Run(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(step)
+}
+
+// GetPaperPositions
+// @Summary 模拟账户持仓
+// @Description 按最新价计算市值和盈亏,实时行情服务启动后包含盘中价格
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Success 200 {array} paper.Position
+// @Router /api/paper/{id}/positions [get]
+func GetPaperPositions(c fbr.Ctx) {
+	ls, err := paper.ListPosition(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetPaperOrders
+// @Summary 模拟账户订单
+// @Description 按时间倒序,包含未成交的订单
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Param code query string false "股票代码"
+// @Param limit query int false "数量,默认全部"
+// @Success 200 {array} paper.Order
+// @Router /api/paper/{id}/orders [get]
+func GetPaperOrders(c fbr.Ctx) {
+	ls, err := paper.ListOrder(c.GetInt64("id"), c.GetString("code"), c.GetInt("limit"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetPaperEquity
+// @Summary 模拟账户资产曲线
+// @Description 每个交易日收盘后的现金,市值,总资产和收益率
+// @Tags 模拟交易
+// @Param id path int true "id"
+// @Success 200 {array} paper.EquityPoint
+// @Router /api/paper/{id}/equity [get]
+func GetPaperEquity(c fbr.Ctx) {
+	ls, err := paper.ListEquity(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/strategy/internal/quote"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+type quoteSignalReq struct {
+	Strategies []string                   `json:"strategies"`
+	Tree       *strategy.Node             `json:"tree"`   //组合策略树,优先于Strategies
+	Params     map[string]strategy.Params `json:"params"` //策略参数覆盖,策略名称->参数
+	Codes      []string                   `json:"codes"`  //股票代码,为空时使用全部订阅的股票
+}
+
+type quoteSubscribeReq struct {
+	Codes []string `json:"codes"` //股票代码,例sz000001
+}
+
+func quoteService(c fbr.Ctx) *quote.Service {
+	if quote.Default == nil {
+		c.CheckErr(errors.New("实时行情服务未启动"))
+	}
+	return quote.Default
+}
+
+// GetQuoteSubscriptions
+// @Summary 订阅的股票
+// @Tags 实时行情
+// @Success 200 {array} quote.Subscription
+// @Router /api/quote/subscriptions [get]
+func GetQuoteSubscriptions(c fbr.Ctx) {
+	ls, err := quote.ListSubscription()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// PostQuoteSubscribe
+// @Summary 订阅股票
+// @Description 交易时间内轮询订阅股票的快照行情,重启后继续订阅
+// @Tags 实时行情
+// @Param data body quoteSubscribeReq true "body"
+// @Router /api/quote/subscriptions [post]
+func PostQuoteSubscribe(c fbr.Ctx) {
+	var req quoteSubscribeReq
+	c.Parse(&req)
+	c.CheckErr(quote.Subscribe(req.Codes...))
+	c.Succ(nil)
+}
+
+// DelQuoteSubscribe
+// @Summary 取消订阅
+// @Tags 实时行情
+// @Param code path string true "股票代码"
+// @Router /api/quote/subscriptions/{code} [delete]
+func DelQuoteSubscribe(c fbr.Ctx) {
+	c.CheckErr(quote.Unsubscribe(c.GetString("code")))
+	c.Succ(nil)
+}
+
+// GetQuoteLives
+// @Summary 实时行情
+// @Description 订阅股票当天的最新行情和实时日K线
+// @Tags 实时行情
+// @Success 200 {array} quote.Live
+// @Router /api/quote/live [get]
+func GetQuoteLives(c fbr.Ctx) {
+	c.Succ(quoteService(c).List())
+}
+
+// GetQuoteLive
+// @Summary 单只股票的实时行情
+// @Tags 实时行情
+// @Param code path string true "股票代码"
+// @Success 200 {object} quote.Live
+// @Router /api/quote/live/{code} [get]
+func GetQuoteLive(c fbr.Ctx) {
+	code := c.GetString("code")
+	l := quoteService(c).Get(code)
+	if l == nil {
+		c.Err("没有股票[" + code + "]的实时行情,请先订阅")
+	}
+	c.Succ(l)
+}
+
+// PollQuote
+// @Summary 立即请求一次行情
+// @Description 不限制交易时间,返回有变化的行情,并执行盘中提醒
+// @Tags 实时行情
+// @Success 200 {array} quote.Live
+// @Router /api/quote/poll [post]
+func PollQuote(c fbr.Ctx) {
+	ls, err := quoteService(c).Poll()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetQuoteKlines
+// @Summary 包含实时K线的日线
+// @Description 历史日线加上当天的实时K线,数据更新后使用数据库中的日线
+// @Tags 实时行情
+// @Param code query string true "股票代码例sz000001"
+// @Param start query string false "开始日期,默认一年前"
+// @Param end query string false "结束日期(包含),默认今天"
+// @Param adjust query string false "复权类型 qfq:前复权 hfq:后复权 空:不复权"
+// @Success 200 {array} protocol.Kline
+// @Router /api/quote/klines [get]
+func GetQuoteKlines(c fbr.Ctx) {
+	now := time.Now()
+	start, err := time.Parse("2006-01-02", c.GetString("start", now.AddDate(-1, 0, 0).Format(time.DateOnly)))
+	c.CheckErr(err)
+	end, err := time.Parse("2006-01-02", c.GetString("end", now.Format(time.DateOnly)))
+	c.CheckErr(err)
+	ks, err := quoteService(c).GetDayKlinesAdjust(c.GetString("code"), start, end.AddDate(0, 0, 1), c.GetString("adjust"))
+	c.CheckErr(err)
+	c.Succ(ks)
+}
+
+// PostQuoteSignal
+// @Summary 盘中策略信号
+// @Description 对有实时行情的股票执行策略,日线使用前复权,最后一根为当天的实时K线
+// @Description 选股和回测的接口不包含实时K线,盘中需要使用这个接口
+// @Tags 实时行情
+// @Param data body quoteSignalReq true "body"
+// @Success 200 {array} quote.Signal
+// @Router /api/quote/signal [post]
+func PostQuoteSignal(c fbr.Ctx) {
+	var req quoteSignalReq
+	c.Parse(&req)
+	strat, err := strategy.Build(req.Tree, req.Strategies, nil, req.Params)
+	c.CheckErr(err)
+	ls, err := quoteService(c).Evaluate(strat, req.Codes...)
+	c.CheckErr(err)
+	c.Succ(ls)
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/report"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+// GetBacktestRuns
+// @Summary 回测记录
+// @Description 回测记录列表,不包含交易和资金曲线,按时间倒序
+// @Tags 回测
+// @Param kind query string false "类型 single:单只股票 portfolio:组合 all:全市场"
+// @Success 200 {array} backtest.Record
+// @Router /api/backtest/runs [get]
+func GetBacktestRuns(c fbr.Ctx) {
+	ls, err := backtest.ListRecord(c.GetString("kind"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetBacktestRun
+// @Summary 回测记录详情
+// @Tags 回测
+// @Param id path int true "id"
+// @Success 200 {object} backtest.Record
+// @Router /api/backtest/{id} [get]
+func GetBacktestRun(c fbr.Ctx) {
+	r, err := backtest.GetRecord(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(r)
+}
+
+// DelBacktestRun
+// @Summary 删除回测记录
+// @Tags 回测
+// @Param id path int true "id"
+// @Router /api/backtest/{id} [delete]
+func DelBacktestRun(c fbr.Ctx) {
+	c.CheckErr(backtest.DeleteRecord(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// CompareBacktestRuns
+// @Summary 回测对比
+// @Description 多个回测记录的指标和归一化资金曲线对比
+// @Tags 回测
+// @Param ids query string true "回测记录ID,逗号分隔,至少2个"
+// @Success 200 {object} backtest.Comparison
+// @Router /api/backtest/runs/compare [get]
+func CompareBacktestRuns(c fbr.Ctx) {
+	var ids []int64
+	for _, s := range strings.Split(c.GetString("ids"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ids = append(ids, conv.Int64(s))
+		}
+	}
+	res, err := backtest.CompareRecord(ids)
+	c.CheckErr(err)
+	c.Succ(res)
+}
+
+// RerunBacktest
+// @Summary 重新运行回测
+// @Description 按回测记录的配置使用最新数据重新回测,保存为新的记录,
+// @Description 策略使用当前版本,changed为和原记录相比有变化的策略
+// @Tags 回测
+// @Param id path int true "id"
+// @Success 200 {object} rerunResp
+// @Router /api/backtest/{id}/rerun [post]
+func RerunBacktest(c fbr.Ctx) {
+	old, err := backtest.GetRecord(c.GetInt64("id"))
+	c.CheckErr(err)
+
+	now := time.Now()
+	var r *backtest.Record
+	switch old.Kind {
+	case backtest.KindSingle:
+		var req backtestReq
+		c.CheckErr(json.Unmarshal(old.Request, &req))
+		res, _, err := req.run()
+		c.CheckErr(err)
+		r = req.record(backtest.KindSingle)
+		r.SetResult(res)
+
+	case backtest.KindPortfolio:
+		var req portfolioReq
+		c.CheckErr(json.Unmarshal(old.Request, &req))
+		res, err := req.run()
+		c.CheckErr(err)
+		r = req.record()
+		r.SetPortfolio(res)
+
+	case backtest.KindAll:
+		var req backtestReq
+		c.CheckErr(json.Unmarshal(old.Request, &req))
+		all, err := req.all()
+		c.CheckErr(err)
+		_, r, err = all.run(nil)
+		c.CheckErr(err)
+
+	default:
+		c.Err(fmt.Sprintf("未知的回测类型[%s]", old.Kind))
+	}
+
+	r.Rerun = old.ID
+	saveRecord(r, now)
+	c.Succ(rerunResp{Record: r, Changed: changedVersions(old.Versions, r.Versions)})
+}
+
+// GetBacktestReport
+// @Summary 导出回测报告
+// @Description 导出回测记录,html:独立的HTML报告,包含资金曲线,回撤,指标,交易和策略源码,不依赖外部资源
+// @Description csv:zip压缩包,包含资金曲线,交易,完整交易和信号 json:全部数据
+// @Tags 回测
+// @Param id path int true "id"
+// @Param format query string false "格式 html(默认)/csv/json"
+// @Router /api/backtest/{id}/report [get]
+func GetBacktestReport(c fbr.Ctx) {
+	rec, err := backtest.GetRecord(c.GetInt64("id"))
+	c.CheckErr(err)
+	r := report.FromRecord(rec)
+
+	buf := bytes.NewBuffer(nil)
+	name := fmt.Sprintf("backtest-%d", rec.ID)
+	switch format := c.GetString("format", "html"); format {
+	case "html":
+		c.CheckErr(r.HTML(buf))
+		name += ".html"
+	case "csv":
+		c.CheckErr(r.CSV(buf))
+		name += ".zip"
+	case "json":
+		c.CheckErr(r.JSON(buf))
+		name += ".json"
+	default:
+		c.Err(fmt.Sprintf("未知的格式[%s],可选html/csv/json", format))
+	}
+	c.Attachment(name)
+	c.CheckErr(c.Send(buf.Bytes()))
+}
+
+// record 单只股票或全市场回测的记录,需要在回测之后调用,使用填充默认值后的配置
+func (this *backtestReq) record(kind string) *backtest.Record {
+	tree, names := this.Tree, this.Strategies
+	var codes []string
+	if kind == backtest.KindAll {
+		tree, names = nil, []string{this.Strategy}
+	} else {
+		codes = []string{this.Code}
+	}
+	return newRecord(kind, tree, names, this.Params, codes, this.Start, this.End, this.Cash, this)
+}
+
+// record 组合回测的记录,需要在回测之后调用,使用填充默认值后的配置
+func (this *portfolioReq) record() *backtest.Record {
+	return newRecord(backtest.KindPortfolio, this.Tree, this.Strategies, this.Params, this.Codes, this.Start, this.End, this.Cash, this)
+}
+
+func newRecord(kind string, tree *strategy.Node, names []string, params map[string]strategy.Params, codes []string, start, end string, cash float64, req any) *backtest.Record {
+	name := strings.Join(names, " and ")
+	if tree != nil {
+		name = tree.String()
+	}
+	r := &backtest.Record{
+		Kind:     kind,
+		Name:     name,
+		Codes:    codes,
+		Params:   params,
+		Versions: strategy.Versions(tree, names),
+		Start:    start,
+		End:      end,
+		Cash:     cash,
+	}
+	r.Request, _ = json.Marshal(req)
+	return r
+}
+
+// saveRecord 保存回测记录,失败只记录日志,不影响回测结果,返回记录ID
+func saveRecord(r *backtest.Record, start time.Time) int64 {
+	r.Elapsed = time.Since(start).Milliseconds()
+	if err := backtest.SaveRecord(r); err != nil {
+		logs.Err(err)
+		return 0
+	}
+	return r.ID
+}
+
+// changedVersions 和原记录相比版本有变化或已经不存在的策略
+func changedVersions(old, now []strategy.Version) []string {
+	m := map[string]strategy.Version{}
+	for _, v := range now {
+		m[v.Name] = v
+	}
+	var out []string
+	for _, v := range old {
+		n, ok := m[v.Name]
+		if !ok || n.Type != v.Type || n.Version != v.Version || n.Node != v.Node {
+			out = append(out, v.Name)
+		}
+	}
+	return out
+}
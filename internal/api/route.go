@@ -1,14 +1,20 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"mime"
+	"sync"
 	"time"
 
 	"github.com/injoyai/frame/fbr"
 	"github.com/injoyai/logs"
 	dist "github.com/injoyai/strategy"
+	"github.com/injoyai/strategy/internal/analysis"
 	"github.com/injoyai/strategy/internal/backtest"
 	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
 	"github.com/injoyai/strategy/internal/screener"
 	"github.com/injoyai/strategy/internal/strategy"
 	"github.com/injoyai/tdx/extend"
@@ -31,21 +37,101 @@ func Run(port int) error {
 		g.Group("/strategy", func(g fbr.Grouper) {
 			g.GET("/names", GetStrategyNames)
 			g.GET("/all", GetStrategyAll)
+			g.GET("/params", GetStrategyParams)
 			g.POST("/", PostStrategy)
 			g.PUT("/", PutStrategy)
 			g.PUT("/enable", PutStrategyEnable)
 			g.DELETE("/", DelStrategy)
+			g.GET("/composite", GetComposites)
+			g.POST("/composite", PostComposite)
+			g.DELETE("/composite", DelComposite)
 		})
 
 		g.Group("/stock", func(g fbr.Grouper) {
 			g.GET("/codes", GetCodes)
 			g.GET("/klines", GetKlines)
 			g.POST("/screener", GetScreener)
+			g.POST("/screener/history", GetScreenerHistory)
+			g.GET("/schedule", GetSchedules)
+			g.POST("/schedule", PostSchedule)
+			g.GET("/schedule/runs/:id", GetScheduleRun)
+			g.GET("/schedule/:id", GetSchedule)
+			g.PUT("/schedule/:id", PutSchedule)
+			g.PUT("/schedule/:id/enable", PutScheduleEnable)
+			g.DELETE("/schedule/:id", DelSchedule)
+			g.POST("/schedule/:id/run", RunSchedule)
+			g.GET("/schedule/:id/runs", GetScheduleRuns)
 		})
 
 		g.Group("/backtest", func(g fbr.Grouper) {
 			g.POST("/", Backtest)
+			g.POST("/portfolio", BacktestPortfolio)
+			g.POST("/montecarlo", BacktestMonteCarlo)
 			g.GET("/all/ws", BacktestAllWS)
+			g.GET("/runs", GetBacktestRuns)
+			g.GET("/runs/compare", CompareBacktestRuns)
+			g.GET("/:id", GetBacktestRun)
+			g.DELETE("/:id", DelBacktestRun)
+			g.POST("/:id/rerun", RerunBacktest)
+			g.GET("/:id/report", GetBacktestReport)
+		})
+
+		g.Group("/analysis", func(g fbr.Grouper) {
+			g.POST("/event", AnalysisEvent)
+		})
+
+		g.Group("/optimize", func(g fbr.Grouper) {
+			g.GET("/ws", OptimizeWS)
+			g.GET("/walkforward/ws", WalkForwardWS)
+			g.GET("/list", GetOptimizeList)
+			g.GET("/", GetOptimize)
+			g.GET("/heatmap", GetOptimizeHeatmap)
+			g.DELETE("/", DelOptimize)
+		})
+
+		g.Group("/watchlist", func(g fbr.Grouper) {
+			g.GET("/", GetWatchlists)
+			g.POST("/", PostWatchlist)
+			g.GET("/:id", GetWatchlist)
+			g.PUT("/:id", PutWatchlist)
+			g.DELETE("/:id", DelWatchlist)
+		})
+
+		g.Group("/alert", func(g fbr.Grouper) {
+			g.GET("/rules", GetAlertRules)
+			g.POST("/rules", PostAlertRule)
+			g.PUT("/rules/:id", PutAlertRule)
+			g.DELETE("/rules/:id", DelAlertRule)
+			g.POST("/evaluate", EvaluateAlerts)
+			g.GET("/history", GetAlertHistory)
+		})
+
+		g.Group("/paper", func(g fbr.Grouper) {
+			g.GET("/", GetPaperAccounts)
+			g.POST("/", PostPaperAccount)
+			g.GET("/:id", GetPaperAccount)
+			g.PUT("/:id", PutPaperAccount)
+			g.DELETE("/:id", DelPaperAccount)
+			g.PUT("/:id/enable", PutPaperAccountEnable)
+			g.POST("/:id/run", RunPaperAccount)
+			g.GET("/:id/positions", GetPaperPositions)
+			g.GET("/:id/orders", GetPaperOrders)
+			g.GET("/:id/equity", GetPaperEquity)
+		})
+
+		g.Group("/quote", func(g fbr.Grouper) {
+			g.GET("/subscriptions", GetQuoteSubscriptions)
+			g.POST("/subscriptions", PostQuoteSubscribe)
+			g.DELETE("/subscriptions/:code", DelQuoteSubscribe)
+			g.GET("/live", GetQuoteLives)
+			g.GET("/live/:code", GetQuoteLive)
+			g.POST("/poll", PollQuote)
+			g.GET("/klines", GetQuoteKlines)
+			g.POST("/signal", PostQuoteSignal)
+		})
+
+		g.Group("/notify", func(g fbr.Grouper) {
+			g.GET("/deliveries", GetDeliveries)
 		})
 
 	})
@@ -77,9 +163,13 @@ func GetCodes(c fbr.Ctx) {
 // @Param code query string true "股票代码例sz000001"
 // @Param start query string true "开始时间"
 // @Param end query string true "结束时间"
+// @Param adjust query string false "复权类型 qfq:前复权 hfq:后复权 空:不复权,只支持日线及以上周期"
+// @Param period query string false "周期 day(默认)/week/month/quarter/year/minute/5minute/15minute/30minute/60minute"
 // @Success 200 {array} protocol.Kline
 func GetKlines(c fbr.Ctx) {
 	code := c.GetString("code")
+	adjust := c.GetString("adjust")
+	period := c.GetString("period", extend.Day)
 	startStr := c.GetString("start", "1990-01-01")
 	endStr := c.GetString("end", time.Now().Format(time.DateOnly))
 
@@ -89,7 +179,20 @@ func GetKlines(c fbr.Ctx) {
 	end, err := time.Parse("2006-01-02", endStr)
 	c.CheckErr(err)
 
-	ks, err := common.Data.GetDayKlines(code, start, end)
+	var ks extend.Klines
+	switch {
+	case adjust == data.AdjustNone:
+		ks, err = common.Klines.GetKlines(code, period, start, end)
+	case period == extend.Day || period == extend.Week || period == extend.Month ||
+		period == extend.Quarter || period == extend.Year:
+		//复权后的日线再合成
+		ks, err = common.Klines.GetDayKlinesAdjust(code, start, end, adjust)
+		if err == nil {
+			ks, err = data.Resample(ks, period)
+		}
+	default:
+		err = errors.New("分钟线不支持复权")
+	}
 	c.CheckErr(err)
 
 	c.Succ(ks)
@@ -116,154 +219,440 @@ func GetScreener(c fbr.Ctx) {
 	c.Succ(items)
 }
 
+// GetScreenerHistory
+// @Summary 历史选股
+// @Description 在一段日期内的每个交易日,只用当天及之前的数据选股,统计选中股票之后1,5,10,20个交易日的收益(后复权)和命中率
+// @Tags 选股
+// @Param data body screener.HistoryRequest true "body"
+// @Success 200 {object} screener.HistoryResult
+// @Router /api/stock/screener/history [post]
+func GetScreenerHistory(c fbr.Ctx) {
+	var req screener.HistoryRequest
+	c.Parse(&req)
+
+	result, err := screener.RunHistory(req)
+	c.CheckErr(err)
+
+	c.Succ(result)
+}
+
+// AnalysisEvent
+// @Summary 事件研究
+// @Description 统计策略每次发出信号之后N个交易日的收益,最大有利/不利波动,收益分布,t统计量,并和全市场无条件收益对比
+// @Tags 分析
+// @Param data body analysis.EventRequest true "body"
+// @Success 200 {object} analysis.EventResult
+// @Router /api/analysis/event [post]
+func AnalysisEvent(c fbr.Ctx) {
+	var req analysis.EventRequest
+	c.Parse(&req)
+
+	result, err := analysis.RunEvent(req)
+	c.CheckErr(err)
+
+	c.Succ(result)
+}
+
 func Backtest(c fbr.Ctx) {
 
 	var req backtestReq
 	c.Parse(&req)
 
-	strat, err := strategy.Group(req.Strategies)
+	now := time.Now()
+	res, _, err := req.run()
 	c.CheckErr(err)
 
-	var start, end time.Time
-	if req.Start != "" {
-		start, err = time.Parse("2006-01-02", req.Start)
-		c.CheckErr(err)
-	}
-	if req.End != "" {
-		end, err = time.Parse("2006-01-02", req.End)
-		c.CheckErr(err)
-	}
+	r := req.record(backtest.KindSingle)
+	r.SetResult(res)
+	res.RunID = saveRecord(r, now)
 
-	dayKlines, err := common.Data.GetDayKlines(req.Code, start, end)
+	c.Succ(res)
+}
+
+// BacktestMonteCarlo
+// @Summary 蒙特卡洛模拟
+// @Description 单只股票回测后,对完整交易做有放回抽样或打乱顺序,随机跳过交易和增加滑点,
+// @Description 输出最终收益率和最大回撤的置信区间以及破产概率
+// @Tags 回测
+// @Param data body monteCarloReq true "body"
+// @Success 200 {object} backtest.MonteCarloResult
+// @Router /api/backtest/montecarlo [post]
+func BacktestMonteCarlo(c fbr.Ctx) {
+	var req monteCarloReq
+	c.Parse(&req)
+
+	res, settings, err := req.run()
 	c.CheckErr(err)
 
-	minKlines, err := common.Data.GetMinKlines(req.Code, start, end)
+	result, err := backtest.MonteCarlo(res, settings.Cash, req.MonteCarlo)
 	c.CheckErr(err)
 
-	cash := req.Cash
-	if cash <= 0 {
-		cash = 100000
+	c.Succ(result)
+}
+
+// run 单只股票回测
+func (this *backtestReq) run() (backtest.Result, backtest.Settings, error) {
+	strat, err := strategy.Build(this.Tree, this.Strategies, nil, this.Params)
+	if err != nil {
+		return backtest.Result{}, backtest.Settings{}, err
 	}
-	size := req.Size
-	if size <= 0 {
-		size = 1
+
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Now()
+	if this.Start != "" {
+		if start, err = time.Parse("2006-01-02", this.Start); err != nil {
+			return backtest.Result{}, backtest.Settings{}, err
+		}
+	}
+	if this.End != "" {
+		if end, err = time.Parse("2006-01-02", this.End); err != nil {
+			return backtest.Result{}, backtest.Settings{}, err
+		}
 	}
-	if req.FeeRate <= 0 {
-		req.FeeRate = 0.0005
+
+	dayKlines, err := common.Klines.GetDayKlinesAdjust(this.Code, start, end, this.Adjust)
+	if err != nil {
+		return backtest.Result{}, backtest.Settings{}, err
 	}
-	if req.MinFee <= 0 {
-		req.MinFee = 5
+
+	minKlines, err := common.Klines.GetMinKlines(this.Code, start, end)
+	if err != nil {
+		return backtest.Result{}, backtest.Settings{}, err
+	}
+
+	settings, err := this.settings(start, end)
+	if err != nil {
+		return backtest.Result{}, settings, err
 	}
 	res := backtest.RunBacktestAdvanced(
 		extend.Info{
-			Code:       "",
-			Name:       "",
-			Price:      0,
-			Turnover:   0,
-			FloatStock: 0,
-			TotalStock: 0,
-			FloatValue: 0,
-			TotalValue: 0,
-		},
-		dayKlines, minKlines, strat,
-		backtest.Settings{
-			Cash:       cash,
-			Size:       size,
-			FeeRate:    req.FeeRate,
-			MinFee:     req.MinFee,
-			Slippage:   req.Slippage,
-			StopLoss:   req.StopLoss,
-			TakeProfit: req.TakeProfit,
+			Code: this.Code,
+			Name: common.Data.Codes.GetName(this.Code),
 		},
+		dayKlines, minKlines, strat, settings,
 	)
+	return res, settings, nil
+}
+
+// settings 回测配置,填充默认值
+func (this *backtestReq) settings(start, end time.Time) (backtest.Settings, error) {
+	if this.Cash <= 0 {
+		this.Cash = 100000
+	}
+	if this.FeeRate <= 0 {
+		this.FeeRate = 0.0005
+	}
+	if this.MinFee <= 0 {
+		this.MinFee = 5
+	}
+	sizer, err := newSizer(this.Sizer, this.Size, this.FeeRate)
+	if err != nil {
+		return backtest.Settings{}, err
+	}
+	bench, err := loadBenchmark(this.Benchmark, start, end)
+	if err != nil {
+		return backtest.Settings{}, err
+	}
+	return backtest.Settings{
+		Cash:          this.Cash,
+		Size:          this.Size,
+		Sizer:         sizer,
+		FeeRate:       this.FeeRate,
+		MinFee:        this.MinFee,
+		Slippage:      this.Slippage,
+		StopLoss:      this.StopLoss,
+		TakeProfit:    this.TakeProfit,
+		Market:        this.Market,
+		RiskFree:      this.RiskFree,
+		Periods:       this.Periods,
+		Benchmark:     bench,
+		BenchmarkCode: this.Benchmark,
+	}, nil
+}
+
+// loadBenchmark 加载基准指数的日线,code为空时不加载,区间内没有K线时返回错误
+func loadBenchmark(code string, start, end time.Time) (extend.Klines, error) {
+	if code == "" {
+		return nil, nil
+	}
+	ks, err := common.Klines.GetIndexDayKlines(code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks) == 0 {
+		return nil, fmt.Errorf("基准指数[%s]在%s至%s之间没有K线", code, start.Format(time.DateOnly), end.Format(time.DateOnly))
+	}
+	return ks, nil
+}
+
+// newSizer 创建仓位模型,未配置时按固定股数,股数也未配置时全仓买入
+func newSizer(cfg *backtest.SizerConfig, size int, feeRate float64) (backtest.Sizer, error) {
+	switch {
+	case cfg != nil:
+		return backtest.NewSizer(*cfg, feeRate)
+	case size > 0:
+		return backtest.FixedShares{Shares: size}, nil
+	default:
+		return backtest.NewSizer(backtest.SizerConfig{Type: backtest.SizerPercent, Percent: 1}, feeRate)
+	}
+}
+
+// BacktestPortfolio
+// @Summary 组合回测
+// @Description 按交易日遍历股票池,共享资金,按评分买入并定期调仓
+// @Tags 回测
+// @Param data body portfolioReq true "body"
+// @Success 200 {object} backtest.PortfolioResult
+// @Router /api/backtest/portfolio [post]
+func BacktestPortfolio(c fbr.Ctx) {
+	var req portfolioReq
+	c.Parse(&req)
+
+	now := time.Now()
+	res, err := req.run()
+	c.CheckErr(err)
+
+	r := req.record()
+	r.SetPortfolio(res)
+	res.RunID = saveRecord(r, now)
 
 	c.Succ(res)
 }
 
+// run 组合回测
+func (this *portfolioReq) run() (*backtest.PortfolioResult, error) {
+	strat, err := strategy.Build(this.Tree, this.Strategies, nil, this.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Now()
+	if this.Start != "" {
+		if start, err = time.Parse("2006-01-02", this.Start); err != nil {
+			return nil, err
+		}
+	}
+	if this.End != "" {
+		if end, err = time.Parse("2006-01-02", this.End); err != nil {
+			return nil, err
+		}
+	}
+
+	universe, err := loadUniverse(this.Codes, start, end, this.Adjust)
+	if err != nil {
+		return nil, err
+	}
+
+	if this.Cash <= 0 {
+		this.Cash = 100000
+	}
+	if this.FeeRate <= 0 {
+		this.FeeRate = 0.0005
+	}
+	if this.MinFee <= 0 {
+		this.MinFee = 5
+	}
+	bench, err := loadBenchmark(this.Benchmark, start, end)
+	if err != nil {
+		return nil, err
+	}
+	res := backtest.RunPortfolio(universe, strat, backtest.PortfolioSettings{
+		Cash:          this.Cash,
+		MaxPositions:  this.MaxPositions,
+		MaxWeight:     this.MaxWeight,
+		Rebalance:     this.Rebalance,
+		FeeRate:       this.FeeRate,
+		MinFee:        this.MinFee,
+		Slippage:      this.Slippage,
+		Market:        this.Market,
+		RiskFree:      this.RiskFree,
+		Periods:       this.Periods,
+		Benchmark:     bench,
+		BenchmarkCode: this.Benchmark,
+	})
+	return &res, nil
+}
+
+// loadUniverse 加载股票池的K线,codes为空时加载全市场
+func loadUniverse(codes []string, start, end time.Time, adjust string) ([]backtest.Series, error) {
+	if len(codes) == 0 {
+		mu := sync.Mutex{}
+		ls := []backtest.Series(nil)
+		err := common.Klines.RangeKlinesAdjust(100, start, end, adjust, func(info extend.Info, day, min extend.Klines) {
+			mu.Lock()
+			defer mu.Unlock()
+			ls = append(ls, backtest.Series{Info: info, Day: day, Min: min})
+		})
+		return ls, err
+	}
+	ls := make([]backtest.Series, 0, len(codes))
+	for _, code := range codes {
+		day, err := common.Klines.GetDayKlinesAdjust(code, start, end, adjust)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, backtest.Series{
+			Info: extend.Info{Code: code, Name: common.Data.Codes.GetName(code)},
+			Day:  day,
+		})
+	}
+	return ls, nil
+}
+
 func BacktestAllWS(c fbr.Ctx) {
 
 	// 读取参数（query）
-	strategyName := c.GetString("strategy")
-	strat := strategy.Get(strategyName)
-	if strat == nil {
-		c.Err("strategy not found")
-	}
-
-	startStr := c.GetString("start")
-	endStr := c.GetString("end")
-	var start, end time.Time
-	var err2 error
-	if startStr != "" {
-		start, err2 = time.Parse("2006-01-02", startStr)
-		c.CheckErr(err2)
-	} else {
-		start = time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local)
-	}
-	if endStr != "" {
-		end, err2 = time.Parse("2006-01-02", endStr)
-		c.CheckErr(err2)
-	} else {
-		end = time.Now()
-	}
-
-	settings := backtest.Settings{
+	req := &backtestReq{
+		Strategy:   c.GetString("strategy"),
+		Start:      c.GetString("start"),
+		End:        c.GetString("end"),
 		Cash:       c.GetFloat64("cash", 100000),
-		Size:       c.GetInt("size", 1),
+		Size:       c.GetInt("size"),
 		FeeRate:    c.GetFloat64("fee_rate", 0.0005),
 		MinFee:     c.GetFloat64("min_fee", 5),
 		Slippage:   c.GetFloat64("slippage", 0),
 		StopLoss:   c.GetFloat64("stop_loss", 0),
 		TakeProfit: c.GetFloat64("take_profit", 0),
+		Market:     c.GetString("market"),
+		RiskFree:   c.GetFloat64("risk_free"),
+		Periods:    c.GetFloat64("periods"),
+		Benchmark:  c.GetString("benchmark"), //基准指数,例sh000300
+		Adjust:     c.GetString("adjust"),
+	}
+	if s := c.GetString("params"); s != "" {
+		//参数以JSON字符串传递,例 {"Window":10}
+		var params strategy.Params
+		c.CheckErr(json.Unmarshal([]byte(s), &params))
+		req.Params = map[string]strategy.Params{req.Strategy: params}
 	}
+	if s := c.GetString("sizer"); s != "" {
+		//仓位模型以JSON字符串传递,例 {"type":"percent","percent":0.5}
+		req.Sizer = new(backtest.SizerConfig)
+		c.CheckErr(json.Unmarshal([]byte(s), req.Sizer))
+	}
+	all, err := req.all()
+	c.CheckErr(err)
 
 	// WebSocket 接入（fasthttp）
 	c.Websocket(func(conn *fbr.Websocket) {
-
-		var sumRet, sumSharpe, sumDD float64
-		var cnt int
-
-		err := common.Data.RangeKlines(
-			100, start, end,
-			func(info extend.Info, day, min extend.Klines) {
-				res := backtest.RunBacktestAdvanced(info, day, min, strat, settings)
-				item := BacktestItem{
-					Code:        info.Code,
-					Name:        common.Data.Codes.GetName(info.Code),
-					Return:      res.Return,
-					MaxDrawdown: res.MaxDD,
-					Sharpe:      res.Sharpe,
-				}
-				sumRet += res.Return
-				sumSharpe += res.Sharpe
-				sumDD += res.MaxDD
-				cnt++
-				// 流式发送单条结果
-				_ = conn.WriteJSON(map[string]any{"type": "item", "item": item})
-			},
-		)
+		now := time.Now()
+		summary, r, err := all.run(func(item BacktestItem) {
+			// 流式发送单条结果
+			_ = conn.WriteJSON(map[string]any{"type": "item", "item": item})
+		})
 		if err != nil {
 			logs.Err(err)
 			return
 		}
-
-		var avgRet, avgSharpe, avgDD float64
-		if cnt > 0 {
-			avgRet = sumRet / float64(cnt)
-			avgSharpe = sumSharpe / float64(cnt)
-			avgDD = sumDD / float64(cnt)
-		}
 		// 发送汇总
-		_ = conn.WriteJSON(map[string]any{
-			"type":             "summary",
-			"avg_return":       avgRet,
-			"avg_sharpe":       avgSharpe,
-			"avg_max_drawdown": avgDD,
-			"count":            cnt,
-		})
+		summary["type"] = "summary"
+		summary["id"] = saveRecord(r, now)
+		_ = conn.WriteJSON(summary)
 	})
 
 }
 
+// allBacktest 全市场回测,每只股票单独回测
+type allBacktest struct {
+	req        *backtestReq
+	strat      strategy.Interface
+	start, end time.Time
+	settings   backtest.Settings
+}
+
+// all 全市场回测的准备,使用Strategy和它在Params中的参数
+func (this *backtestReq) all() (*allBacktest, error) {
+	strat, err := strategy.With(this.Strategy, this.Params[this.Strategy])
+	if err != nil {
+		return nil, err
+	}
+	if err = data.CheckAdjust(this.Adjust); err != nil {
+		return nil, err
+	}
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Now()
+	if this.Start != "" {
+		if start, err = time.Parse("2006-01-02", this.Start); err != nil {
+			return nil, err
+		}
+	}
+	if this.End != "" {
+		if end, err = time.Parse("2006-01-02", this.End); err != nil {
+			return nil, err
+		}
+	}
+	settings, err := this.settings(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &allBacktest{req: this, strat: strat, start: start, end: end, settings: settings}, nil
+}
+
+// run 逐只股票回测,each返回单只股票的结果,结束后返回汇总和回测记录,记录未保存
+func (this *allBacktest) run(each func(item BacktestItem)) (map[string]any, *backtest.Record, error) {
+	var sumRet, sumSharpe, sumDD float64
+	var cnt int
+	var bench benchmarkSum
+	var items []BacktestItem
+	mu := sync.Mutex{} //RangeKlines并发回调
+
+	err := common.Klines.RangeKlinesAdjust(
+		100, this.start, this.end, this.req.Adjust,
+		func(info extend.Info, day, min extend.Klines) {
+			res := backtest.RunBacktestAdvanced(info, day, min, this.strat, this.settings)
+			item := BacktestItem{
+				Code:        info.Code,
+				Name:        common.Data.Codes.GetName(info.Code),
+				Return:      res.Return,
+				MaxDrawdown: res.MaxDD,
+				Sharpe:      res.Sharpe,
+			}
+			if res.Benchmark != nil {
+				item.ExcessReturn = res.Benchmark.ExcessReturn
+				item.Alpha = res.Benchmark.Alpha
+				item.Beta = res.Benchmark.Beta
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			sumRet += res.Return
+			sumSharpe += res.Sharpe
+			sumDD += res.MaxDD
+			cnt++
+			bench.add(res.Benchmark)
+			items = append(items, item)
+			if each != nil {
+				each(item)
+			}
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var avgRet, avgSharpe, avgDD float64
+	if cnt > 0 {
+		avgRet = sumRet / float64(cnt)
+		avgSharpe = sumSharpe / float64(cnt)
+		avgDD = sumDD / float64(cnt)
+	}
+	summary := map[string]any{
+		"avg_return":       avgRet,
+		"avg_sharpe":       avgSharpe,
+		"avg_max_drawdown": avgDD,
+		"count":            cnt,
+	}
+	if this.settings.BenchmarkCode != "" {
+		summary["benchmark"] = bench.summary(this.settings.BenchmarkCode, this.settings.Benchmark)
+	}
+
+	r := this.req.record(backtest.KindAll)
+	r.Metrics = backtest.Metrics{Return: avgRet, Sharpe: avgSharpe, MaxDD: avgDD}
+	r.Count = cnt
+	r.Items, _ = json.Marshal(items)
+	return summary, r, nil
+}
+
 //func BacktestAll(c fbr.Ctx) {
 //	var req backtestReq
 //	c.Parse(&req)
@@ -315,7 +704,7 @@ func BacktestAllWS(c fbr.Ctx) {
 //	var sumRet, sumSharpe, sumDD float64
 //	var cnt int
 //	for _, code := range codes {
-//		ks, err := common.Data.GetDayKlines(code, start, end)
+//		ks, err := common.Klines.GetDayKlines(code, start, end)
 //		if err != nil || len(ks) == 0 {
 //			continue
 //		}
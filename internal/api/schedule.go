@@ -0,0 +1,138 @@
+package api
+
+import (
+	"github.com/injoyai/frame/fbr"
+	"github.com/injoyai/strategy/internal/notify"
+	"github.com/injoyai/strategy/internal/screener"
+)
+
+// GetSchedules
+// @Summary 定时选股列表
+// @Tags 选股
+// @Success 200 {array} screener.Schedule
+// @Router /api/stock/schedule [get]
+func GetSchedules(c fbr.Ctx) {
+	ls, err := screener.ListSchedule()
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetSchedule
+// @Summary 定时选股详情
+// @Tags 选股
+// @Param id path int true "id"
+// @Success 200 {object} screener.Schedule
+// @Router /api/stock/schedule/{id} [get]
+func GetSchedule(c fbr.Ctx) {
+	s, err := screener.GetSchedule(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(s)
+}
+
+// PostSchedule
+// @Summary 新建定时选股
+// @Description 保存选股条件,cron表达式和推送目标(mqtt/webhook/file),cron为空时在每天数据更新完成后执行,到点时当天数据还未更新则推迟到更新完成后执行
+// @Tags 选股
+// @Param data body screener.Schedule true "body"
+// @Success 200 {object} screener.Schedule
+// @Router /api/stock/schedule [post]
+func PostSchedule(c fbr.Ctx) {
+	var s screener.Schedule
+	c.Parse(&s)
+	s.ID = 0
+	c.CheckErr(screener.SaveSchedule(&s))
+	c.Succ(s)
+}
+
+// PutSchedule
+// @Summary 修改定时选股
+// @Tags 选股
+// @Param id path int true "id"
+// @Param data body screener.Schedule true "body"
+// @Success 200 {object} screener.Schedule
+// @Router /api/stock/schedule/{id} [put]
+func PutSchedule(c fbr.Ctx) {
+	var s screener.Schedule
+	c.Parse(&s)
+	s.ID = c.GetInt64("id")
+	c.CheckErr(screener.SaveSchedule(&s))
+	c.Succ(s)
+}
+
+// PutScheduleEnable
+// @Summary 启用或禁用定时选股
+// @Tags 选股
+// @Param id path int true "id"
+// @Param enable query bool true "是否启用"
+// @Router /api/stock/schedule/{id}/enable [put]
+func PutScheduleEnable(c fbr.Ctx) {
+	c.CheckErr(screener.EnableSchedule(c.GetInt64("id"), c.GetBool("enable")))
+	c.Succ(nil)
+}
+
+// DelSchedule
+// @Summary 删除定时选股
+// @Description 同时删除执行记录,推送日志保留
+// @Tags 选股
+// @Param id path int true "id"
+// @Router /api/stock/schedule/{id} [delete]
+func DelSchedule(c fbr.Ctx) {
+	c.CheckErr(screener.DeleteSchedule(c.GetInt64("id")))
+	c.Succ(nil)
+}
+
+// RunSchedule
+// @Summary 立即执行定时选股
+// @Description 立即选股并推送,返回选股结果和推送结果
+// @Tags 选股
+// @Param id path int true "id"
+// @Success 200 {object} screener.ScheduleRun
+// @Router /api/stock/schedule/{id}/run [post]
+func RunSchedule(c fbr.Ctx) {
+	r, err := screener.RunSchedule(c.GetInt64("id"), screener.TriggerManual)
+	c.CheckErr(err)
+	c.Succ(r)
+}
+
+// GetScheduleRuns
+// @Summary 定时选股执行记录
+// @Description 不包含选股结果,按时间倒序
+// @Tags 选股
+// @Param id path int true "定时选股id"
+// @Param limit query int false "数量,默认全部"
+// @Success 200 {array} screener.ScheduleRun
+// @Router /api/stock/schedule/{id}/runs [get]
+func GetScheduleRuns(c fbr.Ctx) {
+	ls, err := screener.ListScheduleRun(c.GetInt64("id"), c.GetInt("limit"))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
+
+// GetScheduleRun
+// @Summary 执行记录详情
+// @Description 包含推送的选股结果和推送日志
+// @Tags 选股
+// @Param id path int true "执行记录id"
+// @Success 200 {object} screener.ScheduleRun
+// @Router /api/stock/schedule/runs/{id} [get]
+func GetScheduleRun(c fbr.Ctx) {
+	r, err := screener.GetScheduleRun(c.GetInt64("id"))
+	c.CheckErr(err)
+	c.Succ(r)
+}
+
+// GetDeliveries
+// @Summary 推送日志
+// @Description 按时间倒序
+// @Tags 推送
+// @Param source query string false "来源 screen:定时选股"
+// @Param source_id query int false "来源id"
+// @Param run_id query int false "执行记录id"
+// @Param limit query int false "数量,默认100"
+// @Success 200 {array} notify.Delivery
+// @Router /api/notify/deliveries [get]
+func GetDeliveries(c fbr.Ctx) {
+	ls, err := notify.ListDelivery(c.GetString("source"), c.GetInt64("source_id"), c.GetInt64("run_id"), c.GetInt("limit", 100))
+	c.CheckErr(err)
+	c.Succ(ls)
+}
@@ -22,6 +22,30 @@ func GetStrategyNames(c fbr.Ctx) {
 	c.Succ(names)
 }
 
+// GetStrategyParams
+// @Summary 获取策略参数
+// @Description 获取策略的参数声明,不传name时返回全部策略
+// @Tags 策略
+// @Param name query string false "策略名称"
+// @Success 200 {array} strategy.Param
+func GetStrategyParams(c fbr.Ctx) {
+	name := c.GetString("name")
+	if name != "" {
+		s := strategy.Get(name)
+		if s == nil {
+			c.Err("策略不存在")
+		}
+		c.Succ(strategy.GetParams(s))
+	}
+	out := map[string][]strategy.Param{}
+	for _, name := range strategy.Names("all") {
+		if s := strategy.Get(name); s != nil {
+			out[name] = strategy.GetParams(s)
+		}
+	}
+	c.Succ(out)
+}
+
 // GetStrategyAll
 // @Summary 获取全部策略
 // @Description 获取全部策略
@@ -122,3 +146,57 @@ func DelStrategy(c fbr.Ctx) {
 	strategy.Del(name)
 	c.Succ(nil)
 }
+
+// GetComposites
+// @Summary 获取组合策略
+// @Description 获取保存的组合策略
+// @Tags 策略
+// @Success 200 {array} strategy.Composite
+func GetComposites(c fbr.Ctx) {
+	data := []*strategy.Composite(nil)
+	err := common.DB.Find(&data)
+	c.CheckErr(err)
+	c.Succ(data)
+}
+
+// PostComposite
+// @Summary 保存组合策略
+// @Description 新建或覆盖组合策略,保存后可以像普通策略一样按名称使用
+// @Tags 策略
+// @Param data body strategy.Composite true "body"
+// @Success 200
+func PostComposite(c fbr.Ctx) {
+	var req strategy.Composite
+	c.Parse(&req)
+
+	err := strategy.RegisterComposite(&req)
+	c.CheckErr(err)
+
+	has, err := common.DB.Where("Name=?", req.Name).Exist(new(strategy.Composite))
+	c.CheckErr(err)
+	if has {
+		_, err = common.DB.Where("Name=?", req.Name).AllCols().Update(&req)
+	} else {
+		_, err = common.DB.Insert(&req)
+	}
+	c.CheckErr(err)
+
+	c.Succ(req)
+}
+
+// DelComposite
+// @Summary 删除组合策略
+// @Description 被其它组合策略引用时不能删除
+// @Tags 策略
+// @Param Name query string true "名称"
+// @Success 200
+func DelComposite(c fbr.Ctx) {
+	name := c.GetString("Name")
+	if len(name) == 0 {
+		c.Succ(nil)
+	}
+	c.CheckErr(strategy.DelComposite(name))
+	_, err := common.DB.Where("Name=?", name).Delete(&strategy.Composite{})
+	c.CheckErr(err)
+	c.Succ(nil)
+}
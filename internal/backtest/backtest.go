@@ -1,7 +1,7 @@
 package backtest
 
 import (
-	"math"
+	"sort"
 	"time"
 
 	"github.com/injoyai/strategy/internal/strategy"
@@ -9,11 +9,14 @@ import (
 )
 
 type Trade struct {
-	Time  int64   `json:"time"`
-	Index int     `json:"index"`
-	Price float64 `json:"price"`
-	Side  string  `json:"side"`
-	Qty   int     `json:"qty"`
+	Time   int64   `json:"time"`
+	Index  int     `json:"index"`
+	Code   string  `json:"code,omitempty"` //股票代码,组合回测有效
+	Price  float64 `json:"price"`
+	Side   string  `json:"side"`
+	Qty    int     `json:"qty"`
+	Fee    float64 `json:"fee"`    //手续费和税费
+	Reason string  `json:"reason"` //触发原因,信号/止损/止盈或策略给出的说明
 }
 
 type Result struct {
@@ -30,12 +33,20 @@ type Result struct {
 	Return float64 `json:"return"`
 	// MaxDD 最大回撤比例（期间总资产相对峰值的最大下跌比例）
 	MaxDD float64 `json:"max_drawdown"`
-	// Sharpe 夏普比率（以日收益率序列计算：mean/StdDev * sqrt(252)）
+	// Sharpe 夏普比率（扣除无风险利率，样本标准差，按K线周期年化）
 	Sharpe float64 `json:"sharpe"`
+	// Metrics 完整的绩效指标
+	Metrics Metrics `json:"metrics"`
+	// RoundTrips 买入和卖出配对后的每笔交易盈亏
+	RoundTrips []RoundTrip `json:"round_trips"`
+	// Benchmark 相对基准的表现,未设置基准时为空
+	Benchmark *Benchmark `json:"benchmark,omitempty"`
 	// Klines K线数据
 	Klines interface{} `json:"klines"`
 	// Signals 策略信号序列 (1: Buy, 0: None, -1: Sell)
 	Signals []int `json:"signals"`
+	// RunID 保存的回测记录ID,未保存时为0
+	RunID int64 `json:"run_id,omitempty"`
 }
 
 type Settings struct {
@@ -46,6 +57,14 @@ type Settings struct {
 	Slippage   float64
 	StopLoss   float64
 	TakeProfit float64
+	Market     string  //交易规则,MarketNone不限制,MarketCN使用A股规则
+	Sizer      Sizer   //仓位模型,为空时按Size固定股数买入
+	RiskFree   float64 //年化无风险利率,用于夏普和索提诺比率
+	Periods    float64 //每年的K线数量,用于年化,0表示按K线间隔自动推断
+	Start      int64   //开始交易的时间(秒级时间戳),之前的K线只推送给策略用于指标预热,不交易也不计入资金曲线
+	// Benchmark 基准K线(一般是指数),为空时不计算相对基准的表现
+	Benchmark     extend.Klines
+	BenchmarkCode string
 }
 
 type Candle struct {
@@ -62,154 +81,174 @@ func RunBacktestAdvanced(info extend.Info, day, min extend.Klines, strat strateg
 	ks := day
 	if len(day) == 0 && len(min) == 0 {
 		return Result{
-			Equity:   []float64{},
-			Cash:     []float64{},
-			Position: []int{},
-			Trades:   []Trade{},
-			Return:   0,
-			MaxDD:    0,
-			Sharpe:   0,
+			Equity:     []float64{},
+			Cash:       []float64{},
+			Position:   []int{},
+			Trades:     []Trade{},
+			RoundTrips: []RoundTrip{},
+			Return:     0,
+			MaxDD:      0,
+			Sharpe:     0,
 		}
 	}
 
-	sigs := strat.Signal(info, day, min)
-	_ = sigs
-	n := len(day)
+	//流式计算,每根K线只推送一次,未实现Streamer的策略会退化成前缀切片
+	st := strategy.NewStream(strat, info, min)
+	var last float64 //前收盘价
+	//开始时间之前的K线只用于策略预热
+	w := 0
+	if cfg.Start > 0 {
+		w = sort.Search(len(day), func(i int) bool { return day[i].Unix >= cfg.Start })
+		for _, k := range day[:w] {
+			st.OnBar(k)
+			last = k.Close.Float64()
+		}
+		ks = day[w:]
+	}
+	n := len(ks)
 	equity := make([]float64, n)
 	cashSeries := make([]float64, n)
 	posSeries := make([]int, n)
 	var pos int
 	var eq float64 = cfg.Cash
 	trades := make([]Trade, 0, 64)
-	var peak float64
-	rets := make([]float64, 0, n)
+	times := make([]int64, n)
+	exposed := make([]bool, n)
 	signals := make([]int, n)
 	var entry float64
+	rules := GetRules(cfg.Market, info.Code)
+	var buyTime int64    //最近一次买入的时间,用于T+1
+	var pending string   //被规则挡住的卖出原因,后续K线继续尝试卖出
+	var adds int         //加仓次数
+	var lastBuy float64  //最近一次买入价
+	var closed []float64 //已平仓交易的收益率
+	sizer := cfg.Sizer
+	if sizer == nil {
+		sizer = FixedShares{Shares: cfg.Size}
+	}
+	lot := 0
+	if rules != nil {
+		lot = rules.Lot(info)
+	}
+	ctx := func(i int, px float64) SizeContext {
+		return SizeContext{
+			Info:    info,
+			Day:     day[:w+i+1],
+			Price:   px,
+			Equity:  eq + float64(pos)*ks[i].Close.Float64(),
+			Cash:    eq,
+			Qty:     pos,
+			Adds:    adds,
+			Last:    lastBuy,
+			Lot:     lot,
+			Returns: closed,
+		}
+	}
+	sell := func(i int, px float64, reason string) {
+		proceeds := px * float64(pos)
+		f := cfg.Fee(rules, "sell", proceeds)
+		eq += proceeds - f
+		closed = append(closed, (px-entry)/entry)
+		trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: px, Side: "sell", Qty: pos, Fee: f, Reason: reason})
+		pos = 0
+		entry = 0
+		adds = 0
+		lastBuy = 0
+		pending = ""
+	}
 	for i := 0; i < n; i++ {
 		price := ks[i].Close.Float64()
-		buyPx := price * (1 + cfg.Slippage)
-		sellPx := price * (1 - cfg.Slippage)
-		s := 0
-		if strat.Signal(info, ks[:i+1], min) {
-			s = 1
+		if ks[i].Last > 0 {
+			last = ks[i].Last.Float64()
 		}
+
+		//按交易规则判断当前K线能否买入/卖出
+		fill := cfg.Fill(rules, info, ks[i], last, buyTime)
+		buyPx, sellPx := fill.BuyPrice, fill.SellPrice
+		trySell := func(reason string) {
+			if !fill.CanSell() {
+				pending = reason
+				return
+			}
+			sell(i, sellPx, reason)
+		}
+
+		d := st.OnBar(ks[i])
+		s := int(d.Action)
 		signals[i] = s
-		if s == 1 && pos == 0 {
-			cost := buyPx * float64(cfg.Size)
-			fee := cost * cfg.FeeRate
-			if fee < cfg.MinFee {
-				fee = cfg.MinFee
+		bought := false
+		if s == 1 && fill.CanBuy() {
+			var size int
+			if d.Weight > 0 && pos == 0 {
+				//策略给出了目标仓位,按当前总资产折算数量
+				size = PercentEquity{Percent: d.Weight, FeeRate: cfg.FeeRate}.Size(ctx(i, buyPx))
+			} else {
+				size = sizer.Size(ctx(i, buyPx))
 			}
-			if eq >= cost+fee {
-				eq -= cost + fee
-				pos += cfg.Size
-				entry = buyPx
-				trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: buyPx, Side: "buy", Qty: cfg.Size})
+			if lot > 0 {
+				size = size / lot * lot
 			}
-		} else if s == -1 && pos > 0 {
-			proceeds := sellPx * float64(pos)
-			fee := proceeds * cfg.FeeRate
-			if fee < cfg.MinFee {
-				fee = cfg.MinFee
+			cost := buyPx * float64(size)
+			f := cfg.Fee(rules, "buy", cost)
+			if size > 0 && eq >= cost+f {
+				eq -= cost + f
+				if pos > 0 {
+					adds++
+				}
+				entry = (entry*float64(pos) + cost) / float64(pos+size)
+				pos += size
+				lastBuy = buyPx
+				buyTime = ks[i].Unix
+				bought = true
+				trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: buyPx, Side: "buy", Qty: size, Fee: f, Reason: d.ReasonOr("signal")})
+			}
+		}
+		if s == -1 && pos > 0 {
+			trySell(d.ReasonOr("signal"))
+		} else if pos > 0 && !bought {
+			if pending != "" {
+				trySell(pending)
 			}
-			eq += proceeds - fee
-			trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: sellPx, Side: "sell", Qty: pos})
-			pos = 0
-			entry = 0
-		} else if pos > 0 {
 			if cfg.StopLoss > 0 && entry > 0 {
 				r := (sellPx - entry) / entry
 				if r <= -cfg.StopLoss {
-					proceeds := sellPx * float64(pos)
-					fee := proceeds * cfg.FeeRate
-					if fee < cfg.MinFee {
-						fee = cfg.MinFee
-					}
-					eq += proceeds - fee
-					trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: sellPx, Side: "sell", Qty: pos})
-					pos = 0
-					entry = 0
+					trySell("stop_loss")
 				}
 			}
 			if cfg.TakeProfit > 0 && entry > 0 && pos > 0 {
 				r := (sellPx - entry) / entry
 				if r >= cfg.TakeProfit {
-					proceeds := sellPx * float64(pos)
-					fee := proceeds * cfg.FeeRate
-					if fee < cfg.MinFee {
-						fee = cfg.MinFee
-					}
-					eq += proceeds - fee
-					trades = append(trades, Trade{Time: ks[i].Time.Unix(), Index: i, Price: sellPx, Side: "sell", Qty: pos})
-					pos = 0
-					entry = 0
+					trySell("take_profit")
 				}
 			}
 		}
+		last = price
 		mtm := eq + float64(pos)*price
 		equity[i] = mtm
 		cashSeries[i] = eq
 		posSeries[i] = pos
-		if i > 0 {
-			rets = append(rets, (equity[i]-equity[i-1])/equity[i-1])
-		}
-		if mtm > peak {
-			peak = mtm
-		}
+		times[i] = ks[i].Time.Unix()
+		exposed[i] = pos > 0
 	}
-	var totalRet float64
-	if n > 0 && cfg.Cash > 0 {
-		totalRet = (equity[n-1] - cfg.Cash) / cfg.Cash
+	trips := PairTrades(trades)
+	mc := MetricsConfig{RiskFree: cfg.RiskFree, Periods: cfg.Periods}
+	m := Analyze(times, equity, exposed, trips, cfg.Cash, mc)
+	var bench *Benchmark
+	if len(cfg.Benchmark) > 0 {
+		bench = CompareBenchmark(cfg.BenchmarkCode, times, equity, cfg.Cash, cfg.Benchmark, mc)
 	}
-	maxDD := drawdown(equity)
-	sharpe := sharpeRatio(rets)
 	return Result{
-		Equity:   equity,
-		Cash:     cashSeries,
-		Position: posSeries,
-		Trades:   trades,
-		Return:   totalRet,
-		MaxDD:    maxDD,
-		Sharpe:   sharpe,
-		Klines:   ks,
-		Signals:  signals,
-	}
-}
-
-func drawdown(eq []float64) float64 {
-	var peak float64
-	var maxdd float64
-	for _, v := range eq {
-		if v > peak {
-			peak = v
-		}
-		if peak > 0 {
-			dd := (peak - v) / peak
-			if dd > maxdd {
-				maxdd = dd
-			}
-		}
-	}
-	return maxdd
-}
-
-func sharpeRatio(xs []float64) float64 {
-	if len(xs) == 0 {
-		return 0
-	}
-	var mean float64
-	for _, v := range xs {
-		mean += v
-	}
-	mean /= float64(len(xs))
-	var sd float64
-	for _, v := range xs {
-		d := v - mean
-		sd += d * d
-	}
-	sd = math.Sqrt(sd / float64(len(xs)))
-	if sd == 0 {
-		return 0
+		Equity:     equity,
+		Cash:       cashSeries,
+		Position:   posSeries,
+		Trades:     trades,
+		Return:     m.Return,
+		MaxDD:      m.MaxDD,
+		Sharpe:     m.Sharpe,
+		Metrics:    m,
+		RoundTrips: trips,
+		Benchmark:  bench,
+		Klines:     ks,
+		Signals:    signals,
 	}
-	return mean / sd * math.Sqrt(252)
 }
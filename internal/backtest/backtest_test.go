@@ -0,0 +1,193 @@
+package backtest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/injoyai/goutil/database/sqlite"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// streamers 实现了流式计算的策略
+var streamers = []string{"MACD金叉死叉", "多头排列(5,10,20,30)", "连涨3天(收盘价)"}
+
+// legacy 隐藏策略的NewStream,让回测引擎退化成前缀切片
+type legacy struct {
+	strategy.Interface
+}
+
+func (this legacy) Decide(info extend.Info, day, min extend.Klines) strategy.Decision {
+	return strategy.Decide(this.Interface, info, day, min)
+}
+
+// randKlines 随机游走生成日K线
+func randKlines(seed int64, n int) extend.Klines {
+	r := rand.New(rand.NewSource(seed))
+	ks := make(extend.Klines, n)
+	t := time.Date(2000, 1, 1, 15, 0, 0, 0, time.Local)
+	last := 10.0
+	for i := range ks {
+		open := last * (1 + r.NormFloat64()*0.01)
+		cls := open * (1 + r.NormFloat64()*0.02)
+		high := max(open, cls) * (1 + r.Float64()*0.01)
+		low := min(open, cls) * (1 - r.Float64()*0.01)
+		vol := int64(1e5 + r.Intn(1e6))
+		ks[i] = &extend.Kline{
+			Unix: t.Unix(),
+			Kline: &protocol.Kline{
+				Last:   protocol.Price(last * 1000),
+				Open:   protocol.Price(open * 1000),
+				High:   protocol.Price(high * 1000),
+				Low:    protocol.Price(low * 1000),
+				Close:  protocol.Price(cls * 1000),
+				Volume: vol,
+				Amount: protocol.Price(float64(vol) * cls * 1000),
+				Time:   t,
+			},
+		}
+		last = cls
+		t = t.AddDate(0, 0, 1)
+	}
+	return ks
+}
+
+func getStreamer(t testing.TB, name string) strategy.Interface {
+	s := strategy.Get(name)
+	if s == nil {
+		t.Fatalf("策略[%s]不存在", name)
+	}
+	if _, ok := s.(strategy.Streamer); !ok {
+		t.Fatalf("策略[%s]未实现流式计算", name)
+	}
+	return s
+}
+
+// TestStreamMatchesPrefix 流式计算和前缀切片的回测结果需要完全一致
+func TestStreamMatchesPrefix(t *testing.T) {
+	info := extend.Info{Code: "sz000001"}
+	ks := randKlines(1, 600)
+	for _, cfg := range []Settings{
+		{Cash: 100000, Size: 100, FeeRate: 0.0003, MinFee: 5},
+		{Cash: 100000, Size: 100, FeeRate: 0.0003, MinFee: 5, Slippage: 0.001, StopLoss: 0.05, TakeProfit: 0.1, Market: MarketCN},
+		{Cash: 100000, Size: 100, FeeRate: 0.0003, MinFee: 5, Start: ks[200].Unix},
+	} {
+		for _, name := range streamers {
+			s := getStreamer(t, name)
+			stream := RunBacktestAdvanced(info, ks, nil, s, cfg)
+			prefix := RunBacktestAdvanced(info, ks, nil, legacy{s}, cfg)
+			if len(stream.Signals) == 0 {
+				t.Fatalf("[%s] 没有信号", name)
+			}
+			if !reflect.DeepEqual(stream.Signals, prefix.Signals) {
+				t.Errorf("[%s] 信号不一致", name)
+			}
+			if !reflect.DeepEqual(stream.Trades, prefix.Trades) {
+				t.Errorf("[%s] 交易不一致: %d != %d", name, len(stream.Trades), len(prefix.Trades))
+			}
+			if !reflect.DeepEqual(stream.Equity, prefix.Equity) {
+				t.Errorf("[%s] 资金曲线不一致", name)
+			}
+		}
+	}
+}
+
+func benchmarkRunBacktest(b *testing.B, wrap func(s strategy.Interface) strategy.Interface) {
+	info := extend.Info{Code: "sz000001"}
+	ks := randKlines(1, 2000)
+	cfg := Settings{Cash: 100000, Size: 100, FeeRate: 0.0003, MinFee: 5}
+	for _, name := range streamers {
+		s := wrap(getStreamer(b, name))
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				RunBacktestAdvanced(info, ks, nil, s, cfg)
+			}
+		})
+	}
+}
+
+// BenchmarkRunBacktestPrefix 前缀切片,每根K线都用截至当前的全部历史调用策略,复杂度O(n²)
+func BenchmarkRunBacktestPrefix(b *testing.B) {
+	benchmarkRunBacktest(b, func(s strategy.Interface) strategy.Interface { return legacy{s} })
+}
+
+// BenchmarkRunBacktestStream 流式计算,每根K线只推送一次,复杂度O(n)
+func BenchmarkRunBacktestStream(b *testing.B) {
+	benchmarkRunBacktest(b, func(s strategy.Interface) strategy.Interface { return s })
+}
+
+// fakeCodes 股票名称都为空
+type fakeCodes struct {
+	tdx.ICodes
+}
+
+func (fakeCodes) GetName(code string) string { return "" }
+
+// fixtureStore 在临时目录生成多只股票的日线数据库,和全市场回测一样通过RangeKlines读取
+func fixtureStore(b *testing.B, codes, n int) *data.Data {
+	d := &data.Data{DatabaseDir: b.TempDir(), MaxOpen: 16, Manage: &tdx.Manage{Codes: fakeCodes{}}}
+	if err := os.MkdirAll(d.KlineDir(), 0o755); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < codes; i++ {
+		db, err := sqlite.NewXorm(filepath.Join(d.KlineDir(), fmt.Sprintf("sz%06d.db", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = db.Sync2(extend.NewKlineTable("DayKline", nil)); err != nil {
+			b.Fatal(err)
+		}
+		ks := randKlines(int64(i+1), n)
+		for j := 0; j < len(ks); j += 200 {
+			if _, err = db.Table("DayKline").Insert(ks[j:min(j+200, len(ks))]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		db.Close()
+	}
+	b.Cleanup(d.Invalidate)
+	return d
+}
+
+func benchmarkRangeBacktest(b *testing.B, wrap func(s strategy.Interface) strategy.Interface) {
+	d := fixtureStore(b, 20, 1000)
+	cfg := Settings{Cash: 100000, Size: 100, FeeRate: 0.0003, MinFee: 5}
+	//不开启缓存时读取数据库的耗时占大部分,开启缓存后主要是策略计算
+	for _, cache := range []bool{false, true} {
+		d.Cache = cache
+		if err := d.WarmCache(); err != nil {
+			b.Fatal(err)
+		}
+		for _, name := range streamers {
+			s := wrap(getStreamer(b, name))
+			b.Run(fmt.Sprintf("缓存:%v/%s", cache, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					err := d.RangeKlines(10, time.Time{}, time.Now(), func(info extend.Info, day, min extend.Klines) {
+						RunBacktestAdvanced(info, day, min, s, cfg)
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRangeBacktestPrefix 全市场回测,20只股票各1000根日线,前缀切片
+func BenchmarkRangeBacktestPrefix(b *testing.B) {
+	benchmarkRangeBacktest(b, func(s strategy.Interface) strategy.Interface { return legacy{s} })
+}
+
+// BenchmarkRangeBacktestStream 全市场回测,20只股票各1000根日线,流式计算
+func BenchmarkRangeBacktestStream(b *testing.B) {
+	benchmarkRangeBacktest(b, func(s strategy.Interface) strategy.Interface { return s })
+}
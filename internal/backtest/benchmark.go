@@ -0,0 +1,127 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/tdx/extend"
+)
+
+// Benchmark 相对基准(一般是指数)的表现
+type Benchmark struct {
+	Code             string    `json:"code"`              //基准代码,例sh000300
+	Equity           []float64 `json:"equity"`            //基准资金曲线,和策略使用相同的初始资金,按策略的K线时间对齐
+	Return           float64   `json:"return"`            //基准总收益率
+	ExcessReturn     float64   `json:"excess_return"`     //超额收益率,策略总收益率-基准总收益率,基准晚于策略开始时从基准的第一根K线开始计算
+	Alpha            float64   `json:"alpha"`             //年化Alpha
+	Beta             float64   `json:"beta"`              //Beta
+	TrackingError    float64   `json:"tracking_error"`    //年化跟踪误差
+	InformationRatio float64   `json:"information_ratio"` //信息比率,年化超额收益/跟踪误差
+	UpCapture        float64   `json:"up_capture"`        //上行捕获率,基准上涨时策略平均收益/基准平均收益
+	DownCapture      float64   `json:"down_capture"`      //下行捕获率,基准下跌时策略平均收益/基准平均收益
+}
+
+// CompareBenchmark 计算策略相对基准的表现,基准按交易日对齐,缺失的交易日沿用前一个收盘价
+func CompareBenchmark(code string, times []int64, equity []float64, cash float64, bench extend.Klines, cfg MetricsConfig) *Benchmark {
+	b := &Benchmark{Code: code, Equity: make([]float64, len(times))}
+	if len(times) == 0 || len(bench) == 0 || cash <= 0 {
+		return b
+	}
+	periods := cfg.Periods
+	if periods <= 0 {
+		periods = periodsPerYear(times)
+	}
+
+	//对齐基准收盘价
+	closes := make([]float64, len(times))
+	j := 0
+	var last float64
+	for i, t := range times {
+		day := data.DayStart(time.Unix(t, 0))
+		for j < len(bench) && data.DayStart(bench[j].Time) <= day {
+			last = bench[j].Close.Float64()
+			j++
+		}
+		closes[i] = last
+	}
+	//策略开始时基准还没有数据,从基准第一根K线开始计算
+	first := 0
+	for first < len(closes) && closes[first] <= 0 {
+		first++
+	}
+	if first == len(closes) {
+		return b
+	}
+	for i := range times {
+		if i < first {
+			b.Equity[i] = cash
+			continue
+		}
+		b.Equity[i] = cash * closes[i] / closes[first]
+	}
+	n := len(times)
+	b.Return = b.Equity[n-1]/cash - 1
+	//策略收益和基准使用相同的区间
+	start := cash
+	if first > 0 && equity[first] > 0 {
+		start = equity[first]
+	}
+	b.ExcessReturn = (equity[n-1]/start - 1) - b.Return
+
+	//逐根K线的收益率
+	var rs, rb []float64
+	for i := first + 1; i < n; i++ {
+		if equity[i-1] <= 0 || b.Equity[i-1] <= 0 {
+			continue
+		}
+		rs = append(rs, equity[i]/equity[i-1]-1)
+		rb = append(rb, b.Equity[i]/b.Equity[i-1]-1)
+	}
+	if len(rs) < 2 {
+		return b
+	}
+	rf := math.Pow(1+cfg.RiskFree, 1/periods) - 1
+	ms, mb := indicator.Mean(rs), indicator.Mean(rb)
+	var cov, varb float64
+	diff := make([]float64, len(rs))
+	var upS, upB, downS, downB float64
+	var ups, downs int
+	for i := range rs {
+		cov += (rs[i] - ms) * (rb[i] - mb)
+		varb += (rb[i] - mb) * (rb[i] - mb)
+		diff[i] = rs[i] - rb[i]
+		switch {
+		case rb[i] > 0:
+			upS += rs[i]
+			upB += rb[i]
+			ups++
+		case rb[i] < 0:
+			downS += rs[i]
+			downB += rb[i]
+			downs++
+		}
+	}
+	if varb > 0 {
+		b.Beta = cov / varb
+	}
+	b.Alpha = ((ms - rf) - b.Beta*(mb-rf)) * periods
+	md := indicator.Mean(diff)
+	var sd float64
+	for _, d := range diff {
+		sd += (d - md) * (d - md)
+	}
+	sd = math.Sqrt(sd / float64(len(diff)-1))
+	b.TrackingError = sd * math.Sqrt(periods)
+	if b.TrackingError > 0 {
+		b.InformationRatio = md * periods / b.TrackingError
+	}
+	if ups > 0 && upB != 0 {
+		b.UpCapture = upS / upB
+	}
+	if downs > 0 && downB != 0 {
+		b.DownCapture = downS / downB
+	}
+	return b
+}
@@ -0,0 +1,40 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareBenchmark(t *testing.T) {
+	ks := makeKlines(10, bar{10, 0}, bar{10, 0}, bar{11, 0}, bar{12, 0})
+	times := make([]int64, len(ks))
+	for i, k := range ks {
+		times[i] = k.Unix
+	}
+	equity := []float64{1000, 800, 900, 1000}
+	for _, c := range []struct {
+		name       string
+		from       int //基准从第几根K线开始有数据
+		wantEquity []float64
+		wantReturn float64
+		wantExcess float64
+	}{
+		{"基准和策略同时开始", 0, []float64{1000, 1000, 1100, 1200}, 0.2, 0 - 0.2},
+		{"基准晚于策略开始", 1, []float64{1000, 1000, 1100, 1200}, 0.2, 0.25 - 0.2},
+		{"策略收益从基准第一根K线开始", 2, []float64{1000, 1000, 1000, 12000.0 / 11}, 1.0 / 11, 1.0/9 - 1.0/11},
+	} {
+		b := CompareBenchmark("sh000300", times, equity, 1000, ks[c.from:], MetricsConfig{})
+		for i := range c.wantEquity {
+			if math.Abs(b.Equity[i]-c.wantEquity[i]) > 1e-9 {
+				t.Fatalf("[%s] 基准资金曲线 %v, 期望 %v", c.name, b.Equity, c.wantEquity)
+			}
+		}
+		if math.Abs(b.Return-c.wantReturn) > 1e-9 || math.Abs(b.ExcessReturn-c.wantExcess) > 1e-9 {
+			t.Errorf("[%s] 基准收益 %v, 超额收益 %v, 期望 %v %v", c.name, b.Return, b.ExcessReturn, c.wantReturn, c.wantExcess)
+		}
+	}
+
+	if b := CompareBenchmark("sh000300", times, equity, 1000, nil, MetricsConfig{}); b.Return != 0 || b.ExcessReturn != 0 {
+		t.Errorf("没有基准数据 %+v", b)
+	}
+}
@@ -0,0 +1,65 @@
+package backtest
+
+import (
+	"math"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/tdx/extend"
+)
+
+// Fee 成交额为amount时的手续费和税费,佣金不低于MinFee,rules为空时只收佣金
+func (this Settings) Fee(rules Rules, side string, amount float64) float64 {
+	f := math.Max(amount*this.FeeRate, this.MinFee)
+	if rules != nil {
+		f += rules.Tax(side, amount)
+	}
+	return f
+}
+
+// Fill 按收盘价成交时能否买入和卖出,以及加上滑点后的成交价
+type Fill struct {
+	BuyPrice  float64 //买入价,收盘价加滑点,不超过涨停价
+	SellPrice float64 //卖出价,收盘价减滑点,不低于跌停价
+	NoBuy     string  //不能买入的原因,停牌/涨停,为空时可以买入
+	NoSell    string  //不能卖出的原因,停牌/跌停/T+1,为空时可以卖出
+}
+
+// CanBuy 是否可以买入
+func (this Fill) CanBuy() bool { return this.NoBuy == "" }
+
+// CanSell 是否可以卖出
+func (this Fill) CanSell() bool { return this.NoSell == "" }
+
+// Fill 按交易规则判断K线收盘时能否成交,收盘涨停买不进,收盘跌停卖不出,滑点不能超过涨跌停价,
+// last为前收盘价,buyTime为最近一次买入的时间戳,用于T+1,rules为空时不限制
+func (this Settings) Fill(rules Rules, info extend.Info, k *extend.Kline, last float64, buyTime int64) Fill {
+	price := k.Close.Float64()
+	f := Fill{
+		BuyPrice:  price * (1 + this.Slippage),
+		SellPrice: price * (1 - this.Slippage),
+	}
+	if rules == nil {
+		return f
+	}
+	up, down := rules.Limit(info, last)
+	if up > 0 {
+		f.BuyPrice = math.Min(f.BuyPrice, up)
+	}
+	if down > 0 {
+		f.SellPrice = math.Max(f.SellPrice, down)
+	}
+	switch {
+	case rules.Suspended(k):
+		f.NoBuy, f.NoSell = "停牌", "停牌"
+		return f
+	case up > 0 && price >= up-1e-6:
+		f.NoBuy = "涨停"
+	}
+	switch {
+	case down > 0 && price <= down+1e-6:
+		f.NoSell = "跌停"
+	case rules.T1() && data.SameDay(buyTime, k.Unix):
+		f.NoSell = "T+1"
+	}
+	return f
+}
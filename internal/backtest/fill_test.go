@@ -0,0 +1,64 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/injoyai/tdx/extend"
+)
+
+func TestSettingsFee(t *testing.T) {
+	cfg := Settings{FeeRate: 0.0003, MinFee: 5}
+	rules := GetRules(MarketCN, "sz000001")
+	for _, c := range []struct {
+		name   string
+		rules  Rules
+		side   string
+		amount float64
+		want   float64
+	}{
+		{"最低佣金", nil, "buy", 10000, 5},
+		{"按费率", nil, "sell", 100000, 30},
+		{"买入过户费", rules, "buy", 100000, 30 + 1},
+		{"卖出印花税", rules, "sell", 100000, 30 + 1 + 50},
+	} {
+		if got := cfg.Fee(c.rules, c.side, c.amount); !near(got, c.want) {
+			t.Errorf("[%s] %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSettingsFill(t *testing.T) {
+	cfg := Settings{Slippage: 0.01}
+	rules := GetRules(MarketCN, "sz000001")
+	info := extend.Info{Code: "sz000001"}
+	//前收盘价都是10
+	k := makeKlines(10, bar{10.5, 1000})[0]
+	up := makeKlines(10, bar{11, 1000})[0]
+	down := makeKlines(10, bar{9, 1000})[0]
+	suspended := makeKlines(10, bar{10, 0})[0]
+	for _, c := range []struct {
+		name            string
+		rules           Rules
+		k               *extend.Kline
+		buyTime         int64
+		buy, sell       float64
+		noBuy, noSell   string
+		canBuy, canSell bool
+	}{
+		{"不限制", nil, up, up.Unix, 11.11, 10.89, "", "", true, true},
+		{"可以成交", rules, k, 0, 10.605, 10.395, "", "", true, true},
+		//滑点后的成交价不超过涨跌停价
+		{"涨停", rules, up, 0, 11, 10.89, "涨停", "", false, true},
+		{"跌停", rules, down, 0, 9.09, 9, "", "跌停", true, false},
+		{"停牌", rules, suspended, 0, 10.1, 9.9, "停牌", "停牌", false, false},
+		{"T+1", rules, k, k.Unix, 10.605, 10.395, "", "T+1", true, false},
+		{"前一天买入", rules, k, k.Unix - 86400, 10.605, 10.395, "", "", true, true},
+	} {
+		last := c.k.Last.Float64()
+		f := cfg.Fill(c.rules, info, c.k, last, c.buyTime)
+		if !near(f.BuyPrice, c.buy) || !near(f.SellPrice, c.sell) || f.NoBuy != c.noBuy || f.NoSell != c.noSell ||
+			f.CanBuy() != c.canBuy || f.CanSell() != c.canSell {
+			t.Errorf("[%s] %+v", c.name, f)
+		}
+	}
+}
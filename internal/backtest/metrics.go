@@ -0,0 +1,286 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MetricsConfig 绩效指标的计算参数
+type MetricsConfig struct {
+	RiskFree float64 //年化无风险利率,例0.02
+	Periods  float64 //每年的K线数量,用于年化,0表示按K线间隔自动推断,日线为252
+}
+
+// Metrics 绩效指标
+type Metrics struct {
+	Return        float64 `json:"return"`          //总收益率
+	CAGR          float64 `json:"cagr"`            //年化收益率(复合)
+	Volatility    float64 `json:"volatility"`      //年化波动率
+	Sharpe        float64 `json:"sharpe"`          //夏普比率,扣除无风险利率,样本标准差
+	Sortino       float64 `json:"sortino"`         //索提诺比率,只计算下行波动
+	Calmar        float64 `json:"calmar"`          //卡玛比率,年化收益率/最大回撤
+	MaxDD         float64 `json:"max_drawdown"`    //最大回撤
+	MaxDDPeak     int64   `json:"max_dd_peak"`     //最大回撤开始的时间(峰值)
+	MaxDDTrough   int64   `json:"max_dd_trough"`   //最大回撤的最低点时间
+	MaxDDRecovery int64   `json:"max_dd_recovery"` //最大回撤恢复到峰值的时间,0表示还未恢复
+	MaxDDDuration int     `json:"max_dd_duration"` //最长的回撤持续K线数量,从峰值到恢复
+	Trades        int     `json:"trades"`          //完整交易(买入到卖出)的数量
+	WinRate       float64 `json:"win_rate"`        //胜率
+	ProfitFactor  float64 `json:"profit_factor"`   //盈利因子,总盈利/总亏损,没有亏损时为InfProfitFactor
+	AvgWin        float64 `json:"avg_win"`         //平均盈利金额
+	AvgLoss       float64 `json:"avg_loss"`        //平均亏损金额,正数
+	AvgHolding    float64 `json:"avg_holding"`     //平均持仓K线数量
+	MaxLossStreak int     `json:"max_loss_streak"` //最大连续亏损次数
+	Exposure      float64 `json:"exposure"`        //持仓时间占比
+	Periods       float64 `json:"periods"`         //年化使用的每年K线数量
+}
+
+// RoundTrip 一笔完整的交易,买入和卖出按先进先出配对
+type RoundTrip struct {
+	Code       string  `json:"code,omitempty"`
+	EntryTime  int64   `json:"entry_time"`
+	EntryIndex int     `json:"entry_index"`
+	EntryPrice float64 `json:"entry_price"`
+	ExitTime   int64   `json:"exit_time"`
+	ExitIndex  int     `json:"exit_index"`
+	ExitPrice  float64 `json:"exit_price"`
+	Qty        int     `json:"qty"`
+	Fee        float64 `json:"fee"`    //买卖手续费合计
+	PnL        float64 `json:"pnl"`    //扣除手续费后的盈亏
+	Return     float64 `json:"return"` //盈亏/买入成本
+	Bars       int     `json:"bars"`   //持仓K线数量
+	Reason     string  `json:"reason"` //卖出原因
+}
+
+// PairTrades 把交易记录按股票代码先进先出配对成完整交易,未卖出的持仓不计入
+func PairTrades(trades []Trade) []RoundTrip {
+	type open struct {
+		Trade
+		remain int
+	}
+	opens := map[string][]*open{}
+	out := []RoundTrip{}
+	for _, t := range trades {
+		if t.Side == "buy" {
+			opens[t.Code] = append(opens[t.Code], &open{Trade: t, remain: t.Qty})
+			continue
+		}
+		qty := t.Qty
+		for qty > 0 && len(opens[t.Code]) > 0 {
+			o := opens[t.Code][0]
+			n := min(qty, o.remain)
+			fee := o.Fee*float64(n)/float64(o.Qty) + t.Fee*float64(n)/float64(t.Qty)
+			cost := o.Price * float64(n)
+			pnl := (t.Price-o.Price)*float64(n) - fee
+			rt := RoundTrip{
+				Code:       t.Code,
+				EntryTime:  o.Time,
+				EntryIndex: o.Index,
+				EntryPrice: o.Price,
+				ExitTime:   t.Time,
+				ExitIndex:  t.Index,
+				ExitPrice:  t.Price,
+				Qty:        n,
+				Fee:        fee,
+				PnL:        pnl,
+				Bars:       t.Index - o.Index,
+				Reason:     t.Reason,
+			}
+			if cost > 0 {
+				rt.Return = pnl / cost
+			}
+			out = append(out, rt)
+			o.remain -= n
+			qty -= n
+			if o.remain == 0 {
+				opens[t.Code] = opens[t.Code][1:]
+			}
+		}
+	}
+	return out
+}
+
+// Analyze 计算绩效指标
+// times和equity为每根K线的时间和总资产,exposed为每根K线是否持仓,cash为初始资金
+func Analyze(times []int64, equity []float64, exposed []bool, trips []RoundTrip, cash float64, cfg MetricsConfig) Metrics {
+	m := Metrics{Periods: cfg.Periods}
+	if m.Periods <= 0 {
+		m.Periods = periodsPerYear(times)
+	}
+	n := len(equity)
+	if n == 0 || cash <= 0 {
+		return m
+	}
+
+	//收益
+	m.Return = equity[n-1]/cash - 1
+	if years := float64(n) / m.Periods; years > 0 && equity[n-1] > 0 {
+		m.CAGR = math.Pow(equity[n-1]/cash, 1/years) - 1
+	}
+
+	//波动率,夏普,索提诺
+	rets := make([]float64, 0, n)
+	prev := cash
+	for _, v := range equity {
+		if prev > 0 {
+			rets = append(rets, v/prev-1)
+		}
+		prev = v
+	}
+	rf := math.Pow(1+cfg.RiskFree, 1/m.Periods) - 1
+	if len(rets) > 1 {
+		var mean float64
+		for _, r := range rets {
+			mean += r
+		}
+		mean /= float64(len(rets))
+		var variance, downside float64
+		for _, r := range rets {
+			variance += (r - mean) * (r - mean)
+			if r < rf {
+				downside += (r - rf) * (r - rf)
+			}
+		}
+		sd := math.Sqrt(variance / float64(len(rets)-1))
+		dd := math.Sqrt(downside / float64(len(rets)))
+		m.Volatility = sd * math.Sqrt(m.Periods)
+		if sd > 0 {
+			m.Sharpe = (mean - rf) / sd * math.Sqrt(m.Periods)
+		}
+		if dd > 0 {
+			m.Sortino = (mean - rf) / dd * math.Sqrt(m.Periods)
+		}
+	}
+
+	//回撤
+	peak, peakIdx, start := cash, -1, -1
+	var trough, ddPeak int
+	for i, v := range equity {
+		if v >= peak {
+			if start >= 0 {
+				//回撤恢复
+				m.MaxDDDuration = max(m.MaxDDDuration, i-start)
+				start = -1
+			}
+			peak, peakIdx = v, i
+			continue
+		}
+		if start < 0 {
+			start = peakIdx
+		}
+		if dd := (peak - v) / peak; dd > m.MaxDD {
+			m.MaxDD = dd
+			ddPeak, trough = peakIdx, i
+		}
+	}
+	if start >= 0 {
+		m.MaxDDDuration = max(m.MaxDDDuration, n-1-start)
+	}
+	if m.MaxDD > 0 {
+		//最大回撤之后第一次回到峰值的时间
+		m.MaxDDPeak = at(times, ddPeak)
+		m.MaxDDTrough = at(times, trough)
+		top := cash
+		if ddPeak >= 0 {
+			top = equity[ddPeak]
+		}
+		for i := trough + 1; i < n; i++ {
+			if equity[i] >= top {
+				m.MaxDDRecovery = at(times, i)
+				break
+			}
+		}
+		m.Calmar = m.CAGR / m.MaxDD
+	}
+
+	//交易统计
+	m.Trades = len(trips)
+	var wins, losses int
+	var win, loss float64
+	var bars, streak int
+	sorted := append([]RoundTrip(nil), trips...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ExitIndex < sorted[j].ExitIndex })
+	for _, t := range sorted {
+		bars += t.Bars
+		if t.PnL > 0 {
+			wins++
+			win += t.PnL
+			streak = 0
+		} else {
+			losses++
+			loss -= t.PnL
+			streak++
+			m.MaxLossStreak = max(m.MaxLossStreak, streak)
+		}
+	}
+	if m.Trades > 0 {
+		m.WinRate = float64(wins) / float64(m.Trades)
+		m.AvgHolding = float64(bars) / float64(m.Trades)
+	}
+	if wins > 0 {
+		m.AvgWin = win / float64(wins)
+	}
+	if losses > 0 {
+		m.AvgLoss = loss / float64(losses)
+	}
+	m.ProfitFactor = ProfitFactor(win, loss)
+
+	//持仓时间
+	var held int
+	for _, b := range exposed {
+		if b {
+			held++
+		}
+	}
+	m.Exposure = float64(held) / float64(n)
+	return m
+}
+
+// InfProfitFactor 只有盈利没有亏损时的盈利因子,json不支持无穷大,使用最大的有限值
+const InfProfitFactor = math.MaxFloat64
+
+// ProfitFactor 盈利因子,总盈利/总亏损,没有亏损时为InfProfitFactor,没有盈利时为0
+func ProfitFactor(win, loss float64) float64 {
+	switch {
+	case loss > 0:
+		return win / loss
+	case win > 0:
+		return InfProfitFactor
+	default:
+		return 0
+	}
+}
+
+// periodsPerYear 按K线间隔的中位数推断每年的K线数量
+func periodsPerYear(times []int64) float64 {
+	if len(times) < 2 {
+		return 252
+	}
+	ds := make([]int64, 0, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		ds = append(ds, times[i]-times[i-1])
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	d := time.Duration(ds[len(ds)/2]) * time.Second
+	switch {
+	case d >= 25*24*time.Hour:
+		return 12
+	case d >= 5*24*time.Hour:
+		return 52
+	case d >= 20*time.Hour:
+		return 252
+	case d <= 0:
+		return 252
+	default:
+		//A股每天交易4小时
+		return 252 * float64(4*time.Hour) / float64(d)
+	}
+}
+
+func at(times []int64, i int) int64 {
+	if i < 0 || i >= len(times) {
+		return 0
+	}
+	return times[i]
+}
@@ -0,0 +1,130 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testTrades 两只股票交叉买卖,A分两次买入,再分两次卖出
+var testTrades = []Trade{
+	{Index: 0, Code: "A", Side: "buy", Price: 10, Qty: 100, Fee: 5},
+	{Index: 1, Code: "B", Side: "buy", Price: 5, Qty: 100, Fee: 5},
+	{Index: 2, Code: "A", Side: "buy", Price: 12, Qty: 100, Fee: 6},
+	{Index: 3, Code: "B", Side: "sell", Price: 4, Qty: 100, Fee: 5, Reason: "stop_loss"},
+	{Index: 4, Code: "A", Side: "sell", Price: 11, Qty: 150, Fee: 7.5, Reason: "signal"},
+	{Index: 5, Code: "A", Side: "sell", Price: 13, Qty: 50, Fee: 2.5, Reason: "take_profit"},
+	{Index: 6, Code: "B", Side: "buy", Price: 4, Qty: 100, Fee: 5}, //未卖出,不计入
+}
+
+func TestPairTrades(t *testing.T) {
+	want := []RoundTrip{
+		//(4-5)*100-5-5
+		{Code: "B", EntryIndex: 1, EntryPrice: 5, ExitIndex: 3, ExitPrice: 4, Qty: 100, Fee: 10, PnL: -110, Return: -0.22, Bars: 2, Reason: "stop_loss"},
+		//卖出150股,先配对第一笔的100股,手续费按数量分摊 5+7.5*100/150
+		{Code: "A", EntryIndex: 0, EntryPrice: 10, ExitIndex: 4, ExitPrice: 11, Qty: 100, Fee: 10, PnL: 90, Return: 0.09, Bars: 4, Reason: "signal"},
+		//剩余50股配对第二笔 6*50/100+7.5*50/150
+		{Code: "A", EntryIndex: 2, EntryPrice: 12, ExitIndex: 4, ExitPrice: 11, Qty: 50, Fee: 5.5, PnL: -55.5, Return: -55.5 / 600, Bars: 2, Reason: "signal"},
+		{Code: "A", EntryIndex: 2, EntryPrice: 12, ExitIndex: 5, ExitPrice: 13, Qty: 50, Fee: 5.5, PnL: 44.5, Return: 44.5 / 600, Bars: 3, Reason: "take_profit"},
+	}
+	got := PairTrades(testTrades)
+	if len(got) != len(want) {
+		t.Fatalf("完整交易数量 %d, 期望 %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Code != w.Code || g.EntryIndex != w.EntryIndex || g.EntryPrice != w.EntryPrice || g.ExitIndex != w.ExitIndex ||
+			g.ExitPrice != w.ExitPrice || g.Qty != w.Qty || !near(g.Fee, w.Fee) || !near(g.PnL, w.PnL) ||
+			!near(g.Return, w.Return) || g.Bars != w.Bars || g.Reason != w.Reason {
+			t.Errorf("完整交易[%d] %+v, 期望 %+v", i, g, w)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local).Unix()
+	times := []int64{start, start + 86400, start + 2*86400, start + 3*86400}
+	equity := []float64{100, 110, 99, 121}
+	exposed := []bool{false, true, true, false}
+	m := Analyze(times, equity, exposed, PairTrades(testTrades), 100, MetricsConfig{})
+
+	//每根K线的收益率 0,0.1,-0.1,2/9,均值1/18,样本标准差0.13789,下行偏差0.05
+	cagr := math.Pow(1.21, 252.0/4) - 1
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"Periods", m.Periods, 252},
+		{"Return", m.Return, 0.21},
+		{"CAGR", m.CAGR, cagr},
+		{"Volatility", m.Volatility, 2.188860687917601},
+		{"Sharpe", m.Sharpe, 6.396021490668317},
+		{"Sortino", m.Sortino, 17.638342073763962},
+		{"MaxDD", m.MaxDD, 0.1},
+		{"Calmar", m.Calmar, cagr / 0.1},
+		{"WinRate", m.WinRate, 0.5},
+		{"AvgWin", m.AvgWin, (90 + 44.5) / 2},
+		{"AvgLoss", m.AvgLoss, (110 + 55.5) / 2},
+		{"ProfitFactor", m.ProfitFactor, 134.5 / 165.5},
+		{"AvgHolding", m.AvgHolding, 2.75},
+		{"Exposure", m.Exposure, 0.5},
+	} {
+		if !near(c.got, c.want) {
+			t.Errorf("%s %v, 期望 %v", c.name, c.got, c.want)
+		}
+	}
+	if m.MaxDDPeak != times[1] || m.MaxDDTrough != times[2] || m.MaxDDRecovery != times[3] || m.MaxDDDuration != 2 {
+		t.Errorf("回撤时间 %d %d %d %d", m.MaxDDPeak, m.MaxDDTrough, m.MaxDDRecovery, m.MaxDDDuration)
+	}
+	if m.Trades != 4 || m.MaxLossStreak != 1 {
+		t.Errorf("交易数量 %d 最大连续亏损 %d", m.Trades, m.MaxLossStreak)
+	}
+
+	//未恢复的回撤持续到最后一根K线
+	m = Analyze(times, []float64{100, 120, 90, 100}, exposed, nil, 100, MetricsConfig{Periods: 252})
+	if !near(m.MaxDD, 0.25) || m.MaxDDRecovery != 0 || m.MaxDDDuration != 2 {
+		t.Errorf("未恢复的回撤 %v %d %d", m.MaxDD, m.MaxDDRecovery, m.MaxDDDuration)
+	}
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	start := time.Date(2024, 1, 2, 9, 35, 0, 0, time.Local).Unix()
+	series := func(step int64) []int64 {
+		return []int64{start, start + step, start + 2*step, start + 3*step}
+	}
+	for _, c := range []struct {
+		name  string
+		times []int64
+		want  float64
+	}{
+		{"日线", series(86400), 252},
+		{"周线", series(7 * 86400), 52},
+		{"月线", series(30 * 86400), 12},
+		{"5分钟", series(300), 252 * 48},
+		{"K线不足", series(300)[:1], 252},
+	} {
+		if got := periodsPerYear(c.times); !near(got, c.want) {
+			t.Errorf("[%s] %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestProfitFactor(t *testing.T) {
+	for _, c := range []struct {
+		win, loss, want float64
+	}{
+		{150, 100, 1.5},
+		{100, 0, InfProfitFactor},
+		{0, 100, 0},
+		{0, 0, 0},
+	} {
+		if got := ProfitFactor(c.win, c.loss); got != c.want {
+			t.Errorf("ProfitFactor(%v, %v) %v, 期望 %v", c.win, c.loss, got, c.want)
+		}
+	}
+	//只有盈利的交易
+	m := Analyze([]int64{1, 2}, []float64{100, 110}, nil, []RoundTrip{{PnL: 10}}, 100, MetricsConfig{})
+	if m.ProfitFactor != InfProfitFactor {
+		t.Errorf("没有亏损时的盈利因子 %v", m.ProfitFactor)
+	}
+}
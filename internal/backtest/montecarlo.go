@@ -0,0 +1,251 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/injoyai/strategy/internal/indicator"
+)
+
+const (
+	MonteCarloBootstrap = "bootstrap" //有放回抽样,交易数量不变,同一笔交易可能出现多次或不出现
+	MonteCarloShuffle   = "shuffle"   //只打乱交易顺序,最终收益不变,回撤会变化
+
+	DefaultMonteCarloRuns = 1000
+	MaxMonteCarloRuns     = 100000
+	DefaultRuin           = 0.5 //默认破产线,总资产跌到初始资金的50%
+)
+
+// DefaultConfidence 默认的置信水平
+var DefaultConfidence = []float64{0.9, 0.95, 0.99}
+
+// MonteCarloConfig 蒙特卡洛模拟配置
+type MonteCarloConfig struct {
+	Runs       int       `json:"runs"`       //模拟次数,默认1000
+	Method     string    `json:"method"`     //bootstrap:有放回抽样(默认) shuffle:打乱顺序
+	SkipRate   float64   `json:"skip_rate"`  //每笔交易被随机跳过的概率,模拟漏单,0~1
+	Slippage   float64   `json:"slippage"`   //额外滑点的上限,每笔交易买卖各随机增加0~Slippage的滑点
+	Ruin       float64   `json:"ruin"`       //破产线,总资产低于初始资金的这个比例视为破产,默认0.5
+	Confidence []float64 `json:"confidence"` //置信水平,默认0.9,0.95,0.99
+	Paths      int       `json:"paths"`      //返回的资金曲线数量,用于画图,默认0
+	Seed       int64     `json:"seed"`       //随机种子,0使用当前时间
+}
+
+// Interval 置信区间
+type Interval struct {
+	Level float64 `json:"level"` //置信水平
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+}
+
+// Distribution 模拟结果的分布
+type Distribution struct {
+	Original  float64    `json:"original"` //历史回测的值
+	Mean      float64    `json:"mean"`
+	Median    float64    `json:"median"`
+	Std       float64    `json:"std"`
+	Worst     float64    `json:"worst"`     //最差的一次
+	Intervals []Interval `json:"intervals"` //双侧置信区间
+}
+
+// MonteCarloResult 蒙特卡洛模拟结果
+type MonteCarloResult struct {
+	Runs       int          `json:"runs"`
+	Trades     int          `json:"trades"`       //参与模拟的完整交易数量
+	Return     Distribution `json:"return"`       //最终收益率
+	MaxDD      Distribution `json:"max_drawdown"` //最大回撤,按每笔交易平仓后的总资产计算
+	Ruin       float64      `json:"ruin"`         //破产线
+	RiskOfRuin float64      `json:"risk_of_ruin"` //破产概率,总资产曾经低于破产线的模拟占比
+	Paths      [][]float64  `json:"paths"`        //部分模拟的资金曲线,每笔交易平仓后的总资产
+}
+
+// mcTrade 一笔交易对总资产的影响
+type mcTrade struct {
+	weight float64 //买入成本/买入前的总资产
+	ret    float64 //交易收益率,已扣除手续费和回测时的滑点
+	exit   float64 //卖出价/买入价
+}
+
+// MonteCarlo 对回测的完整交易做蒙特卡洛模拟,判断历史结果是否只是运气,
+// 每笔交易按买入时占总资产的比例复利,抽样或打乱顺序后随机跳过交易和增加滑点
+func MonteCarlo(res Result, cash float64, cfg MonteCarloConfig) (*MonteCarloResult, error) {
+	if cash <= 0 {
+		return nil, errors.New("初始资金需要大于0")
+	}
+	switch cfg.Method {
+	case "":
+		cfg.Method = MonteCarloBootstrap
+	case MonteCarloBootstrap, MonteCarloShuffle:
+	default:
+		return nil, fmt.Errorf("未知的模拟方式[%s],可选bootstrap/shuffle", cfg.Method)
+	}
+	if cfg.Runs <= 0 {
+		cfg.Runs = DefaultMonteCarloRuns
+	}
+	if cfg.Runs > MaxMonteCarloRuns {
+		return nil, fmt.Errorf("模拟次数不能超过%d", MaxMonteCarloRuns)
+	}
+	if cfg.SkipRate < 0 || cfg.SkipRate >= 1 {
+		return nil, errors.New("跳过交易的概率需要在0-1之间")
+	}
+	if cfg.Slippage < 0 {
+		return nil, errors.New("滑点不能小于0")
+	}
+	if cfg.Ruin <= 0 {
+		cfg.Ruin = DefaultRuin
+	}
+	if len(cfg.Confidence) == 0 {
+		cfg.Confidence = DefaultConfidence
+	}
+	for _, c := range cfg.Confidence {
+		if c <= 0 || c >= 1 {
+			return nil, errors.New("置信水平需要在0-1之间")
+		}
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	trips := res.RoundTrips
+	if trips == nil {
+		trips = PairTrades(res.Trades)
+	}
+	if len(trips) == 0 {
+		return nil, errors.New("没有完整的交易,无法模拟")
+	}
+	trades := make([]mcTrade, 0, len(trips))
+	for _, t := range trips {
+		before := cash
+		if t.EntryIndex > 0 && t.EntryIndex <= len(res.Equity) {
+			before = res.Equity[t.EntryIndex-1]
+		}
+		cost := t.EntryPrice * float64(t.Qty)
+		if before <= 0 || cost <= 0 {
+			continue
+		}
+		trades = append(trades, mcTrade{
+			weight: cost / before,
+			ret:    t.Return,
+			exit:   t.ExitPrice / t.EntryPrice,
+		})
+	}
+	if len(trades) == 0 {
+		return nil, errors.New("没有完整的交易,无法模拟")
+	}
+
+	//历史的交易顺序
+	original := simulate(trades, cash, cfg.Ruin, nil)
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+	result := &MonteCarloResult{Runs: cfg.Runs, Trades: len(trades), Ruin: cfg.Ruin}
+	rets := make([]float64, cfg.Runs)
+	dds := make([]float64, cfg.Runs)
+	ruined := 0
+	sample := make([]mcTrade, len(trades))
+	for i := 0; i < cfg.Runs; i++ {
+		switch cfg.Method {
+		case MonteCarloShuffle:
+			copy(sample, trades)
+			r.Shuffle(len(sample), func(a, b int) { sample[a], sample[b] = sample[b], sample[a] })
+		default:
+			for j := range sample {
+				sample[j] = trades[r.Intn(len(trades))]
+			}
+		}
+		path := simulate(sample, cash, cfg.Ruin, func(t mcTrade) (mcTrade, bool) {
+			if cfg.SkipRate > 0 && r.Float64() < cfg.SkipRate {
+				return t, false
+			}
+			if cfg.Slippage > 0 {
+				//买入价变高,卖出价变低,收益率按买入成本计算
+				t.ret -= r.Float64()*cfg.Slippage + r.Float64()*cfg.Slippage*t.exit
+			}
+			return t, true
+		})
+		rets[i], dds[i] = path.ret, path.dd
+		if path.ruined {
+			ruined++
+		}
+		if i < cfg.Paths {
+			result.Paths = append(result.Paths, path.equity)
+		}
+	}
+	result.RiskOfRuin = float64(ruined) / float64(cfg.Runs)
+	result.Return = distribution(rets, original.ret, cfg.Confidence, false)
+	result.MaxDD = distribution(dds, original.dd, cfg.Confidence, true)
+	return result, nil
+}
+
+type mcPath struct {
+	equity []float64
+	ret    float64
+	dd     float64
+	ruined bool
+}
+
+// simulate 按顺序复利计算一条资金曲线,perturb返回false时跳过这笔交易
+func simulate(trades []mcTrade, cash, ruin float64, perturb func(t mcTrade) (mcTrade, bool)) mcPath {
+	p := mcPath{equity: make([]float64, 0, len(trades))}
+	eq, peak := cash, cash
+	for _, t := range trades {
+		if perturb != nil {
+			var ok bool
+			if t, ok = perturb(t); !ok {
+				continue
+			}
+		}
+		eq = math.Max(eq*(1+t.weight*t.ret), 0)
+		peak = math.Max(peak, eq)
+		p.equity = append(p.equity, eq)
+		if peak > 0 {
+			p.dd = math.Max(p.dd, (peak-eq)/peak)
+		}
+		if eq < cash*ruin {
+			p.ruined = true
+		}
+	}
+	p.ret = eq/cash - 1
+	return p
+}
+
+// distribution 统计分布,worstHigh为true时值越大越差(回撤)
+func distribution(xs []float64, original float64, confidence []float64, worstHigh bool) Distribution {
+	d := Distribution{Original: original}
+	n := len(xs)
+	if n == 0 {
+		return d
+	}
+	ys := append([]float64(nil), xs...)
+	sort.Float64s(ys)
+	d.Mean = indicator.Mean(ys)
+	for _, x := range ys {
+		d.Std += (x - d.Mean) * (x - d.Mean)
+	}
+	if n > 1 {
+		d.Std = math.Sqrt(d.Std / float64(n-1))
+	}
+	d.Median = indicator.Median(ys)
+	d.Worst = ys[0]
+	if worstHigh {
+		d.Worst = ys[n-1]
+	}
+	for _, c := range confidence {
+		a := (1 - c) / 2
+		d.Intervals = append(d.Intervals, Interval{Level: c, Low: quantile(ys, a), High: quantile(ys, 1-a)})
+	}
+	return d
+}
+
+// quantile 分位数,线性插值,ys需要已经排序
+func quantile(ys []float64, q float64) float64 {
+	pos := q * float64(len(ys)-1)
+	i := int(pos)
+	if i >= len(ys)-1 {
+		return ys[len(ys)-1]
+	}
+	return ys[i] + (ys[i+1]-ys[i])*(pos-float64(i))
+}
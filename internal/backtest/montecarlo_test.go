@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testMonteCarloResult 两笔交易,第一笔半仓赚10%,第二笔全仓亏20%,
+// 按历史顺序总资产1000->1050->840
+func testMonteCarloResult() Result {
+	return Result{
+		Equity: []float64{1000, 1050, 1050, 840},
+		Trades: []Trade{
+			{Index: 0, Side: "buy", Price: 10, Qty: 50},
+			{Index: 1, Side: "sell", Price: 11, Qty: 50},
+			{Index: 2, Side: "buy", Price: 10, Qty: 105},
+			{Index: 3, Side: "sell", Price: 8, Qty: 105},
+		},
+	}
+}
+
+func TestMonteCarloShuffle(t *testing.T) {
+	res, err := MonteCarlo(testMonteCarloResult(), 1000, MonteCarloConfig{Runs: 100, Method: MonteCarloShuffle, Paths: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Trades != 2 || len(res.Paths) != 3 || len(res.Paths[0]) != 2 {
+		t.Fatalf("交易数量 %d 资金曲线 %v", res.Trades, res.Paths)
+	}
+	//打乱顺序不改变最终收益 1.05*0.8-1,两种顺序的最大回撤都是20%
+	r := res.Return
+	if !near(r.Original, -0.16) || !near(r.Mean, -0.16) || !near(r.Worst, -0.16) || !near(r.Std, 0) {
+		t.Errorf("收益分布 %+v", r)
+	}
+	if !near(res.MaxDD.Original, 0.2) || !near(res.MaxDD.Worst, 0.2) {
+		t.Errorf("回撤分布 %+v", res.MaxDD)
+	}
+	if len(r.Intervals) != 3 || r.Intervals[1].Level != 0.95 || !near(r.Intervals[1].Low, -0.16) || !near(r.Intervals[1].High, -0.16) {
+		t.Errorf("置信区间 %+v", r.Intervals)
+	}
+	if res.RiskOfRuin != 0 {
+		t.Errorf("破产概率 %v", res.RiskOfRuin)
+	}
+
+	//滑点只会让收益变差
+	slip, err := MonteCarlo(testMonteCarloResult(), 1000, MonteCarloConfig{Runs: 100, Method: MonteCarloShuffle, Slippage: 0.01, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slip.Return.Intervals[2].High >= -0.16 || !near(slip.Return.Original, -0.16) {
+		t.Errorf("滑点后的收益分布 %+v", slip.Return)
+	}
+}
+
+func TestMonteCarloBootstrap(t *testing.T) {
+	cfg := MonteCarloConfig{Runs: 1000, Ruin: 0.7, Confidence: []float64{0.9}, Seed: 1}
+	res, err := MonteCarlo(testMonteCarloResult(), 1000, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//有放回抽样只有三种结果: 两次盈利1.05², 一盈一亏0.84, 两次亏损0.8²,概率为1/4,1/2,1/4
+	r := res.Return
+	if !near(r.Worst, -0.36) || !near(r.Median, -0.16) {
+		t.Errorf("收益分布 %+v", r)
+	}
+	if iv := r.Intervals[0]; !near(iv.Low, -0.36) || !near(iv.High, 0.1025) {
+		t.Errorf("90%%置信区间 %+v", iv)
+	}
+	if !near(res.MaxDD.Worst, 0.36) {
+		t.Errorf("最差回撤 %v", res.MaxDD.Worst)
+	}
+	//只有两次亏损会跌破700
+	if res.RiskOfRuin < 0.2 || res.RiskOfRuin > 0.3 {
+		t.Errorf("破产概率 %v, 期望约0.25", res.RiskOfRuin)
+	}
+
+	//相同的种子结果一致
+	again, err := MonteCarlo(testMonteCarloResult(), 1000, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, again) {
+		t.Error("相同的种子结果不一致")
+	}
+}
+
+func TestMonteCarloError(t *testing.T) {
+	for _, c := range []struct {
+		cash float64
+		cfg  MonteCarloConfig
+	}{
+		{0, MonteCarloConfig{}},
+		{1000, MonteCarloConfig{Method: "unknown"}},
+		{1000, MonteCarloConfig{Runs: MaxMonteCarloRuns + 1}},
+		{1000, MonteCarloConfig{SkipRate: 1}},
+		{1000, MonteCarloConfig{Slippage: -0.01}},
+		{1000, MonteCarloConfig{Confidence: []float64{1}}},
+	} {
+		if _, err := MonteCarlo(testMonteCarloResult(), c.cash, c.cfg); err == nil {
+			t.Errorf("%v %+v 需要返回错误", c.cash, c.cfg)
+		}
+	}
+	if _, err := MonteCarlo(Result{}, 1000, MonteCarloConfig{}); err == nil {
+		t.Error("没有交易需要返回错误")
+	}
+}
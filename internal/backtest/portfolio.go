@@ -0,0 +1,358 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+// rebalanceBand 调仓时持仓市值偏离目标超过该比例才调整,避免频繁小额交易
+const rebalanceBand = 0.1
+
+// Series 单只股票的回测数据
+type Series struct {
+	Info extend.Info
+	Day  extend.Klines
+	Min  extend.Klines
+}
+
+// PortfolioSettings 组合回测设置
+type PortfolioSettings struct {
+	Cash         float64
+	MaxPositions int     //最大持仓数量,默认10
+	MaxWeight    float64 //单只股票的最大仓位比例,默认1/MaxPositions
+	Rebalance    int     //调仓周期(交易日),每N个交易日买入新股票并调整仓位,默认1
+	FeeRate      float64
+	MinFee       float64
+	Slippage     float64
+	Market       string  //交易规则,见MarketCN
+	RiskFree     float64 //年化无风险利率
+	Periods      float64 //每年的K线数量,0表示自动推断
+	// Benchmark 基准K线,为空时不计算相对基准的表现
+	Benchmark     extend.Klines
+	BenchmarkCode string
+}
+
+// settings 手续费,滑点和交易规则,和单只股票回测的成交方式一致
+func (this PortfolioSettings) settings() Settings {
+	return Settings{FeeRate: this.FeeRate, MinFee: this.MinFee, Slippage: this.Slippage, Market: this.Market}
+}
+
+// Holding 持仓
+type Holding struct {
+	Code   string  `json:"code"`
+	Name   string  `json:"name"`
+	Qty    int     `json:"qty"`
+	Price  float64 `json:"price"`  //最新价
+	Cost   float64 `json:"cost"`   //持仓均价
+	Value  float64 `json:"value"`  //市值
+	Weight float64 `json:"weight"` //占总资产比例
+}
+
+// PortfolioResult 组合回测结果
+type PortfolioResult struct {
+	Times          []int64     `json:"times"`           //交易日(秒级时间戳)
+	Equity         []float64   `json:"equity"`          //每个交易日的总资产
+	Cash           []float64   `json:"cash"`            //每个交易日的现金
+	Holdings       [][]Holding `json:"holdings"`        //每个交易日收盘后的持仓
+	Turnover       []float64   `json:"turnover"`        //每个交易日的换手率,成交额/总资产
+	Trades         []Trade     `json:"trades"`          //交易记录
+	Return         float64     `json:"return"`          //总收益率
+	MaxDD          float64     `json:"max_drawdown"`    //最大回撤
+	Sharpe         float64     `json:"sharpe"`          //夏普比率
+	AnnualTurnover float64     `json:"annual_turnover"` //年化换手率,按单边成交额计算
+	Metrics        Metrics     `json:"metrics"`         //完整的绩效指标
+	RoundTrips     []RoundTrip `json:"round_trips"`     //买入和卖出配对后的每笔交易盈亏
+	Benchmark      *Benchmark  `json:"benchmark,omitempty"`
+	RunID          int64       `json:"run_id,omitempty"` //保存的回测记录ID,未保存时为0
+}
+
+// asset 组合中单只股票的状态
+type asset struct {
+	Series
+	stream  strategy.Stream
+	rules   Rules
+	idx     int           //下一根K线的位置
+	bar     *extend.Kline //当日K线,没有数据或停牌为nil
+	info    extend.Info   //按最新K线计算的基本信息,价格和市值不使用之后的数据
+	price   float64       //最新收盘价
+	last    float64       //前收盘价
+	d       strategy.Decision
+	qty     int
+	cost    float64
+	buyTime int64
+	pending string //被规则挡住的卖出原因
+	score   float64
+}
+
+// fill 按当日收盘价计算成交价,返回是否可以成交
+func (this *asset) fill(side string, cost Settings) (float64, bool) {
+	if this.bar == nil {
+		return 0, false
+	}
+	f := cost.Fill(this.rules, this.info, this.bar, this.last, this.buyTime)
+	if side == "buy" {
+		return f.BuyPrice, f.CanBuy()
+	}
+	return f.SellPrice, f.CanSell()
+}
+
+// lot 整数手数量,没有规则时不限制
+func (this *asset) lot(qty int) int {
+	if this.rules != nil {
+		if lot := this.rules.Lot(this.info); lot > 0 {
+			return qty / lot * lot
+		}
+	}
+	return qty
+}
+
+// RunPortfolio 组合回测,按交易日遍历全部股票,共享资金
+// 每个交易日先处理卖出信号,调仓日再按评分从高到低买入新的股票,并把持仓调整到目标仓位
+func RunPortfolio(universe []Series, strat strategy.Interface, cfg PortfolioSettings) PortfolioResult {
+	if cfg.MaxPositions <= 0 {
+		cfg.MaxPositions = 10
+	}
+	if cfg.MaxWeight <= 0 || cfg.MaxWeight > 1 {
+		cfg.MaxWeight = 1 / float64(cfg.MaxPositions)
+	}
+	if cfg.Rebalance <= 0 {
+		cfg.Rebalance = 1
+	}
+
+	//合并全部股票的交易日
+	assets := make([]*asset, 0, len(universe))
+	dateSet := map[int64]struct{}{}
+	for _, s := range universe {
+		if len(s.Day) == 0 {
+			continue
+		}
+		for _, k := range s.Day {
+			dateSet[data.DayStart(k.Time)] = struct{}{}
+		}
+		assets = append(assets, &asset{
+			Series: s,
+			stream: strategy.NewStream(strat, s.Info, s.Min),
+			rules:  GetRules(cfg.Market, s.Info.Code),
+			info:   s.Info,
+		})
+	}
+	dates := make([]int64, 0, len(dateSet))
+	for k := range dateSet {
+		dates = append(dates, k)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+
+	res := PortfolioResult{
+		Times:    dates,
+		Equity:   make([]float64, len(dates)),
+		Cash:     make([]float64, len(dates)),
+		Holdings: make([][]Holding, len(dates)),
+		Turnover: make([]float64, len(dates)),
+		Trades:   []Trade{},
+	}
+	cash := cfg.Cash
+	var traded float64
+	held := map[*asset]struct{}{}
+
+	cost := cfg.settings()
+	buy := func(a *asset, i int, px float64, qty int, reason string) bool {
+		if qty <= 0 {
+			return false
+		}
+		amount := px * float64(qty)
+		f := cost.Fee(a.rules, "buy", amount)
+		if cash < amount+f {
+			return false
+		}
+		cash -= amount + f
+		traded += amount
+		a.cost = (a.cost*float64(a.qty) + amount) / float64(a.qty+qty)
+		a.qty += qty
+		a.buyTime = a.bar.Unix
+		held[a] = struct{}{}
+		res.Trades = append(res.Trades, Trade{Time: a.bar.Time.Unix(), Index: i, Code: a.Info.Code, Price: px, Side: "buy", Qty: qty, Fee: f, Reason: reason})
+		return true
+	}
+	sell := func(a *asset, i int, px float64, qty int, reason string) {
+		amount := px * float64(qty)
+		f := cost.Fee(a.rules, "sell", amount)
+		cash += amount - f
+		traded += amount
+		a.qty -= qty
+		if a.qty == 0 {
+			a.cost = 0
+			a.pending = ""
+			delete(held, a)
+		}
+		res.Trades = append(res.Trades, Trade{Time: a.bar.Time.Unix(), Index: i, Code: a.Info.Code, Price: px, Side: "sell", Qty: qty, Fee: f, Reason: reason})
+	}
+	//按代码排序的持仓,保证每次回测结果一致
+	holding := func() []*asset {
+		ls := make([]*asset, 0, len(held))
+		for a := range held {
+			ls = append(ls, a)
+		}
+		sort.Slice(ls, func(i, j int) bool { return ls[i].Info.Code < ls[j].Info.Code })
+		return ls
+	}
+	equity := func() float64 {
+		eq := cash
+		for _, a := range holding() {
+			eq += float64(a.qty) * a.price
+		}
+		return eq
+	}
+
+	for i, date := range dates {
+		traded = 0
+
+		//推送当日K线
+		for _, a := range assets {
+			a.bar = nil
+			a.d = strategy.Decision{}
+			if a.idx >= len(a.Day) || data.DayStart(a.Day[a.idx].Time) != date {
+				continue
+			}
+			a.bar = a.Day[a.idx]
+			a.idx++
+			a.last = a.price
+			if a.bar.Last > 0 {
+				a.last = a.bar.Last.Float64()
+			}
+			a.price = a.bar.Close.Float64()
+			a.info = data.NewInfo(a.Info.Code, a.Info.Name, a.bar)
+			a.d = a.stream.OnBar(a.bar)
+		}
+
+		//卖出信号,每个交易日都处理
+		for _, a := range holding() {
+			reason := a.pending
+			if a.d.Action == strategy.Sell {
+				reason = a.d.ReasonOr("signal")
+			}
+			if reason == "" {
+				continue
+			}
+			if px, ok := a.fill("sell", cost); ok {
+				sell(a, i, px, a.qty, reason)
+			} else {
+				a.pending = reason
+			}
+		}
+
+		if i%cfg.Rebalance == 0 {
+			eq := equity()
+			target := eq * cfg.MaxWeight
+
+			//减仓超过目标仓位的股票
+			for _, a := range holding() {
+				over := float64(a.qty)*a.price - target
+				if over <= target*rebalanceBand {
+					continue
+				}
+				if px, ok := a.fill("sell", cost); ok {
+					if qty := a.lot(int(over / a.price)); qty > 0 {
+						sell(a, i, px, min(qty, a.qty), "rebalance")
+					}
+				}
+			}
+
+			//按评分从高到低买入新的股票
+			candidates := []*asset(nil)
+			for _, a := range assets {
+				if a.d.Action != strategy.Buy || a.qty > 0 {
+					continue
+				}
+				a.score, _ = strategy.Score(strat, a.info, a.Day[:a.idx], a.Min)
+				candidates = append(candidates, a)
+			}
+			sort.Slice(candidates, func(i, j int) bool {
+				if candidates[i].score != candidates[j].score {
+					return candidates[i].score > candidates[j].score
+				}
+				if candidates[i].d.Weight != candidates[j].d.Weight {
+					return candidates[i].d.Weight > candidates[j].d.Weight
+				}
+				return candidates[i].Info.Code < candidates[j].Info.Code
+			})
+			for _, a := range candidates {
+				if len(held) >= cfg.MaxPositions {
+					break
+				}
+				px, ok := a.fill("buy", cost)
+				if !ok || px <= 0 {
+					continue
+				}
+				value := target
+				if a.d.Weight > 0 {
+					//策略给出了目标仓位,不超过单只股票的上限
+					value = eq * math.Min(a.d.Weight, cfg.MaxWeight)
+				}
+				value = math.Min(value, cash/(1+cfg.FeeRate))
+				buy(a, i, px, a.lot(int(value/px)), a.d.ReasonOr("signal"))
+			}
+
+			//加仓低于目标仓位的股票
+			for _, a := range holding() {
+				under := target - float64(a.qty)*a.price
+				if under <= target*rebalanceBand || a.pending != "" {
+					continue
+				}
+				if px, ok := a.fill("buy", cost); ok {
+					value := math.Min(under, cash/(1+cfg.FeeRate))
+					buy(a, i, px, a.lot(int(value/px)), "rebalance")
+				}
+			}
+		}
+
+		//记录当日收盘后的状态
+		eq := equity()
+		res.Equity[i] = eq
+		res.Cash[i] = cash
+		if eq > 0 {
+			res.Turnover[i] = traded / eq
+		}
+		hs := make([]Holding, 0, len(held))
+		for a := range held {
+			value := float64(a.qty) * a.price
+			hs = append(hs, Holding{
+				Code:   a.Info.Code,
+				Name:   a.Info.Name,
+				Qty:    a.qty,
+				Price:  a.price,
+				Cost:   a.cost,
+				Value:  value,
+				Weight: value / eq,
+			})
+		}
+		sort.Slice(hs, func(i, j int) bool { return hs[i].Value > hs[j].Value })
+		res.Holdings[i] = hs
+	}
+
+	n := len(dates)
+	exposed := make([]bool, n)
+	for i, hs := range res.Holdings {
+		exposed[i] = len(hs) > 0
+	}
+	res.RoundTrips = PairTrades(res.Trades)
+	mc := MetricsConfig{RiskFree: cfg.RiskFree, Periods: cfg.Periods}
+	res.Metrics = Analyze(dates, res.Equity, exposed, res.RoundTrips, cfg.Cash, mc)
+	if len(cfg.Benchmark) > 0 {
+		res.Benchmark = CompareBenchmark(cfg.BenchmarkCode, dates, res.Equity, cfg.Cash, cfg.Benchmark, mc)
+	}
+	res.Return = res.Metrics.Return
+	res.MaxDD = res.Metrics.MaxDD
+	res.Sharpe = res.Metrics.Sharpe
+	if n > 0 {
+		var sum float64
+		for _, v := range res.Turnover {
+			sum += v
+		}
+		res.AnnualTurnover = sum / 2 / float64(n) * res.Metrics.Periods
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+// byPrice 第一根K线买入,价格越高评分越高
+type byPrice struct{}
+
+func (byPrice) Name() string { return "byPrice" }
+
+func (byPrice) Type() string { return strategy.DayKline }
+
+func (byPrice) Signal(info extend.Info, day, min extend.Klines) bool { return len(day) == 1 }
+
+func (byPrice) Score(info extend.Info, day, min extend.Klines) (float64, string) {
+	return info.Price.Float64(), ""
+}
+
+// TestPortfolioScore 评分使用当天K线的价格,不能使用区间最后一根K线的价格
+func TestPortfolioScore(t *testing.T) {
+	a := makeKlines(10, bar{10, 1000}, bar{10, 1000}, bar{40, 1000})
+	b := makeKlines(20, bar{20, 1000}, bar{20, 1000}, bar{20, 1000})
+	universe := []Series{
+		{Info: data.NewInfo("sz000001", "A", a[len(a)-1]), Day: a},
+		{Info: data.NewInfo("sz000002", "B", b[len(b)-1]), Day: b},
+	}
+	res := RunPortfolio(universe, byPrice{}, PortfolioSettings{Cash: 100000, MaxPositions: 1})
+	if len(res.Trades) == 0 || res.Trades[0].Code != "sz000002" || res.Trades[0].Index != 0 {
+		t.Fatalf("交易 %+v", res.Trades)
+	}
+}
@@ -0,0 +1,141 @@
+package backtest
+
+import (
+	"math"
+	"strings"
+
+	"github.com/injoyai/tdx/extend"
+)
+
+const (
+	MarketNone = ""   //不限制,按收盘价成交,兼容旧版本
+	MarketCN   = "cn" //A股交易规则,按代码前缀sh/sz/bj选择交易所
+)
+
+// Rules 市场交易规则,回测引擎在下单前查询
+type Rules interface {
+	// Lot 每手股数,买入数量需要是整数手,0表示不限制
+	Lot(info extend.Info) int
+	// Limit 涨跌停价格(元),last为前收盘价,返回0表示不限制
+	Limit(info extend.Info, last float64) (up, down float64)
+	// Tax 佣金之外的税费,例印花税和过户费,side为buy/sell
+	Tax(side string, amount float64) float64
+	// T1 当日买入的股票是否要下一个交易日才能卖出
+	T1() bool
+	// Suspended 是否停牌,停牌时不能交易
+	Suspended(k *extend.Kline) bool
+}
+
+// GetRules 获取股票对应的交易规则,未知的市场或交易所返回nil
+func GetRules(market, code string) Rules {
+	switch market {
+	case MarketCN:
+		if len(code) < 2 {
+			return nil
+		}
+		if r, ok := AShare[code[:2]]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// Board 板块涨跌幅限制
+type Board struct {
+	Prefix string  //代码前缀,例sh688
+	Rate   float64 //涨跌幅限制
+	STRate float64 //ST股票的涨跌幅限制,0表示和Rate一致
+}
+
+// AShareRules A股单个交易所的规则
+type AShareRules struct {
+	Exchange    string  //交易所 sh/sz/bj
+	LotSize     int     //每手股数
+	StampDuty   float64 //印花税,仅卖出收取
+	TransferFee float64 //过户费,买卖双向收取
+	Boards      []Board //板块涨跌幅限制,按顺序匹配前缀
+	Default     Board   //未匹配到板块时使用
+}
+
+// AShare A股各交易所的规则,可以按需修改
+// 印花税为2023-08-28之后的万分之5,过户费为2022-04-29之后的十万分之1
+var AShare = map[string]*AShareRules{
+	"sh": {
+		Exchange:    "sh",
+		LotSize:     100,
+		StampDuty:   0.0005,
+		TransferFee: 0.00001,
+		Boards: []Board{
+			{Prefix: "sh688", Rate: 0.2}, //科创板
+			{Prefix: "sh689", Rate: 0.2}, //科创板存托凭证
+		},
+		Default: Board{Rate: 0.1, STRate: 0.05},
+	},
+	"sz": {
+		Exchange:    "sz",
+		LotSize:     100,
+		StampDuty:   0.0005,
+		TransferFee: 0.00001,
+		Boards: []Board{
+			{Prefix: "sz30", Rate: 0.2}, //创业板
+		},
+		Default: Board{Rate: 0.1, STRate: 0.05},
+	},
+	"bj": {
+		Exchange:  "bj",
+		LotSize:   100,
+		StampDuty: 0.0005,
+		Default:   Board{Rate: 0.3},
+	},
+}
+
+var _ Rules = (*AShareRules)(nil)
+
+func (this *AShareRules) Lot(info extend.Info) int { return this.LotSize }
+
+func (this *AShareRules) Board(code string) Board {
+	for _, b := range this.Boards {
+		if strings.HasPrefix(code, b.Prefix) {
+			return b
+		}
+	}
+	return this.Default
+}
+
+// Limit 涨跌停价格,按前收盘价计算并四舍五入到分
+func (this *AShareRules) Limit(info extend.Info, last float64) (up, down float64) {
+	if last <= 0 {
+		return 0, 0
+	}
+	b := this.Board(info.Code)
+	rate := b.Rate
+	if b.STRate > 0 && IsST(info.Name) {
+		rate = b.STRate
+	}
+	if rate <= 0 {
+		return 0, 0
+	}
+	up = math.Round(last*(1+rate)*100) / 100
+	down = math.Round(last*(1-rate)*100) / 100
+	return
+}
+
+func (this *AShareRules) Tax(side string, amount float64) float64 {
+	tax := amount * this.TransferFee
+	if side == "sell" {
+		tax += amount * this.StampDuty
+	}
+	return tax
+}
+
+func (this *AShareRules) T1() bool { return true }
+
+// Suspended 成交量为0视为停牌
+func (this *AShareRules) Suspended(k *extend.Kline) bool {
+	return k.Volume <= 0
+}
+
+// IsST 是否是ST股票,包括*ST
+func IsST(name string) bool {
+	return strings.Contains(strings.ToUpper(name), "ST")
+}
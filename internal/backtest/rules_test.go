@@ -0,0 +1,142 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// seq 按K线序号给出固定信号的策略
+type seq map[int]strategy.Action
+
+func (this seq) Name() string { return "seq" }
+
+func (this seq) Type() string { return strategy.DayKline }
+
+func (this seq) Signal(info extend.Info, day, min extend.Klines) bool {
+	return this.Decide(info, day, min).Action == strategy.Buy
+}
+
+func (this seq) Decide(info extend.Info, day, min extend.Klines) strategy.Decision {
+	return strategy.Decision{Action: this[len(day)-1]}
+}
+
+// bar K线,前收盘价为上一根的收盘价,volume为0表示停牌
+type bar struct {
+	close  float64
+	volume int64
+}
+
+// makeKlines 从2024-01-02开始每天一根日K线
+func makeKlines(last float64, bars ...bar) extend.Klines {
+	ks := make(extend.Klines, len(bars))
+	t := time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local)
+	for i, b := range bars {
+		ks[i] = &extend.Kline{
+			Unix: t.Unix(),
+			Kline: &protocol.Kline{
+				Last:   protocol.Price(math.Round(last * 1000)),
+				Open:   protocol.Price(math.Round(b.close * 1000)),
+				High:   protocol.Price(math.Round(b.close * 1000)),
+				Low:    protocol.Price(math.Round(b.close * 1000)),
+				Close:  protocol.Price(math.Round(b.close * 1000)),
+				Volume: b.volume,
+				Time:   t,
+			},
+		}
+		last = b.close
+		t = t.AddDate(0, 0, 1)
+	}
+	return ks
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestRulesLimit(t *testing.T) {
+	for _, c := range []struct {
+		code, name string
+		last       float64
+		up, down   float64
+	}{
+		{"sz000001", "平安银行", 10, 11, 9},
+		{"sh600000", "浦发银行", 7.33, 8.06, 6.6}, //8.063和6.597四舍五入到分
+		{"sz000004", "*ST国华", 10, 10.5, 9.5},
+		{"sz300750", "宁德时代", 100, 120, 80},
+		{"sh688981", "中芯国际", 50, 60, 40},
+		{"bj430047", "诺思兰德", 10, 13, 7},
+	} {
+		r := GetRules(MarketCN, c.code)
+		if r == nil {
+			t.Fatalf("[%s] 没有交易规则", c.code)
+		}
+		up, down := r.Limit(extend.Info{Code: c.code, Name: c.name}, c.last)
+		if !near(up, c.up) || !near(down, c.down) {
+			t.Errorf("[%s] 涨跌停 %v/%v, 期望 %v/%v", c.code, up, down, c.up, c.down)
+		}
+		if r.Lot(extend.Info{Code: c.code}) != 100 || !r.T1() {
+			t.Errorf("[%s] 每手股数或T+1错误", c.code)
+		}
+	}
+	if GetRules(MarketNone, "sz000001") != nil || GetRules(MarketCN, "hk00700") != nil {
+		t.Error("未知的市场需要返回nil")
+	}
+}
+
+func TestRulesTax(t *testing.T) {
+	r := GetRules(MarketCN, "sz000001")
+	//过户费十万分之1,印花税万分之5只在卖出收取
+	if v := r.Tax("buy", 100000); !near(v, 1) {
+		t.Errorf("买入税费 %v, 期望 1", v)
+	}
+	if v := r.Tax("sell", 100000); !near(v, 51) {
+		t.Errorf("卖出税费 %v, 期望 51", v)
+	}
+	//北交所没有过户费
+	if v := GetRules(MarketCN, "bj430047").Tax("sell", 100000); !near(v, 50) {
+		t.Errorf("北交所卖出税费 %v, 期望 50", v)
+	}
+}
+
+// TestRulesBacktest 涨停买不进,跌停和停牌卖不出,之后补卖
+func TestRulesBacktest(t *testing.T) {
+	ks := makeKlines(10,
+		bar{10, 1000},   //0
+		bar{11, 1000},   //1 涨停,买入被挡住
+		bar{10.5, 1000}, //2 买入150股,按整手取100股
+		bar{9.45, 1000}, //3 跌停,卖出被挡住
+		bar{9.8, 0},     //4 停牌
+		bar{10, 1000},   //5 补卖
+	)
+	s := seq{1: strategy.Buy, 2: strategy.Buy, 3: strategy.Sell}
+	cfg := Settings{Cash: 100000, Size: 150, FeeRate: 0.0003, MinFee: 5, Market: MarketCN}
+	res := RunBacktestAdvanced(extend.Info{Code: "sz000001"}, ks, nil, s, cfg)
+
+	want := []Trade{
+		{Time: ks[2].Unix, Index: 2, Price: 10.5, Side: "buy", Qty: 100, Fee: 5 + 1050*0.00001, Reason: "signal"},
+		{Time: ks[5].Unix, Index: 5, Price: 10, Side: "sell", Qty: 100, Fee: 5 + 1000*0.00051, Reason: "signal"},
+	}
+	if len(res.Trades) != len(want) {
+		t.Fatalf("交易数量 %d, 期望 %d: %+v", len(res.Trades), len(want), res.Trades)
+	}
+	for i, w := range want {
+		g := res.Trades[i]
+		if g.Time != w.Time || g.Index != w.Index || !near(g.Price, w.Price) || g.Side != w.Side ||
+			g.Qty != w.Qty || !near(g.Fee, w.Fee) || g.Reason != w.Reason {
+			t.Errorf("交易[%d] %+v, 期望 %+v", i, g, w)
+		}
+	}
+	if pos := res.Position; pos[1] != 0 || pos[3] != 100 || pos[4] != 100 || pos[5] != 0 {
+		t.Errorf("持仓 %v", pos)
+	}
+	cash := 100000 - 1050 - want[0].Fee + 1000 - want[1].Fee
+	if !near(res.Cash[5], cash) || !near(res.Equity[5], cash) {
+		t.Errorf("现金 %v 总资产 %v, 期望 %v", res.Cash[5], res.Equity[5], cash)
+	}
+	if !near(res.Equity[3], res.Cash[3]+945) {
+		t.Errorf("跌停当天的总资产 %v", res.Equity[3])
+	}
+}
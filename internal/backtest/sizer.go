@@ -0,0 +1,251 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/tdx/extend"
+)
+
+const (
+	SizerShares  = "shares"  //固定股数
+	SizerCash    = "cash"    //固定金额
+	SizerPercent = "percent" //总资产百分比
+	SizerATR     = "atr"     //按ATR控制单笔风险
+	SizerKelly   = "kelly"   //凯利公式
+)
+
+// SizeContext 计算买入数量时的上下文
+type SizeContext struct {
+	Info    extend.Info
+	Day     extend.Klines //截至当前的K线
+	Price   float64       //预计成交价
+	Equity  float64       //总资产
+	Cash    float64       //可用现金
+	Qty     int           //当前持仓数量,大于0表示加仓
+	Adds    int           //已加仓次数
+	Last    float64       //最近一次买入价
+	Lot     int           //每手股数,0表示不限制
+	Returns []float64     //已平仓交易的收益率
+}
+
+// shares 金额折算成股数,扣除手续费的余量
+func (this SizeContext) shares(amount, feeRate float64) int {
+	if this.Price <= 0 || amount <= 0 {
+		return 0
+	}
+	return int(amount / (this.Price * (1 + feeRate)))
+}
+
+// Sizer 仓位模型,返回买入数量,0表示不买入
+type Sizer interface {
+	Size(c SizeContext) int
+}
+
+// FixedShares 固定股数,有整手限制时至少一手
+type FixedShares struct {
+	Shares int
+}
+
+func (this FixedShares) Size(c SizeContext) int {
+	if c.Qty > 0 {
+		return 0
+	}
+	if c.Lot > 0 && this.Shares < c.Lot {
+		return c.Lot
+	}
+	return this.Shares
+}
+
+// FixedCash 每次买入固定金额
+type FixedCash struct {
+	Amount  float64
+	FeeRate float64
+}
+
+func (this FixedCash) Size(c SizeContext) int {
+	if c.Qty > 0 {
+		return 0
+	}
+	return c.shares(math.Min(this.Amount, c.Cash), this.FeeRate)
+}
+
+// PercentEquity 按总资产的比例买入
+type PercentEquity struct {
+	Percent float64 //0-1
+	FeeRate float64
+}
+
+func (this PercentEquity) Size(c SizeContext) int {
+	if c.Qty > 0 {
+		return 0
+	}
+	return c.shares(math.Min(c.Equity*this.Percent, c.Cash), this.FeeRate)
+}
+
+// ATRTarget 波动率目标仓位,止损距离为Multiple倍ATR,亏损到止损时损失总资产的Risk
+// 股数=总资产*Risk/(ATR*Multiple),波动越大仓位越小
+type ATRTarget struct {
+	Period   int     //ATR周期,默认14
+	Risk     float64 //单笔风险占总资产的比例,默认0.01
+	Multiple float64 //ATR倍数,默认2
+	FeeRate  float64
+}
+
+func (this ATRTarget) Size(c SizeContext) int {
+	if c.Qty > 0 || len(c.Day) <= this.Period {
+		return 0
+	}
+	//ATR是TR的简单平均,只需要最近Period+1根K线
+	bars := indicator.Bars(c.Day[len(c.Day)-this.Period-1:])
+	atr := indicator.ATR(bars, this.Period)
+	risk := atr[len(atr)-1] * this.Multiple
+	if risk <= 0 {
+		return 0
+	}
+	qty := int(c.Equity * this.Risk / risk)
+	return min(qty, c.shares(c.Cash, this.FeeRate))
+}
+
+// Kelly 凯利公式,f=W-(1-W)/R,W为胜率,R为平均盈利/平均亏损
+// 使用回测中已平仓的交易统计,交易数量不足时按Default比例买入
+type Kelly struct {
+	Fraction  float64 //凯利比例的系数,默认0.5即半凯利
+	MinTrades int     //开始使用凯利公式的最少交易数量,默认10
+	Default   float64 //交易数量不足时的仓位比例,默认0.1
+	Min       float64 //凯利比例小于等于0时的最小仓位,保证继续交易以更新统计,默认0.01
+	FeeRate   float64
+}
+
+func (this Kelly) Fractional(returns []float64) float64 {
+	if len(returns) < this.MinTrades {
+		return this.Default
+	}
+	var win, loss float64
+	var wins, losses int
+	for _, r := range returns {
+		switch {
+		case r > 0:
+			win += r
+			wins++
+		case r < 0:
+			loss -= r
+			losses++
+		}
+	}
+	if wins == 0 {
+		return this.Min
+	}
+	if losses == 0 {
+		return this.Fraction
+	}
+	w := float64(wins) / float64(len(returns))
+	ratio := (win / float64(wins)) / (loss / float64(losses))
+	f := (w - (1-w)/ratio) * this.Fraction
+	if f <= 0 {
+		return this.Min
+	}
+	return math.Min(f, 1)
+}
+
+func (this Kelly) Size(c SizeContext) int {
+	if c.Qty > 0 {
+		return 0
+	}
+	return c.shares(math.Min(c.Equity*this.Fractional(c.Returns), c.Cash), this.FeeRate)
+}
+
+// Pyramid 金字塔加仓,首次按Sizer买入,价格比上次买入上涨Step后再次出现买入信号时加仓,
+// 每次加仓数量为上次的Ratio倍,最多加仓MaxAdds次
+type Pyramid struct {
+	Sizer
+	MaxAdds int     //最多加仓次数
+	Step    float64 //加仓需要的涨幅,相对上次买入价
+	Ratio   float64 //加仓数量相对首次买入数量的比例,默认0.5
+}
+
+func (this Pyramid) Size(c SizeContext) int {
+	if c.Qty == 0 {
+		return this.Sizer.Size(c)
+	}
+	if c.Adds >= this.MaxAdds || c.Last <= 0 || c.Price < c.Last*(1+this.Step) {
+		return 0
+	}
+	first := c
+	first.Qty, first.Adds = 0, 0
+	qty := this.Sizer.Size(first)
+	return int(float64(qty) * math.Pow(this.Ratio, float64(c.Adds+1)))
+}
+
+// SizerConfig 仓位模型配置,用于接口传参
+type SizerConfig struct {
+	Type     string  `json:"type"`      //shares/cash/percent/atr/kelly
+	Shares   int     `json:"shares"`    //shares:固定股数
+	Amount   float64 `json:"amount"`    //cash:每次买入金额
+	Percent  float64 `json:"percent"`   //percent:总资产比例,0-1
+	Period   int     `json:"period"`    //atr:ATR周期,默认14
+	Risk     float64 `json:"risk"`      //atr:单笔风险占总资产的比例,默认0.01
+	Multiple float64 `json:"multiple"`  //atr:止损的ATR倍数,默认2
+	Fraction float64 `json:"fraction"`  //kelly:凯利比例系数,默认0.5
+	Trades   int     `json:"trades"`    //kelly:最少交易数量,默认10
+	Default  float64 `json:"default"`   //kelly:交易数量不足时的仓位比例,默认0.1
+	MaxAdds  int     `json:"max_adds"`  //金字塔加仓次数,0不加仓
+	AddStep  float64 `json:"add_step"`  //加仓需要的涨幅,默认0.05
+	AddRatio float64 `json:"add_ratio"` //加仓数量比例,默认0.5
+}
+
+// NewSizer 按配置创建仓位模型
+func NewSizer(cfg SizerConfig, feeRate float64) (Sizer, error) {
+	def := func(v, d float64) float64 {
+		if v <= 0 {
+			return d
+		}
+		return v
+	}
+	var s Sizer
+	switch cfg.Type {
+	case SizerShares:
+		if cfg.Shares <= 0 {
+			return nil, errors.New("固定股数需要大于0")
+		}
+		s = FixedShares{Shares: cfg.Shares}
+	case SizerCash:
+		if cfg.Amount <= 0 {
+			return nil, errors.New("固定金额需要大于0")
+		}
+		s = FixedCash{Amount: cfg.Amount, FeeRate: feeRate}
+	case SizerPercent, "":
+		if cfg.Percent < 0 || cfg.Percent > 1 {
+			return nil, errors.New("总资产比例需要在0-1之间")
+		}
+		s = PercentEquity{Percent: def(cfg.Percent, 1), FeeRate: feeRate}
+	case SizerATR:
+		s = ATRTarget{
+			Period:   int(def(float64(cfg.Period), 14)),
+			Risk:     def(cfg.Risk, 0.01),
+			Multiple: def(cfg.Multiple, 2),
+			FeeRate:  feeRate,
+		}
+	case SizerKelly:
+		s = Kelly{
+			Fraction:  def(cfg.Fraction, 0.5),
+			MinTrades: int(def(float64(cfg.Trades), 10)),
+			Default:   def(cfg.Default, 0.1),
+			Min:       0.01,
+			FeeRate:   feeRate,
+		}
+	default:
+		return nil, fmt.Errorf("未知的仓位模型[%s]", cfg.Type)
+	}
+	if cfg.MaxAdds > 0 {
+		s = Pyramid{
+			Sizer:   s,
+			MaxAdds: cfg.MaxAdds,
+			Step:    def(cfg.AddStep, 0.05),
+			Ratio:   def(cfg.AddRatio, 0.5),
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,122 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+func TestSizer(t *testing.T) {
+	ks := makeKlines(10, bar{10, 1}, bar{11, 1}, bar{10, 1}, bar{12, 1})
+	for _, c := range []struct {
+		name string
+		s    Sizer
+		c    SizeContext
+		want int
+	}{
+		{"固定股数", FixedShares{Shares: 150}, SizeContext{Price: 10, Lot: 100}, 150},
+		{"固定股数不足一手", FixedShares{Shares: 50}, SizeContext{Price: 10, Lot: 100}, 100},
+		{"固定股数已持仓", FixedShares{Shares: 150}, SizeContext{Price: 10, Qty: 100}, 0},
+		//10000/(10*1.0003)=999.7
+		{"固定金额", FixedCash{Amount: 10000, FeeRate: 0.0003}, SizeContext{Price: 10, Cash: 100000}, 999},
+		{"固定金额现金不足", FixedCash{Amount: 10000, FeeRate: 0.0003}, SizeContext{Price: 10, Cash: 5000}, 499},
+		{"总资产比例", PercentEquity{Percent: 0.5}, SizeContext{Price: 10, Equity: 100000, Cash: 100000}, 5000},
+		{"总资产比例现金不足", PercentEquity{Percent: 0.5}, SizeContext{Price: 10, Equity: 100000, Cash: 30000}, 3000},
+		//最近3根K线的TR为0,1,2,ATR(2)=1.5,止损距离3,100000*0.01/3=333
+		{"ATR", ATRTarget{Period: 2, Risk: 0.01, Multiple: 2}, SizeContext{Day: ks, Price: 12, Equity: 100000, Cash: 100000}, 333},
+		{"ATR现金不足", ATRTarget{Period: 2, Risk: 0.01, Multiple: 2}, SizeContext{Day: ks, Price: 12, Equity: 100000, Cash: 2000}, 166},
+		{"ATR K线不足", ATRTarget{Period: 4, Risk: 0.01, Multiple: 2}, SizeContext{Day: ks, Price: 12, Equity: 100000, Cash: 100000}, 0},
+		//胜率0.5,盈亏比2,f=0.5-0.5/2=0.25,半凯利0.125
+		{"凯利", Kelly{Fraction: 0.5, MinTrades: 4}, SizeContext{Price: 10, Equity: 100000, Cash: 100000, Returns: []float64{0.5, -0.25, 0.5, -0.25}}, 1250},
+	} {
+		if got := c.s.Size(c.c); got != c.want {
+			t.Errorf("[%s] %d, 期望 %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestKelly(t *testing.T) {
+	k := Kelly{Fraction: 0.5, MinTrades: 5, Default: 0.1, Min: 0.01}
+	for _, c := range []struct {
+		name    string
+		returns []float64
+		want    float64
+	}{
+		//胜率0.6,盈亏比(0.4/3)/0.05=8/3,f=0.6-0.4/(8/3)=0.45,半凯利0.225
+		{"半凯利", []float64{0.1, 0.1, -0.05, 0.2, -0.05}, 0.225},
+		{"交易不足", []float64{0.1, 0.1}, 0.1},
+		{"全部亏损", []float64{-0.1, -0.1, -0.05, -0.2, -0.05}, 0.01},
+		{"全部盈利", []float64{0.1, 0.1, 0.05, 0.2, 0.05}, 0.5},
+		//胜率0.2,盈亏比1,f=0.2-0.8<0
+		{"负期望", []float64{0.1, -0.1, -0.1, -0.1, -0.1}, 0.01},
+	} {
+		if got := k.Fractional(c.returns); !near(got, c.want) {
+			t.Errorf("[%s] %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPyramid(t *testing.T) {
+	p := Pyramid{Sizer: FixedShares{Shares: 1000}, MaxAdds: 2, Step: 0.05, Ratio: 0.5}
+	for _, c := range []struct {
+		name string
+		c    SizeContext
+		want int
+	}{
+		{"首次买入", SizeContext{Price: 10}, 1000},
+		{"涨幅不足", SizeContext{Price: 10.4, Qty: 1000, Last: 10}, 0},
+		{"第一次加仓", SizeContext{Price: 10.5, Qty: 1000, Last: 10}, 500},
+		{"第二次加仓", SizeContext{Price: 11.1, Qty: 1500, Adds: 1, Last: 10.5}, 250},
+		{"超过加仓次数", SizeContext{Price: 12, Qty: 1750, Adds: 2, Last: 11.1}, 0},
+	} {
+		if got := p.Size(c.c); got != c.want {
+			t.Errorf("[%s] %d, 期望 %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewSizer(t *testing.T) {
+	for _, cfg := range []SizerConfig{
+		{Type: SizerShares},
+		{Type: SizerCash},
+		{Type: SizerPercent, Percent: 1.5},
+		{Type: "unknown"},
+	} {
+		if _, err := NewSizer(cfg, 0.0003); err == nil {
+			t.Errorf("%+v 需要返回错误", cfg)
+		}
+	}
+
+	s, err := NewSizer(SizerConfig{}, 0.0003)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (PercentEquity{Percent: 1, FeeRate: 0.0003}) {
+		t.Errorf("默认仓位模型 %+v", s)
+	}
+
+	s, err = NewSizer(SizerConfig{Type: SizerKelly, MaxAdds: 1}, 0.0003)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := s.(Pyramid)
+	if !ok || p.Step != 0.05 || p.Ratio != 0.5 {
+		t.Fatalf("金字塔加仓 %+v", s)
+	}
+	if k, ok := p.Sizer.(Kelly); !ok || k.Fraction != 0.5 || k.MinTrades != 10 || k.Default != 0.1 {
+		t.Errorf("凯利 %+v", p.Sizer)
+	}
+}
+
+// TestSizerBacktest 目标仓位按总资产折算,仓位模型的数量按整手取整
+func TestSizerBacktest(t *testing.T) {
+	ks := makeKlines(10, bar{10, 1000}, bar{10, 1000}, bar{10, 1000})
+	s := seq{1: strategy.Buy}
+	cfg := Settings{Cash: 100000, FeeRate: 0.0003, MinFee: 5, Market: MarketCN, Sizer: FixedCash{Amount: 10000, FeeRate: 0.0003}}
+	res := RunBacktestAdvanced(extend.Info{Code: "sz000001"}, ks, nil, s, cfg)
+	//999股取整为900股
+	if len(res.Trades) != 1 || res.Trades[0].Qty != 900 {
+		t.Fatalf("交易 %+v", res.Trades)
+	}
+}
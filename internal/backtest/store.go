@@ -0,0 +1,229 @@
+package backtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+const (
+	KindSingle    = "single"    //单只股票回测
+	KindPortfolio = "portfolio" //组合回测
+	KindAll       = "all"       //全市场回测,每只股票单独回测
+)
+
+// Record 保存的回测记录,Request为原始请求,用于按相同的配置重新运行
+type Record struct {
+	ID         int64                      `xorm:"pk autoincr" json:"id"`
+	Kind       string                     `json:"kind"`                    //single/portfolio/all
+	Name       string                     `json:"name"`                    //策略表达式
+	Codes      []string                   `xorm:"json" json:"codes"`       //股票代码,全市场回测为空
+	Params     map[string]strategy.Params `xorm:"json" json:"params"`      //策略参数覆盖
+	Versions   []strategy.Version         `xorm:"json" json:"versions"`    //使用的策略及脚本版本
+	Start      string                     `json:"start"`                   //开始日期,为空表示全部数据
+	End        string                     `json:"end"`                     //结束日期,为空表示当前
+	Cash       float64                    `json:"cash"`                    //初始资金
+	Request    json.RawMessage            `xorm:"json" json:"request"`     //原始请求,包含资金,手续费,仓位模型等配置
+	Metrics    Metrics                    `xorm:"json" json:"metrics"`     //绩效指标,全市场回测为各股票的平均值
+	Benchmark  *Benchmark                 `xorm:"json" json:"benchmark"`   //相对基准的表现
+	Times      []int64                    `xorm:"json" json:"times"`       //资金曲线的时间
+	Equity     []float64                  `xorm:"json" json:"equity"`      //资金曲线
+	Trades     []Trade                    `xorm:"json" json:"trades"`      //交易记录
+	RoundTrips []RoundTrip                `xorm:"json" json:"round_trips"` //完整交易
+	Signals    []int                      `xorm:"json" json:"signals"`     //策略信号,与Times对齐,组合回测为空
+	Items      json.RawMessage            `xorm:"json" json:"items"`       //全市场回测每只股票的结果
+	Count      int                        `json:"count"`                   //全市场回测的股票数量
+	Rerun      int64                      `json:"rerun"`                   //重新运行的来源记录ID
+	Elapsed    int64                      `json:"elapsed"`                 //耗时,毫秒
+	Created    int64                      `xorm:"created" json:"created"`  //创建时间
+}
+
+// SetResult 填充单只股票的回测结果
+func (this *Record) SetResult(res Result) {
+	ks, _ := res.Klines.(extend.Klines)
+	this.Times = make([]int64, len(ks))
+	for i, k := range ks {
+		this.Times[i] = k.Unix
+	}
+	this.Metrics = res.Metrics
+	this.Benchmark = res.Benchmark
+	this.Equity = res.Equity
+	this.Trades = res.Trades
+	this.RoundTrips = res.RoundTrips
+	this.Signals = res.Signals
+}
+
+// SetPortfolio 填充组合回测结果
+func (this *Record) SetPortfolio(res *PortfolioResult) {
+	this.Metrics = res.Metrics
+	this.Benchmark = res.Benchmark
+	this.Times = res.Times
+	this.Equity = res.Equity
+	this.Trades = res.Trades
+	this.RoundTrips = res.RoundTrips
+}
+
+// Result 转换成单只股票的回测结果,用于蒙特卡洛等分析
+func (this *Record) Result() Result {
+	return Result{
+		Equity:     this.Equity,
+		Trades:     this.Trades,
+		Return:     this.Metrics.Return,
+		MaxDD:      this.Metrics.MaxDD,
+		Sharpe:     this.Metrics.Sharpe,
+		Metrics:    this.Metrics,
+		RoundTrips: this.RoundTrips,
+		Benchmark:  this.Benchmark,
+		Signals:    this.Signals,
+	}
+}
+
+var recordOnce struct {
+	sync.Once
+	err error
+}
+
+func recordTable() error {
+	recordOnce.Do(func() {
+		recordOnce.err = common.DB.Sync2(new(Record))
+	})
+	return recordOnce.err
+}
+
+// SaveRecord 保存回测记录,保存后ID有值
+func SaveRecord(r *Record) error {
+	if err := recordTable(); err != nil {
+		return err
+	}
+	_, err := common.DB.Insert(r)
+	return err
+}
+
+// GetRecord 获取回测记录
+func GetRecord(id int64) (*Record, error) {
+	if err := recordTable(); err != nil {
+		return nil, err
+	}
+	r := new(Record)
+	has, err := common.DB.ID(id).Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("回测记录[%d]不存在", id)
+	}
+	return r, nil
+}
+
+// ListRecord 回测记录列表,不包含交易和资金曲线,按时间倒序
+func ListRecord(kind string) ([]*Record, error) {
+	if err := recordTable(); err != nil {
+		return nil, err
+	}
+	ls := []*Record(nil)
+	session := common.DB.Omit("Times", "Equity", "Trades", "RoundTrips", "Signals", "Items").Desc("ID")
+	if kind != "" {
+		session = session.Where("Kind=?", kind)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
+
+// DeleteRecord 删除回测记录
+func DeleteRecord(id int64) error {
+	if err := recordTable(); err != nil {
+		return err
+	}
+	_, err := common.DB.ID(id).Delete(new(Record))
+	return err
+}
+
+// Comparison 多个回测的对比
+type Comparison struct {
+	Runs   []*Record            `json:"runs"`   //回测记录,不包含交易和资金曲线
+	Times  []int64              `json:"times"`  //全部回测的时间合集
+	Equity [][]float64          `json:"equity"` //按初始资金归一化的资金曲线,与Times对齐,缺失的时间沿用前值,开始之前为1
+	Best   map[string]int64     `json:"best"`   //每个指标最好的回测ID
+	Table  map[string][]float64 `json:"table"`  //指标->每个回测的值,顺序同Runs
+}
+
+// compareMetrics 参与对比的指标,lower表示越小越好
+var compareMetrics = []struct {
+	name  string
+	lower bool
+	value func(m Metrics) float64
+}{
+	{"return", false, func(m Metrics) float64 { return m.Return }},
+	{"cagr", false, func(m Metrics) float64 { return m.CAGR }},
+	{"volatility", true, func(m Metrics) float64 { return m.Volatility }},
+	{"sharpe", false, func(m Metrics) float64 { return m.Sharpe }},
+	{"sortino", false, func(m Metrics) float64 { return m.Sortino }},
+	{"calmar", false, func(m Metrics) float64 { return m.Calmar }},
+	{"max_drawdown", true, func(m Metrics) float64 { return m.MaxDD }},
+	{"win_rate", false, func(m Metrics) float64 { return m.WinRate }},
+	{"profit_factor", false, func(m Metrics) float64 { return m.ProfitFactor }},
+	{"trades", false, func(m Metrics) float64 { return float64(m.Trades) }},
+}
+
+// CompareRecord 对比多个回测记录
+func CompareRecord(ids []int64) (*Comparison, error) {
+	if len(ids) < 2 {
+		return nil, errors.New("至少需要2个回测记录")
+	}
+	c := &Comparison{Best: map[string]int64{}, Table: map[string][]float64{}}
+	set := map[int64]struct{}{}
+	for _, id := range ids {
+		r, err := GetRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		c.Runs = append(c.Runs, r)
+		for _, t := range r.Times {
+			set[t] = struct{}{}
+		}
+	}
+	for t := range set {
+		c.Times = append(c.Times, t)
+	}
+	sort.Slice(c.Times, func(i, j int) bool { return c.Times[i] < c.Times[j] })
+
+	for _, r := range c.Runs {
+		eq := make([]float64, len(c.Times))
+		v, j := 1.0, 0
+		for i, t := range c.Times {
+			for j < len(r.Times) && r.Times[j] <= t {
+				if j < len(r.Equity) && r.Cash > 0 {
+					v = r.Equity[j] / r.Cash
+				}
+				j++
+			}
+			eq[i] = v
+		}
+		c.Equity = append(c.Equity, eq)
+	}
+
+	for _, m := range compareMetrics {
+		best := math.NaN()
+		for _, r := range c.Runs {
+			v := m.value(r.Metrics)
+			c.Table[m.name] = append(c.Table[m.name], v)
+			if math.IsNaN(best) || (m.lower && v < best) || (!m.lower && v > best) {
+				best = v
+				c.Best[m.name] = r.ID
+			}
+		}
+	}
+
+	//资金曲线已经单独返回
+	for _, r := range c.Runs {
+		r.Times, r.Equity, r.Trades, r.RoundTrips, r.Signals, r.Items = nil, nil, nil, nil, nil, nil
+	}
+	return c, nil
+}
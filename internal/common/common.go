@@ -18,6 +18,9 @@ import (
 var (
 	Data *data.Data
 
+	//Klines K线数据源,策略,选股和回测统一从这里读取
+	Klines data.Reader
+
 	DB *xorms.Engine
 
 	Script *interp.Interpreter
@@ -28,6 +31,8 @@ var (
 var (
 	database   = cfg.GetString("database.filename", "./data/database/strategy.db")
 	invalidDay = cfg.GetInt("invalid_day", 180)
+	maxOpen    = cfg.GetInt("kline.max_open", data.DefaultMaxOpen)
+	klineCache = cfg.GetBool("kline.cache", false)
 )
 
 func Init() error {
@@ -54,6 +59,9 @@ func Init() error {
 	if err != nil {
 		return err
 	}
+	Data.MaxOpen = maxOpen
+	Data.Cache = klineCache
+	Klines = Data
 
 	DB, err = sqlite.NewXorm(database)
 	if err != nil {
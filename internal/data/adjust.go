@@ -0,0 +1,174 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+const (
+	AdjustNone = ""    //不复权
+	AdjustQFQ  = "qfq" //前复权,最新价格不变,调整历史价格
+	AdjustHFQ  = "hfq" //后复权,上市首日价格不变,调整之后的价格
+)
+
+// CheckAdjust 校验复权类型
+func CheckAdjust(adjust string) error {
+	switch adjust {
+	case AdjustNone, AdjustQFQ, AdjustHFQ:
+		return nil
+	}
+	return fmt.Errorf("未知的复权类型[%s],可选qfq/hfq", adjust)
+}
+
+// adjustEvent 除权除息事件
+type adjustEvent struct {
+	Unix  int64   //除权除息日
+	Ratio float64 //除权除息后的前收盘价/实际前收盘价
+}
+
+// adjustCache 每只股票的除权除息事件,依赖数据库中的日线,数据更新后清空
+type adjustCache struct {
+	mu  sync.RWMutex
+	gen int //每次清空加1,防止清空前开始计算的旧结果写入缓存
+	m   map[string][]adjustEvent
+}
+
+func (this *adjustCache) get(code string) ([]adjustEvent, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	events, ok := this.m[code]
+	return events, ok
+}
+
+func (this *adjustCache) generation() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.gen
+}
+
+func (this *adjustCache) set(code string, events []adjustEvent, gen int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if gen == this.gen {
+		this.m[code] = events
+	}
+}
+
+func (this *adjustCache) reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.gen++
+	this.m = map[string][]adjustEvent{}
+}
+
+// GetDayKlinesAdjust 获取复权后的日线
+func (this *Data) GetDayKlinesAdjust(code string, start, end time.Time, adjust string) (extend.Klines, error) {
+	ks, err := this.GetDayKlines(code, start, end)
+	if err != nil || adjust == AdjustNone {
+		return ks, err
+	}
+	return this.Adjust(code, ks, adjust)
+}
+
+// Adjust 复权,返回新的K线,不修改原数据
+func (this *Data) Adjust(code string, ks extend.Klines, adjust string) (extend.Klines, error) {
+	if err := CheckAdjust(adjust); err != nil {
+		return nil, err
+	}
+	if adjust == AdjustNone || len(ks) == 0 {
+		return ks, nil
+	}
+	events, err := this.adjustEvents(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return ks, nil
+	}
+
+	//factor 某个时间的复权因子
+	factor := func(unix int64) float64 {
+		f := 1.0
+		for _, e := range events {
+			switch adjust {
+			case AdjustQFQ:
+				if e.Unix > unix {
+					f *= e.Ratio
+				}
+			case AdjustHFQ:
+				if e.Unix <= unix {
+					f /= e.Ratio
+				}
+			}
+		}
+		return f
+	}
+
+	out := make(extend.Klines, len(ks))
+	for i, k := range ks {
+		f := factor(DayStart(k.Time))
+		cp := *k
+		pk := *k.Kline
+		pk.Open = adjustPrice(pk.Open, f)
+		pk.High = adjustPrice(pk.High, f)
+		pk.Low = adjustPrice(pk.Low, f)
+		pk.Close = adjustPrice(pk.Close, f)
+		//前收盘价使用除权除息前一天的因子,保证涨跌幅不变
+		pk.Last = adjustPrice(pk.Last, factor(DayStart(k.Time)-1))
+		cp.Kline = &pk
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// adjustEvents 计算除权除息事件,需要事件前一天的收盘价,使用全部历史日线,
+// 除权除息日的日线还没有更新到数据库时不包含该事件,数据更新后重新计算
+func (this *Data) adjustEvents(code string) ([]adjustEvent, error) {
+	cache := this.repository().adjusts
+	if events, ok := cache.get(code); ok {
+		return events, nil
+	}
+	gen := cache.generation()
+
+	var events []adjustEvent
+	xs := this.Gbbq.GetXRXDs(code)
+	if len(xs) > 0 {
+		ks, err := this.GetDayKlines(code, time.Time{}, time.Now().AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].Time.Before(xs[j].Time) })
+		for _, x := range xs {
+			//除权除息日及之后的第一根K线,前收盘价为除权前的收盘价
+			i := sort.Search(len(ks), func(i int) bool { return DayStart(ks[i].Time) >= DayStart(x.Time) })
+			if i == 0 || i == len(ks) {
+				continue
+			}
+			last := ks[i-1].Close
+			if last <= 0 {
+				continue
+			}
+			pre := x.Pre(last)
+			if pre <= 0 || pre == last {
+				continue
+			}
+			events = append(events, adjustEvent{
+				Unix:  DayStart(ks[i].Time),
+				Ratio: pre.Float64() / last.Float64(),
+			})
+		}
+	}
+
+	cache.set(code, events, gen)
+	return events, nil
+}
+
+func adjustPrice(p protocol.Price, f float64) protocol.Price {
+	return protocol.Price(math.Round(float64(p) * f))
+}
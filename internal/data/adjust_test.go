@@ -0,0 +1,108 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// fakeGbbq 固定的除权除息数据
+type fakeGbbq struct {
+	xrxds map[string]protocol.XRXDs
+}
+
+func (this *fakeGbbq) GetEquity(code string, t time.Time) *protocol.Equity { return nil }
+
+func (this *fakeGbbq) GetTurnover(code string, t time.Time, volume int64) float64 { return 0 }
+
+func (this *fakeGbbq) GetXRXDs(code string) protocol.XRXDs { return this.xrxds[code] }
+
+func (this *fakeGbbq) GetFactors(code string, ks protocol.Klines) []*protocol.Factor { return nil }
+
+// adjustDay 2024年1月的日线,last为前收盘价
+func adjustDay(day int, last, close float64) *extend.Kline {
+	t := time.Date(2024, 1, day, 15, 0, 0, 0, time.Local)
+	return &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{
+		Last: protocol.Yuan(last), Open: protocol.Yuan(close), High: protocol.Yuan(close), Low: protocol.Yuan(close),
+		Close: protocol.Yuan(close), Time: t,
+	}}
+}
+
+// testAdjustData 1月4日10派5元,1月5日10送10股
+func testAdjustData(t *testing.T) (*Data, extend.Klines) {
+	code := "sz000001"
+	d := &Data{DatabaseDir: t.TempDir(), MaxOpen: 4, Manage: &tdx.Manage{Gbbq: &fakeGbbq{xrxds: map[string]protocol.XRXDs{code: {
+		{Code: code, Time: time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local), Songzhuangu: 10},
+		{Code: code, Time: time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local), Fenhong: 5},
+	}}}}}
+	t.Cleanup(d.Invalidate)
+	ks := extend.Klines{
+		adjustDay(2, 10, 10),
+		adjustDay(3, 10, 10),
+		adjustDay(4, 10, 9.5),
+		adjustDay(5, 9.5, 4.8),
+		adjustDay(8, 4.8, 4.9),
+	}
+	return d, ks
+}
+
+func TestAdjust(t *testing.T) {
+	d, ks := testAdjustData(t)
+	writeDayKlines(t, d, "sz000001", ks)
+	for _, c := range []struct {
+		adjust      string
+		close, last []protocol.Price
+	}{
+		{AdjustNone, []protocol.Price{10000, 10000, 9500, 4800, 4900}, []protocol.Price{10000, 10000, 10000, 9500, 4800}},
+		//除权除息前的价格乘以之后全部事件的比例 0.95*0.5
+		{AdjustQFQ, []protocol.Price{4750, 4750, 4750, 4800, 4900}, []protocol.Price{4750, 4750, 4750, 4750, 4800}},
+		//除权除息后的价格除以之前全部事件的比例
+		{AdjustHFQ, []protocol.Price{10000, 10000, 10000, 10105, 10316}, []protocol.Price{10000, 10000, 10000, 10000, 10105}},
+	} {
+		out, err := d.GetDayKlinesAdjust("sz000001", time.Time{}, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), c.adjust)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, k := range out {
+			if k.Close != c.close[i] || k.Last != c.last[i] || k.Open != k.Close {
+				t.Errorf("[%s][%d] 收盘价 %d 前收盘价 %d, 期望 %d %d", c.adjust, i, k.Close, k.Last, c.close[i], c.last[i])
+			}
+		}
+	}
+	//不修改原数据
+	if ks[0].Close != 10000 {
+		t.Error("复权修改了原K线")
+	}
+	if _, err := d.Adjust("sz000001", ks, "xxx"); err == nil {
+		t.Error("未知的复权类型需要返回错误")
+	}
+}
+
+// TestAdjustInvalidate 除权除息日的日线更新到数据库之后,需要重新计算复权事件
+func TestAdjustInvalidate(t *testing.T) {
+	d, ks := testAdjustData(t)
+	writeDayKlines(t, d, "sz000001", ks[:2])
+	events, err := d.adjustEvents("sz000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("除权除息日还没有日线时的事件 %+v", events)
+	}
+
+	writeDayKlines(t, d, "sz000001", ks[2:])
+	d.Invalidate()
+	if events, err = d.adjustEvents("sz000001"); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !near(events[0].Ratio, 0.95) || !near(events[1].Ratio, 0.5) {
+		t.Errorf("数据更新后的事件 %+v", events)
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
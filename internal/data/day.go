@@ -0,0 +1,16 @@
+package data
+
+import (
+	"time"
+)
+
+// DayStart 当天0点的时间戳
+func DayStart(t time.Time) int64 {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Unix()
+}
+
+// SameDay 两个时间戳是否是同一天,小于等于0表示没有时间
+func SameDay(a, b int64) bool {
+	return a > 0 && b > 0 && DayStart(time.Unix(a, 0)) == DayStart(time.Unix(b, 0))
+}
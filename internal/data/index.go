@@ -0,0 +1,66 @@
+package data
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/injoyai/goutil/oss"
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+)
+
+// indexCache 指数日线缓存,指数数据不在本地保存,每天从服务器拉取一次
+var indexCache = struct {
+	sync.Mutex
+	m map[string]*indexKlines
+}{m: map[string]*indexKlines{}}
+
+type indexKlines struct {
+	date string
+	ks   extend.Klines
+}
+
+// GetIndexDayKlines 获取指数日线,例sh000300,sh000001
+// 本地有数据时使用本地数据,否则从服务器拉取并缓存到当天结束
+func (this *Data) GetIndexDayKlines(code string, start, end time.Time) (extend.Klines, error) {
+	if oss.Exists(filepath.Join(this.KlineDir(), code+".db")) {
+		return this.GetDayKlines(code, start, end)
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	indexCache.Lock()
+	cache := indexCache.m[code]
+	indexCache.Unlock()
+
+	if cache == nil || cache.date != today {
+		var ks extend.Klines
+		err := this.Do(func(c *tdx.Client) error {
+			resp, err := c.GetIndexDayAll(code)
+			if err != nil {
+				return err
+			}
+			ks = make(extend.Klines, 0, len(resp.List))
+			for _, k := range resp.List {
+				ks = append(ks, &extend.Kline{Unix: k.Time.Unix(), Kline: k})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("获取指数[%s]失败: %w", code, err)
+		}
+		cache = &indexKlines{date: today, ks: ks}
+		indexCache.Lock()
+		indexCache.m[code] = cache
+		indexCache.Unlock()
+	}
+
+	out := extend.Klines{}
+	for _, k := range cache.ks {
+		if k.Unix > start.Unix() && k.Unix < end.Unix() {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
@@ -2,6 +2,21 @@ package data
 
 import (
 	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
 )
 
 type Info = extend.Info
+
+// NewInfo 用某一根K线生成基本信息,价格,换手率和市值都是这根K线当时的数据
+func NewInfo(code, name string, k *extend.Kline) Info {
+	return Info{
+		Code:       code,
+		Name:       name,
+		Price:      k.Close,
+		Turnover:   k.Turnover,
+		FloatStock: k.FloatStock,
+		TotalStock: k.TotalStock,
+		FloatValue: protocol.Price(k.FloatStock) * k.Close,
+		TotalValue: protocol.Price(k.TotalStock) * k.Close,
+	}
+}
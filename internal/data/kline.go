@@ -1,19 +1,15 @@
 package data
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/injoyai/base/chans"
-	"github.com/injoyai/goutil/database/sqlite"
-	"github.com/injoyai/goutil/oss"
 	"github.com/injoyai/logs"
 	"github.com/injoyai/tdx"
 	"github.com/injoyai/tdx/extend"
-	"github.com/injoyai/tdx/protocol"
 )
 
 const (
@@ -23,6 +19,10 @@ const (
 	BaseInfo = "base-info"
 )
 
+const (
+	DefaultMaxOpen = 256 //默认最多同时打开的数据库文件数量
+)
+
 type (
 	Handler = func(info Info, day, min extend.Klines)
 )
@@ -36,6 +36,7 @@ func NewManage(m *tdx.Manage) (*Data, error) {
 		Retry:       tdx.DefaultRetry,
 		Goroutines:  50,
 		DatabaseDir: tdx.DefaultDatabaseDir,
+		MaxOpen:     DefaultMaxOpen,
 		Manage:      m,
 		Updated:     updated,
 	}, nil
@@ -45,8 +46,12 @@ type Data struct {
 	Retry       int
 	Goroutines  int
 	DatabaseDir string
+	MaxOpen     int  //最多同时打开的数据库文件数量,超过后关闭最久未使用的
+	Cache       bool //日线是否缓存到内存,启动时预热,数据更新后失效
 	*tdx.Manage
 	*Updated
+	repo  repository
+	hooks []func() //数据更新完成后执行
 }
 
 func (this *Data) KlineDir() string {
@@ -58,40 +63,25 @@ func (this *Data) GetStockCodes() []string {
 }
 
 func (this *Data) GetDayKlines(code string, start, end time.Time) (extend.Klines, error) {
-	filename := filepath.Join(this.KlineDir(), code+".db")
-	if !oss.Exists(filename) {
-		return nil, fmt.Errorf("股票[%s]数据不存在", code)
+	if this.Cache {
+		return this.cachedDayKlines(code, start, end)
 	}
-	db, err := sqlite.NewXorm(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-	data := extend.Klines{}
-	err = db.Table("DayKline").Where("Unix>? and Unix<?", start.Unix(), end.Unix()).
-		Asc("Unix").Find(&data)
-	logs.PrintErr(err)
-	return data, err
+	return this.query(code, "DayKline", start.Unix(), end.Unix())
 }
 
 func (this *Data) GetMinKlines(code string, start, end time.Time) (extend.Klines, error) {
-	filename := filepath.Join(this.KlineDir(), code+".db")
-	if !oss.Exists(filename) {
-		return nil, fmt.Errorf("股票[%s]数据不存在", code)
-	}
-	db, err := sqlite.NewXorm(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer db.Close()
-	data := extend.Klines{}
-	err = db.Table("MinuteKline").Where("Unix>? and Unix<?", start.Unix(), end.Unix()).
-		Asc("Unix").Find(&data)
-	logs.PrintErr(err)
-	return data, err
+	return this.query(code, "MinuteKline", start.Unix(), end.Unix())
 }
 
 func (this *Data) RangeKlines(limit int, start, end time.Time, f Handler) error {
+	return this.RangeKlinesAdjust(limit, start, end, AdjustNone, f)
+}
+
+// RangeKlinesAdjust 遍历全部股票的日线,adjust为复权类型
+func (this *Data) RangeKlinesAdjust(limit int, start, end time.Time, adjust string, f Handler) error {
+	if err := CheckAdjust(adjust); err != nil {
+		return err
+	}
 
 	es, err := os.ReadDir(this.KlineDir())
 	if err != nil {
@@ -116,16 +106,13 @@ func (this *Data) RangeKlines(limit int, start, end time.Time, f Handler) error
 			if len(dayKlines) == 0 {
 				return
 			}
-			last := dayKlines[len(dayKlines)-1]
-			info := Info{
-				Code:       code,
-				Name:       this.Codes.GetName(code),
-				Price:      last.Close,
-				Turnover:   last.Turnover,
-				FloatStock: last.FloatStock,
-				TotalStock: last.TotalStock,
-				FloatValue: protocol.Price(last.FloatStock) * last.Close,
-				TotalValue: protocol.Price(last.TotalStock) * last.Close,
+			info := NewInfo(code, this.Codes.GetName(code), dayKlines[len(dayKlines)-1])
+
+			//基本信息使用实际价格,K线按需复权
+			dayKlines, err = this.Adjust(code, dayKlines, adjust)
+			if err != nil {
+				logs.Err(err)
+				return
 			}
 
 			var minKlines extend.Klines
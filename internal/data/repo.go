@@ -0,0 +1,373 @@
+package data
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/base/chans"
+	"github.com/injoyai/goutil/database/sqlite"
+	"github.com/injoyai/goutil/database/xorms"
+	"github.com/injoyai/goutil/oss"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Reader K线读取接口,策略,选股和回测共用同一个数据源
+type Reader interface {
+	GetDayKlines(code string, start, end time.Time) (extend.Klines, error)
+	GetDayKlinesAdjust(code string, start, end time.Time, adjust string) (extend.Klines, error)
+	GetMinKlines(code string, start, end time.Time) (extend.Klines, error)
+	GetKlines(code, period string, start, end time.Time) (extend.Klines, error)
+	Adjust(code string, ks extend.Klines, adjust string) (extend.Klines, error)
+	GetIndexDayKlines(code string, start, end time.Time) (extend.Klines, error)
+	RangeKlinesAdjust(limit int, start, end time.Time, adjust string, f Handler) error
+}
+
+var _ Reader = (*Data)(nil)
+
+// repository K线仓库,复用打开的数据库文件,可选把日线常驻内存
+type repository struct {
+	once    sync.Once
+	handles *handlePool
+	days    *dayCache
+	adjusts *adjustCache
+}
+
+func (this *Data) repository() *repository {
+	this.repo.once.Do(func() {
+		this.repo.handles = newHandlePool(this.MaxOpen)
+		this.repo.days = &dayCache{m: map[string]*dayColumns{}}
+		this.repo.adjusts = &adjustCache{m: map[string][]adjustEvent{}}
+	})
+	return &this.repo
+}
+
+// query 使用连接池中的数据库查询时间在(start,end)之间的K线
+func (this *Data) query(code, table string, start, end int64) (extend.Klines, error) {
+	return this.find(code, table, "Unix>? and Unix<?", start, end)
+}
+
+// queryAll 查询全部K线,用于加载日线缓存
+func (this *Data) queryAll(code, table string) (extend.Klines, error) {
+	return this.find(code, table, "")
+}
+
+// find 使用连接池中的数据库查询K线,where为空时不限制条件
+func (this *Data) find(code, table, where string, args ...any) (extend.Klines, error) {
+	filename := filepath.Join(this.KlineDir(), code+".db")
+	if !oss.Exists(filename) {
+		return nil, fmt.Errorf("股票[%s]数据不存在", code)
+	}
+	pool := this.repository().handles
+	h, err := pool.get(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.put(h)
+	data := extend.Klines{}
+	session := h.db.Table(table)
+	if where != "" {
+		session = session.Where(where, args...)
+	}
+	err = session.Asc("Unix").Find(&data)
+	logs.PrintErr(err)
+	return data, err
+}
+
+// cachedDayKlines 从内存缓存读取日线,未缓存时加载全部历史日线
+func (this *Data) cachedDayKlines(code string, start, end time.Time) (extend.Klines, error) {
+	days := this.repository().days
+	if cols := days.get(code); cols != nil {
+		return cols.klines(start.Unix(), end.Unix()), nil
+	}
+	cols, err := this.loadDayColumns(code)
+	if err != nil {
+		return nil, err
+	}
+	return cols.klines(start.Unix(), end.Unix()), nil
+}
+
+func (this *Data) loadDayColumns(code string) (*dayColumns, error) {
+	days := this.repository().days
+	gen := days.generation()
+	ks, err := this.queryAll(code, "DayKline")
+	if err != nil {
+		return nil, err
+	}
+	cols := newDayColumns(ks)
+	days.set(code, cols, gen)
+	return cols, nil
+}
+
+// WarmCache 预热日线缓存,只加载还未缓存的股票,未开启缓存时不处理
+func (this *Data) WarmCache() error {
+	if !this.Cache {
+		return nil
+	}
+	es, err := os.ReadDir(this.KlineDir())
+	if err != nil {
+		return err
+	}
+	days := this.repository().days
+	start := time.Now()
+	wg := chans.NewWaitLimit(max(this.Goroutines, 1))
+	for _, e := range es {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".db") {
+			continue
+		}
+		code := strings.TrimSuffix(e.Name(), ".db")
+		if days.get(code) != nil {
+			continue
+		}
+		wg.Add()
+		go func() {
+			defer wg.Done()
+			_, err := this.loadDayColumns(code)
+			logs.PrintErr(err)
+		}()
+	}
+	wg.Wait()
+	logs.Infof("日线缓存预热完成,股票数量: %d,耗时: %s\n", days.len(), time.Since(start))
+	return nil
+}
+
+// Invalidate 数据更新后清空日线和复权缓存并关闭打开的数据库,下次读取时重新加载
+func (this *Data) Invalidate() {
+	repo := this.repository()
+	repo.days.reset()
+	repo.adjusts.reset()
+	repo.handles.closeAll()
+}
+
+/*
+
+
+
+ */
+
+// handlePool 打开的数据库文件,按最近使用淘汰,被淘汰时还在使用的等使用完再关闭
+type handlePool struct {
+	mu  sync.Mutex
+	max int
+	ll  *list.List //前面是最近使用的
+	m   map[string]*list.Element
+}
+
+type handle struct {
+	filename string
+	db       *xorms.Engine
+	refs     int  //正在使用的数量
+	evicted  bool //已经被淘汰,使用完后关闭
+}
+
+func newHandlePool(max int) *handlePool {
+	if max <= 0 {
+		max = DefaultMaxOpen
+	}
+	return &handlePool{max: max, ll: list.New(), m: map[string]*list.Element{}}
+}
+
+// get 获取数据库,使用完需要调用put
+func (this *handlePool) get(filename string) (*handle, error) {
+	this.mu.Lock()
+	if e, ok := this.m[filename]; ok {
+		h := e.Value.(*handle)
+		h.refs++
+		this.ll.MoveToFront(e)
+		this.mu.Unlock()
+		return h, nil
+	}
+	this.mu.Unlock()
+
+	//打开文件比较耗时,不占用锁
+	db, err := sqlite.NewXorm(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if e, ok := this.m[filename]; ok {
+		//其他协程已经打开了
+		db.Close()
+		h := e.Value.(*handle)
+		h.refs++
+		this.ll.MoveToFront(e)
+		return h, nil
+	}
+	h := &handle{filename: filename, db: db, refs: 1}
+	this.m[filename] = this.ll.PushFront(h)
+	for this.ll.Len() > this.max {
+		this.evict(this.ll.Back())
+	}
+	return h, nil
+}
+
+// put 使用完毕
+func (this *handlePool) put(h *handle) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	h.refs--
+	if h.evicted && h.refs == 0 {
+		h.db.Close()
+	}
+}
+
+// closeAll 关闭全部数据库,正在使用的等使用完再关闭
+func (this *handlePool) closeAll() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for this.ll.Len() > 0 {
+		this.evict(this.ll.Back())
+	}
+}
+
+func (this *handlePool) evict(e *list.Element) {
+	h := e.Value.(*handle)
+	this.ll.Remove(e)
+	delete(this.m, h.filename)
+	h.evicted = true
+	if h.refs == 0 {
+		h.db.Close()
+	}
+}
+
+/*
+
+
+
+ */
+
+// dayCache 日线内存缓存,按列存储,减少内存占用和GC压力
+type dayCache struct {
+	mu  sync.RWMutex
+	gen int //每次清空加1,防止清空前开始加载的旧数据写入缓存
+	m   map[string]*dayColumns
+}
+
+func (this *dayCache) get(code string) *dayColumns {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.m[code]
+}
+
+func (this *dayCache) generation() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.gen
+}
+
+func (this *dayCache) set(code string, cols *dayColumns, gen int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if gen == this.gen {
+		this.m[code] = cols
+	}
+}
+
+func (this *dayCache) reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.gen++
+	this.m = map[string]*dayColumns{}
+}
+
+func (this *dayCache) len() int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return len(this.m)
+}
+
+// dayColumns 一只股票的全部日线,按时间升序
+// 价格使用int32存储,单位厘,最大可以表示200多万元
+type dayColumns struct {
+	Unix       []int64
+	Last       []int32
+	Open       []int32
+	High       []int32
+	Low        []int32
+	Close      []int32
+	Order      []int32
+	Volume     []int64
+	Amount     []int64
+	Turnover   []float64
+	FloatStock []int64
+	TotalStock []int64
+}
+
+func newDayColumns(ks extend.Klines) *dayColumns {
+	n := len(ks)
+	cols := &dayColumns{
+		Unix:       make([]int64, n),
+		Last:       make([]int32, n),
+		Open:       make([]int32, n),
+		High:       make([]int32, n),
+		Low:        make([]int32, n),
+		Close:      make([]int32, n),
+		Order:      make([]int32, n),
+		Volume:     make([]int64, n),
+		Amount:     make([]int64, n),
+		Turnover:   make([]float64, n),
+		FloatStock: make([]int64, n),
+		TotalStock: make([]int64, n),
+	}
+	for i, k := range ks {
+		cols.Unix[i] = k.Unix
+		cols.Last[i] = int32(k.Last)
+		cols.Open[i] = int32(k.Open)
+		cols.High[i] = int32(k.High)
+		cols.Low[i] = int32(k.Low)
+		cols.Close[i] = int32(k.Close)
+		cols.Order[i] = int32(k.Order)
+		cols.Volume[i] = k.Volume
+		cols.Amount[i] = int64(k.Amount)
+		cols.Turnover[i] = k.Turnover
+		cols.FloatStock[i] = k.FloatStock
+		cols.TotalStock[i] = k.TotalStock
+	}
+	return cols
+}
+
+// klines 生成时间在(start,end)之间的K线,每次返回新的数据,调用方可以随意修改
+func (this *dayColumns) klines(start, end int64) extend.Klines {
+	i := sort.Search(len(this.Unix), func(i int) bool { return this.Unix[i] > start })
+	j := sort.Search(len(this.Unix), func(j int) bool { return this.Unix[j] >= end })
+	if j <= i {
+		return extend.Klines{}
+	}
+	n := j - i
+	//一次性分配,避免每根K线分配两次内存
+	ks := make([]extend.Kline, n)
+	ps := make([]protocol.Kline, n)
+	out := make(extend.Klines, n)
+	for x := 0; x < n; x++ {
+		y := i + x
+		ps[x] = protocol.Kline{
+			Last:   protocol.Price(this.Last[y]),
+			Open:   protocol.Price(this.Open[y]),
+			High:   protocol.Price(this.High[y]),
+			Low:    protocol.Price(this.Low[y]),
+			Close:  protocol.Price(this.Close[y]),
+			Order:  int(this.Order[y]),
+			Volume: this.Volume[y],
+			Amount: protocol.Price(this.Amount[y]),
+			Time:   time.Unix(this.Unix[y], 0),
+		}
+		ks[x] = extend.Kline{
+			Unix:       this.Unix[y],
+			Kline:      &ps[x],
+			Turnover:   this.Turnover[y],
+			FloatStock: this.FloatStock[y],
+			TotalStock: this.TotalStock[y],
+		}
+		out[x] = &ks[x]
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/injoyai/goutil/database/sqlite"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// testData 在临时目录生成一只股票5天的日线
+func testData(t *testing.T) (*Data, []time.Time) {
+	d := &Data{DatabaseDir: t.TempDir(), MaxOpen: 4}
+	var times []time.Time
+	var ks extend.Klines
+	for i := 0; i < 5; i++ {
+		ti := time.Date(2024, 1, 2+i, 15, 0, 0, 0, time.Local)
+		ks = append(ks, &extend.Kline{Unix: ti.Unix(), Kline: &protocol.Kline{Close: protocol.Price(10000 + i), Time: ti}})
+		times = append(times, ti)
+	}
+	writeDayKlines(t, d, "sz000001", ks)
+	t.Cleanup(d.Invalidate)
+	return d, times
+}
+
+// writeDayKlines 把日线写入股票的数据库
+func writeDayKlines(t *testing.T, d *Data, code string, ks extend.Klines) {
+	if err := os.MkdirAll(d.KlineDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqlite.NewXorm(filepath.Join(d.KlineDir(), code+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Sync2(extend.NewKlineTable("DayKline", nil)); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range ks {
+		if _, err = db.Table("DayKline").Insert(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestGetDayKlinesRange 数据库和缓存都按(start,end)过滤,零值的结束时间不返回K线
+func TestGetDayKlinesRange(t *testing.T) {
+	for _, cache := range []bool{false, true} {
+		d, times := testData(t)
+		d.Cache = cache
+		for _, c := range []struct {
+			name       string
+			start, end time.Time
+			want       int
+		}{
+			{"全部", time.Time{}, times[4].AddDate(0, 0, 1), 5},
+			{"区间", times[0], times[3], 2},
+			{"包含结束当天", times[1].AddDate(0, 0, -1), times[2].AddDate(0, 0, 1), 2},
+			{"零值", time.Time{}, time.Time{}, 0},
+		} {
+			ks, err := d.GetDayKlines("sz000001", c.start, c.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ks) != c.want {
+				t.Errorf("[缓存:%v][%s] K线数量 %d, 期望 %d", cache, c.name, len(ks), c.want)
+			}
+		}
+	}
+}
+
+// TestLoadDayColumns 缓存加载全部历史日线
+func TestLoadDayColumns(t *testing.T) {
+	d, times := testData(t)
+	cols, err := d.loadDayColumns("sz000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols.Unix) != 5 || cols.Unix[0] != times[0].Unix() || cols.Close[4] != 10004 {
+		t.Errorf("缓存的日线 %v %v", cols.Unix, cols.Close)
+	}
+	if _, err = d.loadDayColumns("sz000002"); err == nil {
+		t.Error("不存在的股票需要返回错误")
+	}
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/injoyai/tdx/extend"
+)
+
+// GetKlines 获取指定周期的K线,周期见extend.Day,extend.Week,extend.Minute5等,
+// 本地只保存了日线和1分钟线,其他周期由这两个周期合成
+func (this *Data) GetKlines(code, period string, start, end time.Time) (extend.Klines, error) {
+	//往前多取一个周期,保证第一根K线是完整的
+	var from time.Time
+	var load func(code string, start, end time.Time) (extend.Klines, error)
+	switch period {
+	case extend.Day, "":
+		return this.GetDayKlines(code, start, end)
+	case extend.Minute:
+		return this.GetMinKlines(code, start, end)
+	case extend.Week:
+		from, load = start.AddDate(0, 0, -7), this.GetDayKlines
+	case extend.Month:
+		from, load = start.AddDate(0, -1, 0), this.GetDayKlines
+	case extend.Quarter:
+		from, load = start.AddDate(0, -3, 0), this.GetDayKlines
+	case extend.Year:
+		from, load = start.AddDate(-1, 0, 0), this.GetDayKlines
+	case extend.Minute5, extend.Minute15, extend.Minute30, extend.Minute60:
+		from, load = start.AddDate(0, 0, -1), this.GetMinKlines
+	default:
+		return nil, fmt.Errorf("未知的K线周期[%s]", period)
+	}
+	ks, err := load(code, from, end)
+	if err != nil {
+		return nil, err
+	}
+	ks, err = Resample(ks, period)
+	if err != nil {
+		return nil, err
+	}
+	out := extend.Klines{}
+	for _, k := range ks {
+		if k.Unix > start.Unix() {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+// Resample 合成K线,周线/月线/季线/年线由日线合成,5/15/30/60分钟线由1分钟线合成
+// 最后一个周期没有结束时,合成的是截至最后一根K线的数据,回测时不会用到未来数据
+func Resample(ks extend.Klines, period string) (extend.Klines, error) {
+	var key func(t time.Time) int64
+	switch period {
+	case extend.Day, extend.Minute:
+		return ks, nil
+	case extend.Week:
+		key = func(t time.Time) int64 {
+			y, w := t.ISOWeek()
+			return int64(y*100 + w)
+		}
+	case extend.Month:
+		key = func(t time.Time) int64 { return int64(t.Year()*100 + int(t.Month())) }
+	case extend.Quarter:
+		key = func(t time.Time) int64 { return int64(t.Year()*100 + (int(t.Month())-1)/3) }
+	case extend.Year:
+		key = func(t time.Time) int64 { return int64(t.Year()) }
+	case extend.Minute5, extend.Minute15, extend.Minute30, extend.Minute60:
+		n := map[string]int{extend.Minute5: 5, extend.Minute15: 15, extend.Minute30: 30, extend.Minute60: 60}[period]
+		return resampleMinute(ks, n), nil
+	default:
+		return nil, fmt.Errorf("未知的K线周期[%s]", period)
+	}
+
+	out := extend.Klines{}
+	var cur *extend.Kline
+	var last int64
+	for _, k := range ks {
+		if i := key(k.Time); cur == nil || i != last {
+			cur = newBar(k, k.Time)
+			out = append(out, cur)
+			last = i
+			continue
+		}
+		mergeBar(cur, k, k.Time)
+	}
+	return out, nil
+}
+
+// resampleMinute 1分钟线合成n分钟线,按交易时间分组,上午9:30-11:30,下午13:00-15:00,
+// 时间为周期的结束时间,例5分钟线为9:35,9:40...,9:30的集合竞价并入第一根
+func resampleMinute(ks extend.Klines, n int) extend.Klines {
+	out := extend.Klines{}
+	var cur *extend.Kline
+	var lastDay, lastBucket int64 = -1, -1
+	for _, k := range ks {
+		y, m, d := k.Time.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, k.Time.Location())
+		//距离开盘的交易分钟数
+		minute := k.Time.Hour()*60 + k.Time.Minute()
+		elapsed := minute - (9*60 + 30)
+		if minute >= 13*60 {
+			elapsed = 120 + minute - 13*60
+		} else if elapsed > 120 {
+			elapsed = 120
+		}
+		bucket := int64((max(elapsed, 1) + n - 1) / n)
+		end := int(bucket) * n
+		var t time.Time
+		if end <= 120 {
+			t = day.Add(time.Duration(9*60+30+end) * time.Minute)
+		} else {
+			t = day.Add(time.Duration(13*60+end-120) * time.Minute)
+		}
+		if cur == nil || day.Unix() != lastDay || bucket != lastBucket {
+			cur = newBar(k, t)
+			out = append(out, cur)
+			lastDay, lastBucket = day.Unix(), bucket
+			continue
+		}
+		mergeBar(cur, k, t)
+	}
+	return out
+}
+
+func newBar(k *extend.Kline, t time.Time) *extend.Kline {
+	pk := *k.Kline
+	pk.Time = t
+	return &extend.Kline{
+		Unix:       t.Unix(),
+		Kline:      &pk,
+		Turnover:   k.Turnover,
+		FloatStock: k.FloatStock,
+		TotalStock: k.TotalStock,
+	}
+}
+
+func mergeBar(cur, k *extend.Kline, t time.Time) {
+	cur.Unix = t.Unix()
+	cur.Time = t
+	cur.High = max(cur.High, k.High)
+	cur.Low = min(cur.Low, k.Low)
+	cur.Close = k.Close
+	cur.Order += k.Order
+	cur.Volume += k.Volume
+	cur.Amount += k.Amount
+	cur.Turnover += k.Turnover
+	cur.FloatStock = k.FloatStock
+	cur.TotalStock = k.TotalStock
+}
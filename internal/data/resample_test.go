@@ -0,0 +1,124 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// resampleBar 开盘价为price,最高价price+1,最低价price-1,收盘价price+0.5
+func resampleBar(t time.Time, price float64, volume int64) *extend.Kline {
+	return &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{
+		Open:   protocol.Yuan(price),
+		High:   protocol.Yuan(price + 1),
+		Low:    protocol.Yuan(price - 1),
+		Close:  protocol.Yuan(price + 0.5),
+		Volume: volume,
+		Time:   t,
+	}}
+}
+
+func TestResample(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 15, 0, 0, 0, time.Local) }
+	minute := func(h, m int) time.Time { return time.Date(2024, 1, 2, h, m, 0, 0, time.Local) }
+
+	// want 合成后的每根K线: 时间,开盘价(第一根),收盘价(最后一根)对应的输入价格,成交量
+	type want struct {
+		t           time.Time
+		first, last float64
+		volume      int64
+	}
+	for _, c := range []struct {
+		name   string
+		period string
+		in     extend.Klines
+		want   []want
+	}{
+		{"周线按自然周分组,时间为最后一根", extend.Week, extend.Klines{
+			resampleBar(day(2024, 1, 4), 10, 1), //周四
+			resampleBar(day(2024, 1, 5), 11, 2), //周五
+			resampleBar(day(2024, 1, 8), 12, 3), //下周一
+			resampleBar(day(2024, 1, 10), 9, 4),
+		}, []want{{day(2024, 1, 5), 10, 11, 3}, {day(2024, 1, 10), 12, 9, 7}}},
+		{"周线跨年按ISO周", extend.Week, extend.Klines{
+			resampleBar(day(2024, 12, 27), 10, 1), //2024年第52周
+			resampleBar(day(2024, 12, 30), 11, 2), //2025年第1周
+			resampleBar(day(2025, 1, 2), 12, 3),
+		}, []want{{day(2024, 12, 27), 10, 10, 1}, {day(2025, 1, 2), 11, 12, 5}}},
+		{"月线", extend.Month, extend.Klines{
+			resampleBar(day(2024, 1, 30), 10, 1),
+			resampleBar(day(2024, 1, 31), 11, 2),
+			resampleBar(day(2024, 2, 1), 12, 3),
+		}, []want{{day(2024, 1, 31), 10, 11, 3}, {day(2024, 2, 1), 12, 12, 3}}},
+		{"季线", extend.Quarter, extend.Klines{
+			resampleBar(day(2024, 3, 29), 10, 1),
+			resampleBar(day(2024, 4, 1), 11, 2),
+			resampleBar(day(2024, 6, 28), 12, 3),
+		}, []want{{day(2024, 3, 29), 10, 10, 1}, {day(2024, 6, 28), 11, 12, 5}}},
+		{"5分钟线,集合竞价并入第一根", extend.Minute5, extend.Klines{
+			resampleBar(minute(9, 30), 10, 1),
+			resampleBar(minute(9, 31), 11, 2),
+			resampleBar(minute(9, 35), 12, 3),
+			resampleBar(minute(9, 36), 13, 4),
+			resampleBar(minute(11, 30), 14, 5),
+			resampleBar(minute(13, 1), 15, 6),
+			resampleBar(minute(15, 0), 16, 7),
+		}, []want{
+			{minute(9, 35), 10, 12, 6},
+			{minute(9, 40), 13, 13, 4},
+			{minute(11, 30), 14, 14, 5},
+			{minute(13, 5), 15, 15, 6},
+			{minute(15, 0), 16, 16, 7},
+		}},
+		{"60分钟线,上午和下午各两根", extend.Minute60, extend.Klines{
+			resampleBar(minute(9, 31), 10, 1),
+			resampleBar(minute(10, 30), 11, 2),
+			resampleBar(minute(10, 31), 12, 3),
+			resampleBar(minute(11, 30), 13, 4),
+			resampleBar(minute(13, 1), 14, 5),
+			resampleBar(minute(14, 1), 15, 6),
+		}, []want{
+			{minute(10, 30), 10, 11, 3},
+			{minute(11, 30), 12, 13, 7},
+			{minute(14, 0), 14, 14, 5},
+			{minute(15, 0), 15, 15, 6},
+		}},
+	} {
+		out, err := Resample(c.in, c.period)
+		if err != nil {
+			t.Fatalf("[%s] %v", c.name, err)
+		}
+		if len(out) != len(c.want) {
+			t.Fatalf("[%s] 合成 %d 根, 期望 %d", c.name, len(out), len(c.want))
+		}
+		for i, w := range c.want {
+			k := out[i]
+			if !k.Time.Equal(w.t) || k.Unix != w.t.Unix() {
+				t.Errorf("[%s][%d] 时间 %v, 期望 %v", c.name, i, k.Time, w.t)
+			}
+			high, low := max(w.first, w.last)+1, min(w.first, w.last)-1
+			if k.Open != protocol.Yuan(w.first) || k.Close != protocol.Yuan(w.last+0.5) || k.Volume != w.volume {
+				t.Errorf("[%s][%d] 开盘 %v, 收盘 %v, 成交量 %d", c.name, i, k.Open, k.Close, k.Volume)
+			}
+			//中间的K线价格在首尾之间,最高最低价由首尾决定
+			if k.High != protocol.Yuan(high) || k.Low != protocol.Yuan(low) {
+				t.Errorf("[%s][%d] 最高 %v, 最低 %v", c.name, i, k.High, k.Low)
+			}
+		}
+	}
+
+	//合成不修改输入的K线
+	in := extend.Klines{resampleBar(day(2024, 1, 4), 10, 1), resampleBar(day(2024, 1, 5), 11, 2)}
+	if _, err := Resample(in, extend.Week); err != nil {
+		t.Fatal(err)
+	}
+	if in[0].Volume != 1 || !in[0].Time.Equal(day(2024, 1, 4)) {
+		t.Error("修改了输入的K线")
+	}
+
+	if _, err := Resample(in, "2d"); err == nil {
+		t.Error("未知周期需要返回错误")
+	}
+}
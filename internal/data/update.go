@@ -29,9 +29,11 @@ func (this *Data) Start() {
 	cr := cron.New(cron.WithSeconds())
 	cr.AddFunc("0 20 15 * * *", func() {
 		logs.PrintErr(this.Update(p))
+		logs.PrintErr(this.WarmCache())
 	})
 
 	logs.PrintErr(this.Update(p))
+	go func() { logs.PrintErr(this.WarmCache()) }()
 
 	cr.Start()
 }
@@ -39,14 +41,26 @@ func (this *Data) Start() {
 func (this *Data) Update(p *extend.PullKline) error {
 	if updated, _ := this.Updated.Updated(Kline); !updated {
 		err := p.Update(this.Manage)
+		//更新过程中可能已经写入了部分数据,缓存都需要失效
+		this.Invalidate()
 		if err != nil {
 			return err
 		}
-		return this.Updated.Update(Kline)
+		if err = this.Updated.Update(Kline); err != nil {
+			return err
+		}
+		for _, f := range this.hooks {
+			go f()
+		}
 	}
 	return nil
 }
 
+// OnUpdated 注册数据更新完成后执行的函数,例如定时选股和提醒,需要在Start之前注册
+func (this *Data) OnUpdated(f func()) {
+	this.hooks = append(this.hooks, f)
+}
+
 //// updateDayKline 更新日线数据
 //func (this *Data) updateDayKlineAll() error {
 //	updated, err := this.Updated.Updated(DayKline)
@@ -0,0 +1,57 @@
+package indicator
+
+import (
+	"math"
+)
+
+// TR 真实波幅,MAX(MAX(H-L,ABS(H-REF(C,1))),ABS(L-REF(C,1)))
+func TR(bars []Bar) []float64 {
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		if i == 0 {
+			out[i] = b.High - b.Low
+			continue
+		}
+		out[i] = trueRange(b, bars[i-1].Close)
+	}
+	return out
+}
+
+// ATR 平均真实波幅,MA(TR,N)
+func ATR(bars []Bar, n int) []float64 {
+	s := NewATRStream(n)
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		out[i] = s.Update(b)
+	}
+	return out
+}
+
+func trueRange(b Bar, lastClose float64) float64 {
+	return math.Max(math.Max(b.High-b.Low, math.Abs(b.High-lastClose)), math.Abs(b.Low-lastClose))
+}
+
+func NewATRStream(n int) *ATRStream {
+	return &ATRStream{ma: NewSMAStream(n)}
+}
+
+// ATRStream 增量ATR
+type ATRStream struct {
+	ma    *SMAStream
+	last  float64
+	count int
+}
+
+func (this *ATRStream) Update(b Bar) float64 {
+	tr := b.High - b.Low
+	if this.count > 0 {
+		tr = trueRange(b, this.last)
+	}
+	this.count++
+	this.last = b.Close
+	return this.ma.Update(tr)
+}
+
+func (this *ATRStream) Value() float64 { return this.ma.Value() }
+
+func (this *ATRStream) Ready() bool { return this.ma.Ready() }
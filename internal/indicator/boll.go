@@ -0,0 +1,53 @@
+package indicator
+
+import (
+	"math"
+)
+
+// BOLL 布林带,常用参数20,2
+// mid=MA(C,N), upper=mid+k*STD(C,N), lower=mid-k*STD(C,N)
+func BOLL(xs []float64, n int, k float64) (upper, mid, lower []float64) {
+	s := NewBOLLStream(n, k)
+	upper = make([]float64, len(xs))
+	mid = make([]float64, len(xs))
+	lower = make([]float64, len(xs))
+	for i, v := range xs {
+		upper[i], mid[i], lower[i] = s.Update(v)
+	}
+	return
+}
+
+func NewBOLLStream(n int, k float64) *BOLLStream {
+	return &BOLLStream{w: newWindow(n), k: k}
+}
+
+// BOLLStream 增量布林带
+type BOLLStream struct {
+	w                 *window
+	k                 float64
+	Upper, Mid, Lower float64
+}
+
+func (this *BOLLStream) Update(v float64) (upper, mid, lower float64) {
+	this.w.push(v)
+	if !this.w.full() {
+		return
+	}
+	n := this.w.len()
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += this.w.at(i)
+	}
+	this.Mid = sum / float64(n)
+	var sd float64
+	for i := 0; i < n; i++ {
+		d := this.w.at(i) - this.Mid
+		sd += d * d
+	}
+	sd = math.Sqrt(sd / float64(n))
+	this.Upper = this.Mid + this.k*sd
+	this.Lower = this.Mid - this.k*sd
+	return this.Upper, this.Mid, this.Lower
+}
+
+func (this *BOLLStream) Ready() bool { return this.w.full() }
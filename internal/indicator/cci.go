@@ -0,0 +1,55 @@
+package indicator
+
+import (
+	"math"
+)
+
+// CCI 顺势指标,常用参数14
+// TP=(H+L+C)/3, CCI=(TP-MA(TP,N))/(0.015*AVEDEV(TP,N))
+func CCI(bars []Bar, n int) []float64 {
+	s := NewCCIStream(n)
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		out[i] = s.Update(b)
+	}
+	return out
+}
+
+func NewCCIStream(n int) *CCIStream {
+	return &CCIStream{w: newWindow(n)}
+}
+
+// CCIStream 增量CCI
+type CCIStream struct {
+	w     *window
+	value float64
+}
+
+func (this *CCIStream) Update(b Bar) float64 {
+	tp := (b.High + b.Low + b.Close) / 3
+	this.w.push(tp)
+	if !this.w.full() {
+		return this.value
+	}
+	n := this.w.len()
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += this.w.at(i)
+	}
+	ma := sum / float64(n)
+	var dev float64
+	for i := 0; i < n; i++ {
+		dev += math.Abs(this.w.at(i) - ma)
+	}
+	dev /= float64(n)
+	if dev == 0 {
+		this.value = 0
+	} else {
+		this.value = (tp - ma) / (0.015 * dev)
+	}
+	return this.value
+}
+
+func (this *CCIStream) Value() float64 { return this.value }
+
+func (this *CCIStream) Ready() bool { return this.w.full() }
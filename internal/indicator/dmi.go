@@ -0,0 +1,96 @@
+package indicator
+
+import (
+	"math"
+)
+
+// DMI 趋向指标,常用参数14,6,通达信算法
+// TR=SUM(真实波幅,N), HD=H-REF(H,1), LD=REF(L,1)-L
+// PDI=SUM(IF(HD>0&&HD>LD,HD,0),N)*100/TR, MDI=SUM(IF(LD>0&&LD>HD,LD,0),N)*100/TR
+// ADX=MA(ABS(MDI-PDI)/(MDI+PDI)*100,M), ADXR=(ADX+REF(ADX,M))/2
+func DMI(bars []Bar, n, m int) (pdi, mdi, adx, adxr []float64) {
+	s := NewDMIStream(n, m)
+	pdi = make([]float64, len(bars))
+	mdi = make([]float64, len(bars))
+	adx = make([]float64, len(bars))
+	adxr = make([]float64, len(bars))
+	for i, b := range bars {
+		pdi[i], mdi[i], adx[i], adxr[i] = s.Update(b)
+	}
+	return
+}
+
+func NewDMIStream(n, m int) *DMIStream {
+	return &DMIStream{
+		tr:  newSum(n),
+		dmp: newSum(n),
+		dmm: newSum(n),
+		adx: NewSMAStream(m),
+		ref: NewREFStream(m),
+	}
+}
+
+// DMIStream 增量DMI
+type DMIStream struct {
+	tr, dmp, dmm        *sum
+	adx                 *SMAStream
+	ref                 *REFStream
+	last                Bar
+	count               int
+	PDI, MDI, ADX, ADXR float64
+}
+
+func (this *DMIStream) Update(b Bar) (pdi, mdi, adx, adxr float64) {
+	defer func() { this.last = b; this.count++ }()
+	if this.count == 0 {
+		return
+	}
+	hd := b.High - this.last.High
+	ld := this.last.Low - b.Low
+	tr := this.tr.update(trueRange(b, this.last.Close))
+	dmp := this.dmp.update(conditional(hd > 0 && hd > ld, hd))
+	dmm := this.dmm.update(conditional(ld > 0 && ld > hd, ld))
+	if !this.tr.full() || tr == 0 {
+		return
+	}
+	this.PDI = dmp * 100 / tr
+	this.MDI = dmm * 100 / tr
+	dx := 0.0
+	if this.PDI+this.MDI > 0 {
+		dx = math.Abs(this.MDI-this.PDI) / (this.MDI + this.PDI) * 100
+	}
+	this.ADX = this.adx.Update(dx)
+	if this.adx.Ready() {
+		ref := this.ref.Update(this.ADX)
+		if this.ref.Ready() {
+			this.ADXR = (this.ADX + ref) / 2
+		}
+	}
+	return this.PDI, this.MDI, this.ADX, this.ADXR
+}
+
+func conditional(b bool, v float64) float64 {
+	if b {
+		return v
+	}
+	return 0
+}
+
+// sum 滑动窗口求和
+type sum struct {
+	w   *window
+	sum float64
+}
+
+func newSum(n int) *sum { return &sum{w: newWindow(n)} }
+
+func (this *sum) update(v float64) float64 {
+	out, full := this.w.push(v)
+	this.sum += v
+	if full {
+		this.sum -= out
+	}
+	return this.sum
+}
+
+func (this *sum) full() bool { return this.w.full() }
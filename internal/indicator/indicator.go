@@ -0,0 +1,146 @@
+// Package indicator 技术指标库
+//
+// 每个指标都有两种用法:
+//  1. 全序列计算,例 SMA(closes, 5),输入完整序列,输出等长序列,移动平均类指标数据不足的位置为0
+//  2. 增量计算,例 NewSMAStream(5),每次Update一个新值,输出最新的指标值,适合逐根K线回测
+//
+// 全序列计算内部使用增量计算实现,两种用法的结果一致
+package indicator
+
+import (
+	"github.com/injoyai/tdx/extend"
+)
+
+// Bar 指标计算使用的K线,价格单位元
+type Bar struct {
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+	Amount float64
+}
+
+// NewBar 从K线转换
+func NewBar(k *extend.Kline) Bar {
+	return Bar{
+		Open:   k.Open.Float64(),
+		High:   k.High.Float64(),
+		Low:    k.Low.Float64(),
+		Close:  k.Close.Float64(),
+		Volume: float64(k.Volume),
+		Amount: k.Amount.Float64(),
+	}
+}
+
+// Bars 从K线序列转换
+func Bars(ks extend.Klines) []Bar {
+	out := make([]Bar, len(ks))
+	for i, k := range ks {
+		out[i] = NewBar(k)
+	}
+	return out
+}
+
+// Opens 开盘价序列
+func Opens(ks extend.Klines) []float64 {
+	out := make([]float64, len(ks))
+	for i, k := range ks {
+		out[i] = k.Open.Float64()
+	}
+	return out
+}
+
+// Highs 最高价序列
+func Highs(ks extend.Klines) []float64 {
+	out := make([]float64, len(ks))
+	for i, k := range ks {
+		out[i] = k.High.Float64()
+	}
+	return out
+}
+
+// Lows 最低价序列
+func Lows(ks extend.Klines) []float64 {
+	out := make([]float64, len(ks))
+	for i, k := range ks {
+		out[i] = k.Low.Float64()
+	}
+	return out
+}
+
+// Closes 收盘价序列
+func Closes(ks extend.Klines) []float64 {
+	out := make([]float64, len(ks))
+	for i, k := range ks {
+		out[i] = k.Close.Float64()
+	}
+	return out
+}
+
+// Volumes 成交量序列
+func Volumes(ks extend.Klines) []float64 {
+	out := make([]float64, len(ks))
+	for i, k := range ks {
+		out[i] = float64(k.Volume)
+	}
+	return out
+}
+
+// Stream 单输入的增量指标
+type Stream interface {
+	Update(v float64) float64 //输入一个新值,返回最新的指标值
+	Value() float64           //最新的指标值
+	Ready() bool              //数据是否足够,例SMA不足N个值时Value为0
+}
+
+// run 使用增量指标计算全序列
+func run(s Stream, xs []float64) []float64 {
+	out := make([]float64, len(xs))
+	for i, v := range xs {
+		out[i] = s.Update(v)
+	}
+	return out
+}
+
+// window 固定长度的环形缓冲区
+type window struct {
+	buf   []float64
+	start int
+	size  int
+}
+
+func newWindow(n int) *window {
+	if n < 1 {
+		n = 1
+	}
+	return &window{buf: make([]float64, n)}
+}
+
+// push 写入新值,窗口满时返回被挤出的值
+func (this *window) push(v float64) (out float64, full bool) {
+	if this.size < len(this.buf) {
+		this.buf[(this.start+this.size)%len(this.buf)] = v
+		this.size++
+		return 0, false
+	}
+	out = this.buf[this.start]
+	this.buf[this.start] = v
+	this.start = (this.start + 1) % len(this.buf)
+	return out, true
+}
+
+// full 窗口是否已满
+func (this *window) full() bool { return this.size == len(this.buf) }
+
+// at 第i个值,0为最早的值
+func (this *window) at(i int) float64 {
+	return this.buf[(this.start+i)%len(this.buf)]
+}
+
+// last 倒数第i个值,0为最新的值
+func (this *window) last(i int) float64 {
+	return this.at(this.size - 1 - i)
+}
+
+func (this *window) len() int { return this.size }
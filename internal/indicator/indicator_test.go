@@ -0,0 +1,172 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+)
+
+// 参考值按公式逐项计算(不使用增量算法),保留6位小数
+var (
+	testClose  = []float64{10, 10.5, 10.2, 10.8, 11.3, 11, 11.6, 12.1, 11.8, 11.2, 11.5, 12.3, 12.8, 12.4, 13}
+	testHigh   = []float64{10.3, 10.8, 10.5, 11.4, 11.6, 11.3, 11.9, 12.6, 12.1, 11.5, 11.8, 12.6, 13.1, 12.7, 13.3}
+	testLow    = []float64{9.6, 10.1, 9.8, 10.4, 10.9, 10.4, 11.2, 11.7, 11.4, 10.9, 11.1, 11.9, 12.4, 12, 12.6}
+	testVolume = []float64{1000, 1200, 900, 1500, 1800, 1100, 1600, 2000, 1300, 1700, 1400, 2100, 2500, 1900, 2200}
+)
+
+func testBars() []Bar {
+	bars := make([]Bar, len(testClose))
+	for i := range bars {
+		bars[i] = Bar{High: testHigh[i], Low: testLow[i], Close: testClose[i], Volume: testVolume[i]}
+	}
+	return bars
+}
+
+func assertNear(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: 长度 %d, 期望 %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-5 {
+			t.Fatalf("%s[%d]: %v, 期望 %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+// reference 输入为testClose或testBars()时的参考值,增量计算的测试也使用
+var reference = map[string][]float64{
+	"SMA(5)":      {0, 0, 0, 0, 10.56, 10.76, 10.98, 11.36, 11.56, 11.54, 11.64, 11.78, 11.92, 12.04, 12.4},
+	"EMA(5)":      {10, 10.166667, 10.177778, 10.385185, 10.690123, 10.793416, 11.062277, 11.408185, 11.53879, 11.42586, 11.450573, 11.733716, 12.089144, 12.192762, 12.461842},
+	"WMA(5)":      {0, 0, 0, 0, 10.753333, 10.9, 11.18, 11.553333, 11.7, 11.58, 11.566667, 11.786667, 12.126667, 12.286667, 12.606667},
+	"TdxSMA(5,3)": {10, 10.3, 10.24, 10.576, 11.0104, 11.00416, 11.361664, 11.804666, 11.801866, 11.440746, 11.476299, 11.970519, 12.468208, 12.427283, 12.770913},
+	"MACD.dif":    {0, 0.107143, 0.065816, 0.170226, 0.29034, 0.227475, 0.301098, 0.391521, 0.303598, 0.100254, 0.077595, 0.229846, 0.358529, 0.267555, 0.325413},
+	"MACD.dea":    {0, 0.042857, 0.052041, 0.099315, 0.175725, 0.196425, 0.238294, 0.299585, 0.30119, 0.220816, 0.163527, 0.190055, 0.257444, 0.261489, 0.287058},
+	"MACD.hist":   {0, 0.128571, 0.027551, 0.141822, 0.22923, 0.0621, 0.125608, 0.183872, 0.004815, -0.241123, -0.171865, 0.079582, 0.202169, 0.012132, 0.076709},
+	"RSI(6)":      {0, 100, 89.285714, 91.477273, 92.924528, 82.800941, 86.365817, 88.706615, 78.947596, 62.456815, 66.638053, 75.403843, 79.452882, 68.610031, 74.80021},
+	"KDJ.k":       {52.380952, 59.920635, 56.613757, 59.964727, 68.309818, 67.762101, 73.746162, 74.921684, 71.159911, 59.561152, 51.472141, 61.765741, 69.965039, 69.370632, 75.034967},
+	"KDJ.d":       {50.793651, 53.835979, 54.761905, 56.496179, 60.434058, 62.876739, 66.49988, 69.307148, 69.924736, 66.470208, 61.470852, 61.569148, 64.367779, 66.035397, 69.035253},
+	"KDJ.j":       {55.555556, 72.089947, 60.31746, 66.901822, 84.061336, 77.532824, 88.238727, 86.150756, 73.63026, 45.743042, 31.474718, 62.158926, 81.159561, 76.041104, 87.034394},
+	"BOLL.upper":  {0, 0, 0, 0, 11.477388, 11.525245, 11.929947, 12.277388, 12.325245, 12.33599, 12.241332, 12.573977, 13.061227, 13.23063, 13.435374},
+	"BOLL.mid":    {0, 0, 0, 0, 10.56, 10.76, 10.98, 11.36, 11.56, 11.54, 11.64, 11.78, 11.92, 12.04, 12.4},
+	"BOLL.lower":  {0, 0, 0, 0, 9.642612, 9.994755, 10.030053, 10.442612, 10.794755, 10.74401, 11.038668, 10.986023, 10.778773, 10.84937, 11.364626},
+	"ATR(3)":      {0, 0, 0.733333, 0.9, 0.9, 0.966667, 0.866667, 0.933333, 0.866667, 0.866667, 0.766667, 0.9, 0.866667, 0.9, 0.833333},
+	"CCI(5)":      {0, 0, 0, 0, 115.384615, 33.333333, 110.311751, 130.242826, 45.112782, -56.354916, -41.237113, 96.153846, 116.755793, 43.300654, 100},
+	"DMI.pdi":     {0, 0, 0, 0, 45.714286, 30.555556, 44.736842, 41.666667, 37.142857, 37.142857, 30.30303, 32.352941, 45.714286, 47.058824, 52.777778},
+	"DMI.mdi":     {0, 0, 0, 0, 8.571429, 22.222222, 13.157895, 13.888889, 22.857143, 22.857143, 24.242424, 23.529412, 14.285714, 11.764706, 11.111111},
+	"DMI.adx":     {0, 0, 0, 0, 0, 0, 46.251994, 40.111643, 42.784993, 32.539683, 19.57672, 16.90337, 26.427179, 42.723475, 59.199448},
+	"DMI.adxr":    {0, 0, 0, 0, 0, 0, 0, 0, 0, 39.395838, 29.844181, 29.844181, 29.483431, 31.150097, 38.051409},
+	"VWAP":        {9.966667, 10.239394, 10.21828, 10.42971, 10.665104, 10.699556, 10.852015, 11.082883, 11.15457, 11.160047, 11.187742, 11.316477, 11.496849, 11.57197, 11.69876},
+	"OBV":         {0, 1200, 300, 1800, 3600, 2500, 4100, 6100, 4800, 3100, 4500, 6600, 9100, 7200, 9400},
+	"REF(2)":      {0, 0, 10, 10.5, 10.2, 10.8, 11.3, 11, 11.6, 12.1, 11.8, 11.2, 11.5, 12.3, 12.8},
+	"HHV(3)":      {10, 10.5, 10.5, 10.8, 11.3, 11.3, 11.6, 12.1, 12.1, 12.1, 11.8, 12.3, 12.8, 12.8, 13},
+	"LLV(3)":      {10, 10, 10, 10.2, 10.2, 10.8, 11, 11, 11.6, 11.2, 11.2, 11.2, 11.5, 12.3, 12.4},
+}
+
+func TestIndicator(t *testing.T) {
+	bars := testBars()
+	dif, dea, hist := MACD(testClose, 3, 6, 4)
+	k, d, j := KDJ(bars, 5, 3, 3)
+	upper, mid, lower := BOLL(testClose, 5, 2)
+	pdi, mdi, adx, adxr := DMI(bars, 4, 3)
+
+	tests := []struct {
+		name      string
+		got, want []float64
+	}{
+		{"SMA", SMA([]float64{1, 2, 3, 4, 5}, 3), []float64{0, 0, 2, 3, 4}},
+		{"SMA(5)", SMA(testClose, 5), reference["SMA(5)"]},
+		{"EMA", EMA([]float64{1, 2, 3, 4}, 3), []float64{1, 1.5, 2.25, 3.125}},
+		{"EMA(5)", EMA(testClose, 5), reference["EMA(5)"]},
+		{"WMA", WMA([]float64{1, 2, 3, 4}, 3), []float64{0, 0, 14.0 / 6, 20.0 / 6}},
+		{"WMA(5)", WMA(testClose, 5), reference["WMA(5)"]},
+		{"TdxSMA", TdxSMA([]float64{3, 6, 9}, 3, 1), []float64{3, 4, 17.0 / 3}},
+		{"TdxSMA(5,3)", TdxSMA(testClose, 5, 3), reference["TdxSMA(5,3)"]},
+		{"MACD.dif", dif, reference["MACD.dif"]},
+		{"MACD.dea", dea, reference["MACD.dea"]},
+		{"MACD.hist", hist, reference["MACD.hist"]},
+		{"RSI", RSI([]float64{10, 11, 10, 12}, 2), []float64{0, 100, 50, 250.0 / 3}},
+		{"RSI(6)", RSI(testClose, 6), reference["RSI(6)"]},
+		{"KDJ.k", k, reference["KDJ.k"]},
+		{"KDJ.d", d, reference["KDJ.d"]},
+		{"KDJ.j", j, reference["KDJ.j"]},
+		{"BOLL.upper", upper, reference["BOLL.upper"]},
+		{"BOLL.mid", mid, reference["BOLL.mid"]},
+		{"BOLL.lower", lower, reference["BOLL.lower"]},
+		{"ATR(3)", ATR(bars, 3), reference["ATR(3)"]},
+		{"CCI(5)", CCI(bars, 5), reference["CCI(5)"]},
+		{"DMI.pdi", pdi, reference["DMI.pdi"]},
+		{"DMI.mdi", mdi, reference["DMI.mdi"]},
+		{"DMI.adx", adx, reference["DMI.adx"]},
+		{"DMI.adxr", adxr, reference["DMI.adxr"]},
+		{"VWAP", VWAP(bars), reference["VWAP"]},
+		{"OBV", OBV(bars), reference["OBV"]},
+		{"REF(2)", REF(testClose, 2), reference["REF(2)"]},
+		{"HHV(3)", HHV(testClose, 3), reference["HHV(3)"]},
+		{"HHV(0)", HHV([]float64{2, 1, 3, 2}, 0), []float64{2, 2, 3, 3}},
+		{"LLV(3)", LLV(testClose, 3), reference["LLV(3)"]},
+		{"LLV(0)", LLV([]float64{2, 1, 3, 0}, 0), []float64{2, 1, 1, 0}},
+	}
+	for _, v := range tests {
+		assertNear(t, v.name, v.got, v.want)
+	}
+}
+
+func TestIndicatorEmpty(t *testing.T) {
+	for name, out := range map[string][]float64{
+		"SMA":    SMA(nil, 5),
+		"EMA":    EMA(nil, 5),
+		"WMA":    WMA(nil, 5),
+		"TdxSMA": TdxSMA(nil, 5, 3),
+		"RSI":    RSI(nil, 6),
+		"REF":    REF(nil, 2),
+		"HHV":    HHV(nil, 3),
+		"LLV":    LLV(nil, 3),
+		"ATR":    ATR(nil, 3),
+		"CCI":    CCI(nil, 5),
+		"OBV":    OBV(nil),
+		"VWAP":   VWAP(nil),
+	} {
+		if len(out) != 0 {
+			t.Errorf("%s: 空输入返回 %v", name, out)
+		}
+	}
+}
+
+// 条件函数的输入和参考值
+var (
+	condA     = []float64{1, 3, 2, 4, 4, 5}
+	condB     = []float64{2, 2, 3, 3, 4, 4}
+	cond      = []bool{false, true, true, false, true, false, false}
+	wantCross = []bool{false, true, false, true, false, true}
+	wantCount = map[string][]int{
+		"COUNT(3)": {0, 1, 2, 2, 2, 1, 1},
+		"COUNT(0)": {0, 1, 2, 2, 3, 3, 3},
+		"BARSLAST": {-1, 0, 0, 1, 0, 1, 2},
+	}
+)
+
+func TestCondition(t *testing.T) {
+	cross := CROSS(condA, condB)
+	for i := range wantCross {
+		if cross[i] != wantCross[i] {
+			t.Fatalf("CROSS[%d]: %v, 期望 %v", i, cross[i], wantCross[i])
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  []int
+	}{
+		{"COUNT(3)", COUNT(cond, 3)},
+		{"COUNT(0)", COUNT(cond, 0)},
+		{"BARSLAST", BARSLAST(cond)},
+	}
+	for _, v := range tests {
+		want := wantCount[v.name]
+		for i := range want {
+			if v.got[i] != want[i] {
+				t.Fatalf("%s[%d]: %d, 期望 %d", v.name, i, v.got[i], want[i])
+			}
+		}
+	}
+}
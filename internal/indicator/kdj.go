@@ -0,0 +1,46 @@
+package indicator
+
+// KDJ 随机指标,常用参数9,3,3
+// RSV=(C-LLV(L,N))/(HHV(H,N)-LLV(L,N))*100, K=SMA(RSV,M1,1), D=SMA(K,M2,1), J=3K-2D
+func KDJ(bars []Bar, n, m1, m2 int) (k, d, j []float64) {
+	s := NewKDJStream(n, m1, m2)
+	k = make([]float64, len(bars))
+	d = make([]float64, len(bars))
+	j = make([]float64, len(bars))
+	for i, b := range bars {
+		k[i], d[i], j[i] = s.Update(b)
+	}
+	return
+}
+
+func NewKDJStream(n, m1, m2 int) *KDJStream {
+	return &KDJStream{
+		hhv: NewHHVStream(n),
+		llv: NewLLVStream(n),
+		m1:  float64(m1),
+		m2:  float64(m2),
+		K:   50,
+		D:   50,
+	}
+}
+
+// KDJStream 增量KDJ,K和D的初始值为50
+type KDJStream struct {
+	hhv     *HHVStream
+	llv     *LLVStream
+	m1, m2  float64
+	K, D, J float64
+}
+
+func (this *KDJStream) Update(b Bar) (k, d, j float64) {
+	h := this.hhv.Update(b.High)
+	l := this.llv.Update(b.Low)
+	rsv := 50.0
+	if h > l {
+		rsv = (b.Close - l) / (h - l) * 100
+	}
+	this.K = (rsv + (this.m1-1)*this.K) / this.m1
+	this.D = (this.K + (this.m2-1)*this.D) / this.m2
+	this.J = 3*this.K - 2*this.D
+	return this.K, this.D, this.J
+}
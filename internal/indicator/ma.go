@@ -0,0 +1,137 @@
+package indicator
+
+// SMA 简单移动平均
+func SMA(xs []float64, n int) []float64 {
+	return run(NewSMAStream(n), xs)
+}
+
+// EMA 指数移动平均,权重2/(n+1),首个值为输入值
+func EMA(xs []float64, n int) []float64 {
+	return run(NewEMAStream(n), xs)
+}
+
+// WMA 加权移动平均,最新值权重为n,最早值权重为1
+func WMA(xs []float64, n int) []float64 {
+	return run(NewWMAStream(n), xs)
+}
+
+// TdxSMA 通达信的SMA(X,N,M),Y=(M*X+(N-M)*Y')/N
+func TdxSMA(xs []float64, n, m int) []float64 {
+	return run(NewTdxSMAStream(n, m), xs)
+}
+
+var (
+	_ Stream = (*SMAStream)(nil)
+	_ Stream = (*EMAStream)(nil)
+	_ Stream = (*WMAStream)(nil)
+	_ Stream = (*TdxSMAStream)(nil)
+)
+
+func NewSMAStream(n int) *SMAStream {
+	return &SMAStream{w: newWindow(n)}
+}
+
+// SMAStream 增量简单移动平均
+type SMAStream struct {
+	w     *window
+	sum   float64
+	value float64
+}
+
+func (this *SMAStream) Update(v float64) float64 {
+	out, full := this.w.push(v)
+	this.sum += v
+	if full {
+		this.sum -= out
+	}
+	if this.w.full() {
+		this.value = this.sum / float64(this.w.len())
+	}
+	return this.value
+}
+
+func (this *SMAStream) Value() float64 { return this.value }
+
+func (this *SMAStream) Ready() bool { return this.w.full() }
+
+func NewEMAStream(n int) *EMAStream {
+	return &EMAStream{alpha: 2 / float64(n+1)}
+}
+
+// EMAStream 增量指数移动平均
+type EMAStream struct {
+	alpha float64
+	value float64
+	ready bool
+}
+
+func (this *EMAStream) Update(v float64) float64 {
+	if !this.ready {
+		this.value = v
+		this.ready = true
+		return this.value
+	}
+	this.value = this.alpha*v + (1-this.alpha)*this.value
+	return this.value
+}
+
+func (this *EMAStream) Value() float64 { return this.value }
+
+func (this *EMAStream) Ready() bool { return this.ready }
+
+func NewWMAStream(n int) *WMAStream {
+	return &WMAStream{w: newWindow(n)}
+}
+
+// WMAStream 增量加权移动平均
+type WMAStream struct {
+	w     *window
+	value float64
+}
+
+func (this *WMAStream) Update(v float64) float64 {
+	this.w.push(v)
+	if !this.w.full() {
+		return this.value
+	}
+	n := this.w.len()
+	var sum, den float64
+	for i := 0; i < n; i++ {
+		sum += this.w.at(i) * float64(i+1)
+		den += float64(i + 1)
+	}
+	this.value = sum / den
+	return this.value
+}
+
+func (this *WMAStream) Value() float64 { return this.value }
+
+func (this *WMAStream) Ready() bool { return this.w.full() }
+
+func NewTdxSMAStream(n, m int) *TdxSMAStream {
+	if n < 1 {
+		n = 1
+	}
+	return &TdxSMAStream{n: float64(n), m: float64(m)}
+}
+
+// TdxSMAStream 增量通达信SMA(X,N,M)
+type TdxSMAStream struct {
+	n, m  float64
+	value float64
+	ready bool
+}
+
+func (this *TdxSMAStream) Update(v float64) float64 {
+	if !this.ready {
+		this.value = v
+		this.ready = true
+		return this.value
+	}
+	this.value = (this.m*v + (this.n-this.m)*this.value) / this.n
+	return this.value
+}
+
+func (this *TdxSMAStream) Value() float64 { return this.value }
+
+func (this *TdxSMAStream) Ready() bool { return this.ready }
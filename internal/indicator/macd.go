@@ -0,0 +1,35 @@
+package indicator
+
+// MACD 指数平滑异同移动平均,常用参数12,26,9
+// dif=EMA(C,fast)-EMA(C,slow), dea=EMA(dif,signal), hist=(dif-dea)*2
+func MACD(xs []float64, fast, slow, signal int) (dif, dea, hist []float64) {
+	s := NewMACDStream(fast, slow, signal)
+	dif = make([]float64, len(xs))
+	dea = make([]float64, len(xs))
+	hist = make([]float64, len(xs))
+	for i, v := range xs {
+		dif[i], dea[i], hist[i] = s.Update(v)
+	}
+	return
+}
+
+func NewMACDStream(fast, slow, signal int) *MACDStream {
+	return &MACDStream{
+		fast:   NewEMAStream(fast),
+		slow:   NewEMAStream(slow),
+		signal: NewEMAStream(signal),
+	}
+}
+
+// MACDStream 增量MACD
+type MACDStream struct {
+	fast, slow, signal *EMAStream
+	Dif, Dea, Hist     float64
+}
+
+func (this *MACDStream) Update(v float64) (dif, dea, hist float64) {
+	this.Dif = this.fast.Update(v) - this.slow.Update(v)
+	this.Dea = this.signal.Update(this.Dif)
+	this.Hist = (this.Dif - this.Dea) * 2
+	return this.Dif, this.Dea, this.Hist
+}
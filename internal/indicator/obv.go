@@ -0,0 +1,34 @@
+package indicator
+
+// OBV 能量潮,收盘价上涨累加成交量,下跌累减成交量
+func OBV(bars []Bar) []float64 {
+	s := new(OBVStream)
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		out[i] = s.Update(b)
+	}
+	return out
+}
+
+// OBVStream 增量OBV
+type OBVStream struct {
+	last  float64
+	count int
+	value float64
+}
+
+func (this *OBVStream) Update(b Bar) float64 {
+	if this.count > 0 {
+		switch {
+		case b.Close > this.last:
+			this.value += b.Volume
+		case b.Close < this.last:
+			this.value -= b.Volume
+		}
+	}
+	this.count++
+	this.last = b.Close
+	return this.value
+}
+
+func (this *OBVStream) Value() float64 { return this.value }
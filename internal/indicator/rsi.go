@@ -0,0 +1,47 @@
+package indicator
+
+import (
+	"math"
+)
+
+// RSI 相对强弱指标,通达信算法
+// RSI=SMA(MAX(C-REF(C,1),0),N,1)/SMA(ABS(C-REF(C,1)),N,1)*100
+func RSI(xs []float64, n int) []float64 {
+	return run(NewRSIStream(n), xs)
+}
+
+var _ Stream = (*RSIStream)(nil)
+
+func NewRSIStream(n int) *RSIStream {
+	return &RSIStream{up: NewTdxSMAStream(n, 1), all: NewTdxSMAStream(n, 1)}
+}
+
+// RSIStream 增量RSI
+type RSIStream struct {
+	up, all *TdxSMAStream
+	last    float64
+	count   int
+	value   float64
+}
+
+func (this *RSIStream) Update(v float64) float64 {
+	this.count++
+	if this.count == 1 {
+		this.last = v
+		return this.value
+	}
+	diff := v - this.last
+	this.last = v
+	up := this.up.Update(math.Max(diff, 0))
+	all := this.all.Update(math.Abs(diff))
+	if all == 0 {
+		this.value = 0
+	} else {
+		this.value = up / all * 100
+	}
+	return this.value
+}
+
+func (this *RSIStream) Value() float64 { return this.value }
+
+func (this *RSIStream) Ready() bool { return this.count > 1 }
@@ -0,0 +1,124 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+)
+
+// 逐根输入的增量结果和indicator_test.go中按公式逐项计算的参考值对比,
+// 全序列函数也是用增量计算实现的,互相对比不能发现错误
+
+// assertStep 第i个增量结果和参考值一致
+func assertStep(t *testing.T, name string, i int, got float64, want []float64) {
+	t.Helper()
+	if math.Abs(got-want[i]) > 1e-5 {
+		t.Fatalf("%s[%d]: 增量 %v, 期望 %v", name, i, got, want[i])
+	}
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		ready  int //第几个值之后Ready
+		stream Stream
+		want   []float64
+	}{
+		{"SMA(5)", 5, NewSMAStream(5), reference["SMA(5)"]},
+		{"EMA(5)", 1, NewEMAStream(5), reference["EMA(5)"]},
+		{"WMA(5)", 5, NewWMAStream(5), reference["WMA(5)"]},
+		{"TdxSMA(5,3)", 1, NewTdxSMAStream(5, 3), reference["TdxSMA(5,3)"]},
+		{"RSI(6)", 2, NewRSIStream(6), reference["RSI(6)"]},
+		{"REF(2)", 3, NewREFStream(2), reference["REF(2)"]},
+		{"HHV(3)", 3, NewHHVStream(3), reference["HHV(3)"]},
+		{"LLV(3)", 3, NewLLVStream(3), reference["LLV(3)"]},
+		{"SMA(20)", 20, NewSMAStream(20), make([]float64, len(testClose))},
+	}
+	for _, v := range tests {
+		if v.stream.Ready() {
+			t.Fatalf("%s: 没有数据时Ready", v.name)
+		}
+		for i, x := range testClose {
+			got := v.stream.Update(x)
+			assertStep(t, v.name, i, got, v.want)
+			if v.stream.Value() != got {
+				t.Fatalf("%s[%d]: Value %v, Update %v", v.name, i, v.stream.Value(), got)
+			}
+			if v.stream.Ready() != (i+1 >= v.ready) {
+				t.Fatalf("%s[%d]: Ready %v", v.name, i, v.stream.Ready())
+			}
+		}
+	}
+}
+
+func TestBarStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(b Bar) float64
+	}{
+		{"ATR(3)", NewATRStream(3).Update},
+		{"CCI(5)", NewCCIStream(5).Update},
+		{"OBV", new(OBVStream).Update},
+		{"VWAP", new(VWAPStream).Update},
+	}
+	for _, v := range tests {
+		for i, b := range testBars() {
+			assertStep(t, v.name, i, v.update(b), reference[v.name])
+		}
+	}
+}
+
+func TestMultiStream(t *testing.T) {
+	macd := NewMACDStream(3, 6, 4)
+	boll := NewBOLLStream(5, 2)
+	kdj := NewKDJStream(5, 3, 3)
+	dmi := NewDMIStream(4, 3)
+	for i, b := range testBars() {
+		dif, dea, hist := macd.Update(b.Close)
+		assertStep(t, "MACD.dif", i, dif, reference["MACD.dif"])
+		assertStep(t, "MACD.dea", i, dea, reference["MACD.dea"])
+		assertStep(t, "MACD.hist", i, hist, reference["MACD.hist"])
+
+		upper, mid, lower := boll.Update(b.Close)
+		assertStep(t, "BOLL.upper", i, upper, reference["BOLL.upper"])
+		assertStep(t, "BOLL.mid", i, mid, reference["BOLL.mid"])
+		assertStep(t, "BOLL.lower", i, lower, reference["BOLL.lower"])
+		if boll.Ready() != (i >= 4) {
+			t.Fatalf("BOLL[%d]: Ready %v", i, boll.Ready())
+		}
+
+		k, d, j := kdj.Update(b)
+		assertStep(t, "KDJ.k", i, k, reference["KDJ.k"])
+		assertStep(t, "KDJ.d", i, d, reference["KDJ.d"])
+		assertStep(t, "KDJ.j", i, j, reference["KDJ.j"])
+
+		pdi, mdi, adx, adxr := dmi.Update(b)
+		assertStep(t, "DMI.pdi", i, pdi, reference["DMI.pdi"])
+		assertStep(t, "DMI.mdi", i, mdi, reference["DMI.mdi"])
+		assertStep(t, "DMI.adx", i, adx, reference["DMI.adx"])
+		assertStep(t, "DMI.adxr", i, adxr, reference["DMI.adxr"])
+	}
+}
+
+func TestConditionStream(t *testing.T) {
+	cross := new(CrossStream)
+	for i := range condA {
+		if got := cross.Update(condA[i], condB[i]); got != wantCross[i] {
+			t.Fatalf("CROSS[%d]: 增量 %v, 期望 %v", i, got, wantCross[i])
+		}
+	}
+
+	count := NewCountStream(3)
+	total := NewCountStream(0)
+	last := new(BarsLastStream)
+	for i, c := range cond {
+		if got := count.Update(c); got != wantCount["COUNT(3)"][i] || count.Value() != got {
+			t.Fatalf("COUNT(3)[%d]: 增量 %d", i, got)
+		}
+		if got := total.Update(c); got != wantCount["COUNT(0)"][i] {
+			t.Fatalf("COUNT(0)[%d]: 增量 %d", i, got)
+		}
+		if got := last.Update(c); got != wantCount["BARSLAST"][i] || last.Value() != got {
+			t.Fatalf("BARSLAST[%d]: 增量 %d", i, got)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package indicator
+
+// 通达信公式常用函数
+
+// REF 向前引用N周期的值,数据不足时为0
+func REF(xs []float64, n int) []float64 {
+	return run(NewREFStream(n), xs)
+}
+
+// HHV N周期内最高值,N<=0表示从第一个周期开始
+func HHV(xs []float64, n int) []float64 {
+	return run(NewHHVStream(n), xs)
+}
+
+// LLV N周期内最低值,N<=0表示从第一个周期开始
+func LLV(xs []float64, n int) []float64 {
+	return run(NewLLVStream(n), xs)
+}
+
+// CROSS a上穿b,当前周期a>b且上一周期a<=b
+func CROSS(a, b []float64) []bool {
+	s := new(CrossStream)
+	out := make([]bool, min(len(a), len(b)))
+	for i := range out {
+		out[i] = s.Update(a[i], b[i])
+	}
+	return out
+}
+
+// COUNT N周期内满足条件的次数,N<=0表示从第一个周期开始
+func COUNT(cond []bool, n int) []int {
+	s := NewCountStream(n)
+	out := make([]int, len(cond))
+	for i, v := range cond {
+		out[i] = s.Update(v)
+	}
+	return out
+}
+
+// BARSLAST 上一次条件成立到当前的周期数,当前成立为0,从未成立为-1
+func BARSLAST(cond []bool) []int {
+	s := new(BarsLastStream)
+	out := make([]int, len(cond))
+	for i, v := range cond {
+		out[i] = s.Update(v)
+	}
+	return out
+}
+
+var (
+	_ Stream = (*REFStream)(nil)
+	_ Stream = (*HHVStream)(nil)
+	_ Stream = (*LLVStream)(nil)
+)
+
+func NewREFStream(n int) *REFStream {
+	if n < 0 {
+		n = 0
+	}
+	return &REFStream{w: newWindow(n + 1)}
+}
+
+// REFStream 增量REF
+type REFStream struct {
+	w     *window
+	value float64
+}
+
+func (this *REFStream) Update(v float64) float64 {
+	this.w.push(v)
+	if this.w.full() {
+		this.value = this.w.at(0)
+	}
+	return this.value
+}
+
+func (this *REFStream) Value() float64 { return this.value }
+
+func (this *REFStream) Ready() bool { return this.w.full() }
+
+func NewHHVStream(n int) *HHVStream {
+	return &HHVStream{extreme: extreme{n: n, better: func(a, b float64) bool { return a >= b }}}
+}
+
+// HHVStream 增量HHV
+type HHVStream struct{ extreme }
+
+func NewLLVStream(n int) *LLVStream {
+	return &LLVStream{extreme: extreme{n: n, better: func(a, b float64) bool { return a <= b }}}
+}
+
+// LLVStream 增量LLV
+type LLVStream struct{ extreme }
+
+// extreme 单调队列求滑动窗口极值,均摊O(1)
+type extreme struct {
+	n      int
+	better func(a, b float64) bool
+	index  []int
+	values []float64
+	count  int
+	value  float64
+}
+
+func (this *extreme) Update(v float64) float64 {
+	i := this.count
+	this.count++
+	if this.n <= 0 {
+		if i == 0 || this.better(v, this.value) {
+			this.value = v
+		}
+		return this.value
+	}
+	for len(this.values) > 0 && this.better(v, this.values[len(this.values)-1]) {
+		this.values = this.values[:len(this.values)-1]
+		this.index = this.index[:len(this.index)-1]
+	}
+	this.values = append(this.values, v)
+	this.index = append(this.index, i)
+	if this.index[0] <= i-this.n {
+		this.values = this.values[1:]
+		this.index = this.index[1:]
+	}
+	this.value = this.values[0]
+	return this.value
+}
+
+func (this *extreme) Value() float64 { return this.value }
+
+func (this *extreme) Ready() bool { return this.count >= this.n && this.count > 0 }
+
+// CrossStream 增量CROSS
+type CrossStream struct {
+	lastA, lastB float64
+	count        int
+}
+
+func (this *CrossStream) Update(a, b float64) bool {
+	cross := this.count > 0 && a > b && this.lastA <= this.lastB
+	this.lastA, this.lastB = a, b
+	this.count++
+	return cross
+}
+
+func NewCountStream(n int) *CountStream {
+	return &CountStream{n: n, w: newWindow(n)}
+}
+
+// CountStream 增量COUNT
+type CountStream struct {
+	n     int
+	w     *window
+	value int
+}
+
+func (this *CountStream) Update(cond bool) int {
+	v := 0.0
+	if cond {
+		v = 1
+		this.value++
+	}
+	if this.n > 0 {
+		if out, full := this.w.push(v); full && out > 0 {
+			this.value--
+		}
+	}
+	return this.value
+}
+
+func (this *CountStream) Value() int { return this.value }
+
+// BarsLastStream 增量BARSLAST
+type BarsLastStream struct {
+	value int
+	found bool
+}
+
+func (this *BarsLastStream) Update(cond bool) int {
+	switch {
+	case cond:
+		this.found = true
+		this.value = 0
+	case this.found:
+		this.value++
+	default:
+		this.value = -1
+	}
+	return this.value
+}
+
+func (this *BarsLastStream) Value() int { return this.value }
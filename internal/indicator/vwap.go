@@ -0,0 +1,29 @@
+package indicator
+
+// VWAP 成交量加权平均价,从序列开始累计,价格使用典型价格(H+L+C)/3
+// 不使用成交额,避免不同周期成交量单位(股/手)不一致的问题
+func VWAP(bars []Bar) []float64 {
+	s := new(VWAPStream)
+	out := make([]float64, len(bars))
+	for i, b := range bars {
+		out[i] = s.Update(b)
+	}
+	return out
+}
+
+// VWAPStream 增量VWAP
+type VWAPStream struct {
+	amount, volume float64
+	value          float64
+}
+
+func (this *VWAPStream) Update(b Bar) float64 {
+	this.amount += (b.High + b.Low + b.Close) / 3 * b.Volume
+	this.volume += b.Volume
+	if this.volume > 0 {
+		this.value = this.amount / this.volume
+	}
+	return this.value
+}
+
+func (this *VWAPStream) Value() float64 { return this.value }
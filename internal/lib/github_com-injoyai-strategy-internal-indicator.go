@@ -0,0 +1,102 @@
+// Code generated by 'yaegi extract github.com/injoyai/strategy/internal/indicator'. DO NOT EDIT.
+
+package lib
+
+import (
+	"github.com/injoyai/strategy/internal/indicator"
+	"reflect"
+)
+
+func init() {
+	Symbols["github.com/injoyai/strategy/internal/indicator/indicator"] = map[string]reflect.Value{
+		// function, constant and variable definitions
+		"ATR":             reflect.ValueOf(indicator.ATR),
+		"BARSLAST":        reflect.ValueOf(indicator.BARSLAST),
+		"BOLL":            reflect.ValueOf(indicator.BOLL),
+		"Bars":            reflect.ValueOf(indicator.Bars),
+		"CCI":             reflect.ValueOf(indicator.CCI),
+		"COUNT":           reflect.ValueOf(indicator.COUNT),
+		"CROSS":           reflect.ValueOf(indicator.CROSS),
+		"Closes":          reflect.ValueOf(indicator.Closes),
+		"DMI":             reflect.ValueOf(indicator.DMI),
+		"EMA":             reflect.ValueOf(indicator.EMA),
+		"HHV":             reflect.ValueOf(indicator.HHV),
+		"Highs":           reflect.ValueOf(indicator.Highs),
+		"KDJ":             reflect.ValueOf(indicator.KDJ),
+		"LLV":             reflect.ValueOf(indicator.LLV),
+		"Lows":            reflect.ValueOf(indicator.Lows),
+		"MACD":            reflect.ValueOf(indicator.MACD),
+		"Mean":            reflect.ValueOf(indicator.Mean),
+		"Median":          reflect.ValueOf(indicator.Median),
+		"NewATRStream":    reflect.ValueOf(indicator.NewATRStream),
+		"NewBOLLStream":   reflect.ValueOf(indicator.NewBOLLStream),
+		"NewBar":          reflect.ValueOf(indicator.NewBar),
+		"NewCCIStream":    reflect.ValueOf(indicator.NewCCIStream),
+		"NewCountStream":  reflect.ValueOf(indicator.NewCountStream),
+		"NewDMIStream":    reflect.ValueOf(indicator.NewDMIStream),
+		"NewEMAStream":    reflect.ValueOf(indicator.NewEMAStream),
+		"NewHHVStream":    reflect.ValueOf(indicator.NewHHVStream),
+		"NewKDJStream":    reflect.ValueOf(indicator.NewKDJStream),
+		"NewLLVStream":    reflect.ValueOf(indicator.NewLLVStream),
+		"NewMACDStream":   reflect.ValueOf(indicator.NewMACDStream),
+		"NewREFStream":    reflect.ValueOf(indicator.NewREFStream),
+		"NewRSIStream":    reflect.ValueOf(indicator.NewRSIStream),
+		"NewSMAStream":    reflect.ValueOf(indicator.NewSMAStream),
+		"NewTdxSMAStream": reflect.ValueOf(indicator.NewTdxSMAStream),
+		"NewWMAStream":    reflect.ValueOf(indicator.NewWMAStream),
+		"OBV":             reflect.ValueOf(indicator.OBV),
+		"Opens":           reflect.ValueOf(indicator.Opens),
+		"REF":             reflect.ValueOf(indicator.REF),
+		"RSI":             reflect.ValueOf(indicator.RSI),
+		"SMA":             reflect.ValueOf(indicator.SMA),
+		"TR":              reflect.ValueOf(indicator.TR),
+		"TdxSMA":          reflect.ValueOf(indicator.TdxSMA),
+		"VWAP":            reflect.ValueOf(indicator.VWAP),
+		"Volumes":         reflect.ValueOf(indicator.Volumes),
+		"WMA":             reflect.ValueOf(indicator.WMA),
+
+		// type definitions
+		"ATRStream":      reflect.ValueOf((*indicator.ATRStream)(nil)),
+		"BOLLStream":     reflect.ValueOf((*indicator.BOLLStream)(nil)),
+		"Bar":            reflect.ValueOf((*indicator.Bar)(nil)),
+		"BarsLastStream": reflect.ValueOf((*indicator.BarsLastStream)(nil)),
+		"CCIStream":      reflect.ValueOf((*indicator.CCIStream)(nil)),
+		"CountStream":    reflect.ValueOf((*indicator.CountStream)(nil)),
+		"CrossStream":    reflect.ValueOf((*indicator.CrossStream)(nil)),
+		"DMIStream":      reflect.ValueOf((*indicator.DMIStream)(nil)),
+		"EMAStream":      reflect.ValueOf((*indicator.EMAStream)(nil)),
+		"HHVStream":      reflect.ValueOf((*indicator.HHVStream)(nil)),
+		"KDJStream":      reflect.ValueOf((*indicator.KDJStream)(nil)),
+		"LLVStream":      reflect.ValueOf((*indicator.LLVStream)(nil)),
+		"MACDStream":     reflect.ValueOf((*indicator.MACDStream)(nil)),
+		"OBVStream":      reflect.ValueOf((*indicator.OBVStream)(nil)),
+		"REFStream":      reflect.ValueOf((*indicator.REFStream)(nil)),
+		"RSIStream":      reflect.ValueOf((*indicator.RSIStream)(nil)),
+		"SMAStream":      reflect.ValueOf((*indicator.SMAStream)(nil)),
+		"Stream":         reflect.ValueOf((*indicator.Stream)(nil)),
+		"TdxSMAStream":   reflect.ValueOf((*indicator.TdxSMAStream)(nil)),
+		"VWAPStream":     reflect.ValueOf((*indicator.VWAPStream)(nil)),
+		"WMAStream":      reflect.ValueOf((*indicator.WMAStream)(nil)),
+
+		// interface wrapper definitions
+		"_Stream": reflect.ValueOf((*_github_com_injoyai_strategy_internal_indicator_Stream)(nil)),
+	}
+}
+
+// _github_com_injoyai_strategy_internal_indicator_Stream is an interface wrapper for Stream type
+type _github_com_injoyai_strategy_internal_indicator_Stream struct {
+	IValue  interface{}
+	WReady  func() bool
+	WUpdate func(v float64) float64
+	WValue  func() float64
+}
+
+func (W _github_com_injoyai_strategy_internal_indicator_Stream) Ready() bool {
+	return W.WReady()
+}
+func (W _github_com_injoyai_strategy_internal_indicator_Stream) Update(v float64) float64 {
+	return W.WUpdate(v)
+}
+func (W _github_com_injoyai_strategy_internal_indicator_Stream) Value() float64 {
+	return W.WValue()
+}
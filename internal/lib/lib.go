@@ -78,4 +78,6 @@ var Symbols = interp.Exports{}
 
 //go:generate yaegi extract github.com/injoyai/bar
 
+//go:generate yaegi extract github.com/injoyai/strategy/internal/indicator
+
 */
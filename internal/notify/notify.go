@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/injoyai/conv/cfg"
+)
+
+const (
+	TypeMQTT    = "mqtt"    //发布到MQTT主题
+	TypeWebhook = "webhook" //POST到HTTP地址
+	TypeFile    = "file"    //写入本地目录
+)
+
+// DefaultTimeout 连接和发送的超时时间
+const DefaultTimeout = 10 * time.Second
+
+// baseDir 文件推送的根目录,推送目标的Dir是相对这个目录的路径
+var baseDir = cfg.GetString("notify.dir", "./data/notify")
+
+// Sink 推送目标,按Type使用对应的字段
+type Sink struct {
+	Type string `json:"type"` //mqtt/webhook/file
+
+	//mqtt
+	Broker      string `json:"broker,omitempty"` //例tcp://127.0.0.1:1883
+	Topic       string `json:"topic,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"-"`                  //不通过接口返回,只保存到数据库
+	NewPassword string `json:"password,omitempty"` //只写,通过接口设置密码,保存时转存到Password,为空时沿用原来的密码
+	QoS         byte   `json:"qos,omitempty"`
+	Retained    bool   `json:"retained,omitempty"`
+
+	//webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	//file
+	Dir string `json:"dir,omitempty"` //相对notify.dir的目录,每次推送写入一个json文件
+}
+
+// Check 检查配置是否完整
+func (this Sink) Check() error {
+	switch this.Type {
+	case TypeMQTT:
+		if this.Broker == "" || this.Topic == "" {
+			return errors.New("MQTT推送需要配置broker和topic")
+		}
+		if this.QoS > 2 {
+			return errors.New("MQTT的qos只能是0,1,2")
+		}
+	case TypeWebhook:
+		if !strings.HasPrefix(this.URL, "http://") && !strings.HasPrefix(this.URL, "https://") {
+			return errors.New("webhook地址需要以http://或https://开头")
+		}
+	case TypeFile:
+		if _, err := this.path(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("未知的推送类型[%s],可选mqtt/webhook/file", this.Type)
+	}
+	return nil
+}
+
+// Target 推送目标的描述,用于推送日志
+func (this Sink) Target() string {
+	switch this.Type {
+	case TypeMQTT:
+		return this.Broker + "/" + this.Topic
+	case TypeWebhook:
+		return this.URL
+	case TypeFile:
+		return this.Dir
+	}
+	return ""
+}
+
+// Send 推送数据,name用于文件推送的文件名(不含扩展名)
+func (this Sink) Send(name string, payload []byte) error {
+	if err := this.Check(); err != nil {
+		return err
+	}
+	switch this.Type {
+	case TypeMQTT:
+		return this.mqtt(payload)
+	case TypeWebhook:
+		return this.webhook(payload)
+	default:
+		return this.file(name, payload)
+	}
+}
+
+// mqtt 每次推送单独连接,推送频率低,不需要保持连接
+func (this Sink) mqtt(payload []byte) error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(this.Broker).
+		SetClientID(fmt.Sprintf("strategy-%d", time.Now().UnixNano())).
+		SetUsername(this.Username).
+		SetPassword(this.Password).
+		SetConnectTimeout(DefaultTimeout).
+		SetAutoReconnect(false)
+	c := mqtt.NewClient(opts)
+	if err := wait(c.Connect()); err != nil {
+		return fmt.Errorf("连接MQTT失败: %w", err)
+	}
+	defer c.Disconnect(250)
+	return wait(c.Publish(this.Topic, this.QoS, this.Retained, payload))
+}
+
+func wait(t mqtt.Token) error {
+	if !t.WaitTimeout(DefaultTimeout) {
+		return errors.New("MQTT超时")
+	}
+	return t.Error()
+}
+
+func (this Sink) webhook(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, this.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range this.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Timeout: DefaultTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook返回状态码%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// path 文件推送的实际目录,不能超出根目录
+func (this Sink) path() (string, error) {
+	if this.Dir == "" {
+		return "", errors.New("文件推送需要配置目录")
+	}
+	if !filepath.IsLocal(this.Dir) {
+		return "", fmt.Errorf("文件推送的目录[%s]需要是notify.dir下的相对路径", this.Dir)
+	}
+	return filepath.Join(baseDir, this.Dir), nil
+}
+
+// file 先写临时文件再重命名,避免其他程序读到写了一半的文件
+func (this Sink) file(name string, payload []byte) error {
+	dir, err := this.path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	filename := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(filename+".tmp", payload, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// Sinks 推送目标列表,保存到数据库时包含密码,接口返回时不包含
+type Sinks []Sink
+
+// storedSink 数据库中保存的推送目标
+type storedSink struct {
+	Sink
+	Password string `json:"password,omitempty"`
+}
+
+// ToDB 实现xorm的转换接口
+func (this Sinks) ToDB() ([]byte, error) {
+	ls := make([]storedSink, len(this))
+	for i, s := range this {
+		s.NewPassword = ""
+		ls[i] = storedSink{Sink: s, Password: s.Password}
+	}
+	return json.Marshal(ls)
+}
+
+// FromDB 实现xorm的转换接口
+func (this *Sinks) FromDB(bs []byte) error {
+	if len(bs) == 0 {
+		*this = nil
+		return nil
+	}
+	var ls []storedSink
+	if err := json.Unmarshal(bs, &ls); err != nil {
+		return err
+	}
+	*this = make(Sinks, len(ls))
+	for i, s := range ls {
+		s.Sink.Password = s.Password
+		(*this)[i] = s.Sink
+	}
+	return nil
+}
+
+// KeepPassword 保存前处理密码,接口传入了密码时使用新密码,
+// 否则沿用old中相同位置,相同地址和用户名的推送目标的密码
+func (this Sinks) KeepPassword(old Sinks) {
+	for i := range this {
+		s := &this[i]
+		if s.NewPassword != "" {
+			s.Password, s.NewPassword = s.NewPassword, ""
+			continue
+		}
+		if i < len(old) && old[i].Type == s.Type && old[i].Broker == s.Broker && old[i].Username == s.Username {
+			s.Password = old[i].Password
+		}
+	}
+}
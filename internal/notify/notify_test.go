@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSinksPassword(t *testing.T) {
+	//接口传入的密码保存后不再返回
+	var ls Sinks
+	if err := json.Unmarshal([]byte(`[{"type":"mqtt","broker":"tcp://127.0.0.1:1883","topic":"a","username":"u","password":"p"}]`), &ls); err != nil {
+		t.Fatal(err)
+	}
+	ls.KeepPassword(nil)
+	if ls[0].Password != "p" || ls[0].NewPassword != "" {
+		t.Fatalf("密码 %q, 新密码 %q", ls[0].Password, ls[0].NewPassword)
+	}
+	bs, err := json.Marshal(ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "password") {
+		t.Errorf("接口返回了密码 %s", bs)
+	}
+
+	//数据库中保存密码
+	bs, err = ls.ToDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Sinks
+	if err := stored.FromDB(bs); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].Password != "p" || stored[0].Broker != ls[0].Broker {
+		t.Fatalf("数据库读取 %+v", stored)
+	}
+
+	for _, c := range []struct {
+		name string
+		sink Sink
+		want string
+	}{
+		{"没有传入密码沿用原来的", Sink{Type: TypeMQTT, Broker: "tcp://127.0.0.1:1883", Username: "u"}, "p"},
+		{"传入新密码", Sink{Type: TypeMQTT, Broker: "tcp://127.0.0.1:1883", Username: "u", NewPassword: "q"}, "q"},
+		{"修改了地址", Sink{Type: TypeMQTT, Broker: "tcp://127.0.0.2:1883", Username: "u"}, ""},
+		{"修改了用户名", Sink{Type: TypeMQTT, Broker: "tcp://127.0.0.1:1883", Username: "v"}, ""},
+	} {
+		ls := Sinks{c.sink}
+		ls.KeepPassword(stored)
+		if ls[0].Password != c.want || ls[0].NewPassword != "" {
+			t.Errorf("[%s] 密码 %q, 期望 %q", c.name, ls[0].Password, c.want)
+		}
+	}
+}
+
+func TestSinkFile(t *testing.T) {
+	old := baseDir
+	baseDir = t.TempDir()
+	defer func() { baseDir = old }()
+
+	for _, c := range []struct {
+		dir string
+		ok  bool
+	}{
+		{"", false},
+		{"screen", true},
+		{"a/b", true},
+		{".", true},
+		{"../screen", false},
+		{"a/../../screen", false},
+		{filepath.Join(baseDir, "screen"), false},
+	} {
+		if err := (Sink{Type: TypeFile, Dir: c.dir}).Check(); (err == nil) != c.ok {
+			t.Errorf("[%s] 检查结果 %v", c.dir, err)
+		}
+	}
+
+	if err := (Sink{Type: TypeFile, Dir: "a/b"}).Send("run-1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(filepath.Join(baseDir, "a", "b", "run-1.json")); err != nil || string(bs) != `{}` {
+		t.Errorf("推送的文件 %s %v", bs, err)
+	}
+}
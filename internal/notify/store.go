@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+)
+
+// Delivery 推送日志,每个推送目标一条
+type Delivery struct {
+	ID       int64  `xorm:"pk autoincr" json:"id"`
+	Source   string `json:"source"`    //来源,例screen:定时选股
+	SourceID int64  `json:"source_id"` //来源的ID,例定时选股的ID
+	RunID    int64  `json:"run_id"`    //来源的运行记录ID
+	Type     string `json:"type"`      //mqtt/webhook/file
+	Target   string `json:"target"`    //推送目标
+	Success  bool   `json:"success"`
+	Error    string `json:"error"`
+	Elapsed  int64  `json:"elapsed"` //耗时,毫秒
+	Created  int64  `xorm:"created" json:"created"`
+}
+
+var syncOnce struct {
+	sync.Once
+	err error
+}
+
+func table() error {
+	syncOnce.Do(func() {
+		syncOnce.err = common.DB.Sync2(new(Delivery))
+	})
+	return syncOnce.err
+}
+
+// Deliver 推送到全部目标并保存推送日志,单个目标失败不影响其他目标
+func Deliver(source string, sourceID, runID int64, sinks []Sink, name string, payload []byte) []*Delivery {
+	ls := make([]*Delivery, 0, len(sinks))
+	for _, sink := range sinks {
+		start := time.Now()
+		d := &Delivery{
+			Source:   source,
+			SourceID: sourceID,
+			RunID:    runID,
+			Type:     sink.Type,
+			Target:   sink.Target(),
+			Success:  true,
+		}
+		if err := sink.Send(name, payload); err != nil {
+			d.Success, d.Error = false, err.Error()
+			logs.Err("推送到["+d.Target+"]失败:", err)
+		}
+		d.Elapsed = time.Since(start).Milliseconds()
+		if err := table(); err == nil {
+			_, err = common.DB.Insert(d)
+			logs.PrintErr(err)
+		} else {
+			logs.Err(err)
+		}
+		ls = append(ls, d)
+	}
+	return ls
+}
+
+// ListDelivery 推送日志,按时间倒序,source,sourceID,runID为空时不过滤,limit为0时不限制
+func ListDelivery(source string, sourceID, runID int64, limit int) ([]*Delivery, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Delivery(nil)
+	session := common.DB.Desc("ID")
+	if source != "" {
+		session = session.Where("Source=?", source)
+	}
+	if sourceID > 0 {
+		session = session.Where("SourceID=?", sourceID)
+	}
+	if runID > 0 {
+		session = session.Where("RunID=?", runID)
+	}
+	if limit > 0 {
+		session = session.Limit(limit)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
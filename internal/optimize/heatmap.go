@@ -0,0 +1,107 @@
+package optimize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/strategy/internal/backtest"
+)
+
+// Heatmap 两个参数的敏感度热力图,其他参数取该格子里最好的结果
+type Heatmap struct {
+	X       string       `json:"x"`        //横轴参数
+	Y       string       `json:"y"`        //纵轴参数
+	XValues []any        `json:"x_values"` //横轴取值,从小到大
+	YValues []any        `json:"y_values"` //纵轴取值,从小到大
+	Best    [][]*float64 `json:"best"`     //[y][x]该格子里最好的优化目标,没有结果时为空
+	Mean    [][]*float64 `json:"mean"`     //[y][x]该格子里优化目标的平均值
+	Count   [][]int      `json:"count"`    //[y][x]该格子里的参数组合数量
+}
+
+// Heatmap 生成任意两个参数的热力图
+func (this *Optimization) Heatmap(x, y string) (*Heatmap, error) {
+	if x == "" || y == "" {
+		return nil, errors.New("需要指定两个参数")
+	}
+	if x == y {
+		return nil, errors.New("两个参数不能相同")
+	}
+	for _, name := range []string{x, y} {
+		found := false
+		for _, r := range this.Ranges {
+			found = found || r.Name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("参数[%s]没有参与优化", name)
+		}
+	}
+
+	h := &Heatmap{X: x, Y: y}
+	xs, xi := axis(this.Rows, x)
+	ys, yi := axis(this.Rows, y)
+	h.XValues, h.YValues = xs, ys
+	h.Best = make([][]*float64, len(ys))
+	h.Mean = make([][]*float64, len(ys))
+	h.Count = make([][]int, len(ys))
+	sum := make([][]float64, len(ys))
+	for i := range ys {
+		h.Best[i] = make([]*float64, len(xs))
+		h.Mean[i] = make([]*float64, len(xs))
+		h.Count[i] = make([]int, len(xs))
+		sum[i] = make([]float64, len(xs))
+	}
+	for _, r := range this.Rows {
+		if r.Error != "" {
+			continue
+		}
+		i, j := yi[conv.String(r.Params[y])], xi[conv.String(r.Params[x])]
+		if h.Best[i][j] == nil || r.Objective > *h.Best[i][j] {
+			v := r.Objective
+			h.Best[i][j] = &v
+		}
+		sum[i][j] += r.Objective
+		h.Count[i][j]++
+	}
+	for i := range ys {
+		for j := range xs {
+			if h.Count[i][j] > 0 {
+				//盈利因子可能是backtest.InfProfitFactor,相加后溢出
+				v := math.Min(sum[i][j]/float64(h.Count[i][j]), backtest.InfProfitFactor)
+				h.Mean[i][j] = &v
+			}
+		}
+	}
+	return h, nil
+}
+
+// axis 某个参数出现过的全部取值,数值按大小排序,其他按字符串排序
+func axis(rows []Row, name string) ([]any, map[string]int) {
+	seen := map[string]any{}
+	for _, r := range rows {
+		if v, ok := r.Params[name]; ok {
+			seen[conv.String(v)] = v
+		}
+	}
+	vs := make([]any, 0, len(seen))
+	for _, v := range seen {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		a, b := conv.String(vs[i]), conv.String(vs[j])
+		fa, ea := strconv.ParseFloat(a, 64)
+		fb, eb := strconv.ParseFloat(b, 64)
+		if ea == nil && eb == nil && fa != fb {
+			return fa < fb
+		}
+		return a < b
+	})
+	index := make(map[string]int, len(vs))
+	for i, v := range vs {
+		index[conv.String(v)] = i
+	}
+	return vs, index
+}
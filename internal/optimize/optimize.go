@@ -0,0 +1,420 @@
+package optimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/base/chans"
+	"github.com/injoyai/conv"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+const (
+	MethodGrid   = "grid"   //网格搜索,遍历全部参数组合
+	MethodRandom = "random" //随机搜索,在参数范围内随机取值
+
+	ObjectiveSharpe       = "sharpe"        //夏普比率
+	ObjectiveCAGR         = "cagr"          //年化收益率
+	ObjectiveCalmar       = "calmar"        //卡玛比率
+	ObjectiveProfitFactor = "profit_factor" //盈利因子
+	ObjectiveReturn       = "return"        //总收益率
+
+	DefaultSamples = 100   //随机搜索默认次数
+	DefaultMaxRuns = 10000 //网格搜索最多的参数组合数量
+)
+
+// Range 参数的取值范围
+type Range struct {
+	Name   string  `json:"name"`   //参数名称
+	Min    float64 `json:"min"`    //最小值
+	Max    float64 `json:"max"`    //最大值
+	Step   float64 `json:"step"`   //步长,整数参数默认1,小数参数默认(最大值-最小值)/10
+	Values []any   `json:"values"` //指定取值,优先于最小值和最大值,bool和string参数只能用指定取值
+}
+
+// values 网格搜索的全部取值
+func (this Range) values(p strategy.Param) ([]any, error) {
+	if len(this.Values) > 0 {
+		return this.Values, nil
+	}
+	if this.Max < this.Min {
+		return nil, fmt.Errorf("参数[%s]的最大值小于最小值", this.Name)
+	}
+	step := this.step(p)
+	out := []any(nil)
+	//加上一点余量,避免小数累加误差漏掉最大值
+	for v := this.Min; v <= this.Max+step*1e-9; v += step {
+		out = append(out, this.convert(p, v))
+	}
+	return out, nil
+}
+
+// random 随机取值,有步长时对齐到步长
+func (this Range) random(p strategy.Param, r *rand.Rand) any {
+	if len(this.Values) > 0 {
+		return this.Values[r.Intn(len(this.Values))]
+	}
+	v := this.Min + r.Float64()*(this.Max-this.Min)
+	if step := this.step(p); p.Type == strategy.ParamInt || this.Step > 0 {
+		v = this.Min + math.Round((v-this.Min)/step)*step
+	}
+	return this.convert(p, v)
+}
+
+func (this Range) step(p strategy.Param) float64 {
+	switch {
+	case this.Step > 0:
+		return this.Step
+	case p.Type == strategy.ParamInt:
+		return 1
+	case this.Max > this.Min:
+		return (this.Max - this.Min) / 10
+	default:
+		return 1
+	}
+}
+
+func (this Range) convert(p strategy.Param, v float64) any {
+	if p.Type == strategy.ParamInt {
+		return int(math.Round(v))
+	}
+	//去掉累加产生的小数误差
+	return math.Round(v*1e8) / 1e8
+}
+
+// Request 参数优化请求
+type Request struct {
+	Strategy  string          `json:"strategy"`  //策略名称,需要实现可调参数
+	Params    strategy.Params `json:"params"`    //固定的参数,不参与优化
+	Ranges    []Range         `json:"ranges"`    //参与优化的参数
+	Method    string          `json:"method"`    //grid:网格搜索(默认) random:随机搜索
+	Samples   int             `json:"samples"`   //随机搜索的次数,默认100
+	Seed      int64           `json:"seed"`      //随机种子,0使用当前时间
+	Objective string          `json:"objective"` //优化目标 sharpe(默认)/cagr/calmar/profit_factor/return
+	MaxRuns   int             `json:"max_runs"`  //网格搜索最多的参数组合数量,默认10000
+}
+
+// Row 一组参数的回测结果,多只股票时取平均值
+type Row struct {
+	Rank         int             `json:"rank"`
+	Params       strategy.Params `json:"params"`
+	Objective    float64         `json:"objective"` //优化目标的值
+	Return       float64         `json:"return"`
+	CAGR         float64         `json:"cagr"`
+	Sharpe       float64         `json:"sharpe"`
+	Calmar       float64         `json:"calmar"`
+	MaxDD        float64         `json:"max_drawdown"`
+	ProfitFactor float64         `json:"profit_factor"` //全部交易的总盈利/总亏损,没有亏损时为backtest.InfProfitFactor
+	WinRate      float64         `json:"win_rate"`
+	Trades       int             `json:"trades"` //全部股票的交易数量合计
+	Error        string          `json:"error,omitempty"`
+}
+
+// Progress 优化进度
+type Progress struct {
+	Done  int  `json:"done"`
+	Total int  `json:"total"`
+	Row   *Row `json:"row"`  //刚完成的参数组合
+	Best  *Row `json:"best"` //目前最好的参数组合
+}
+
+// Run 参数优化,每组参数在全部股票上回测,goroutines为并发数量,
+// progress每完成一组参数回调一次,不会并发调用,ctx取消后不再开始新的回测
+func Run(ctx context.Context, req Request, series []backtest.Series, settings backtest.Settings, goroutines int, progress func(p Progress)) (*Optimization, error) {
+	if err := Check(&req); err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return nil, errors.New("没有可用于回测的K线数据")
+	}
+	s := strategy.Get(req.Strategy)
+	if s == nil {
+		return nil, fmt.Errorf("策略[%s]不存在", req.Strategy)
+	}
+	combos, err := Combinations(req, strategy.GetParams(s))
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows := make([]*Row, len(combos))
+	var best *Row
+	done := 0
+	mu := sync.Mutex{}
+	wg := chans.NewWaitLimit(max(goroutines, 1))
+	for i, params := range combos {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add()
+		go func() {
+			defer wg.Done()
+			row := Evaluate(req.Strategy, params, req.Objective, series, settings)
+			mu.Lock()
+			defer mu.Unlock()
+			rows[i] = row
+			done++
+			if row.Error == "" && (best == nil || row.Objective > best.Objective) {
+				best = row
+			}
+			if progress != nil {
+				progress(Progress{Done: done, Total: len(combos), Row: row, Best: best})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Optimization{
+		Strategy:  req.Strategy,
+		Method:    req.Method,
+		Objective: req.Objective,
+		Params:    req.Params,
+		Ranges:    req.Ranges,
+		Codes:     codes(series),
+		Rows:      Rank(rows),
+		Elapsed:   time.Since(start).Milliseconds(),
+		Created:   time.Now().Unix(),
+	}, nil
+}
+
+// Check 校验并填充默认值
+func Check(req *Request) error {
+	if req.Strategy == "" {
+		return errors.New("策略名称不能为空")
+	}
+	if len(req.Ranges) == 0 {
+		return errors.New("没有需要优化的参数")
+	}
+	switch req.Method {
+	case "":
+		req.Method = MethodGrid
+	case MethodGrid, MethodRandom:
+	default:
+		return fmt.Errorf("未知的优化方式[%s],可选grid/random", req.Method)
+	}
+	switch req.Objective {
+	case "":
+		req.Objective = ObjectiveSharpe
+	case ObjectiveSharpe, ObjectiveCAGR, ObjectiveCalmar, ObjectiveProfitFactor, ObjectiveReturn:
+	default:
+		return fmt.Errorf("未知的优化目标[%s],可选sharpe/cagr/calmar/profit_factor/return", req.Objective)
+	}
+	if req.Samples <= 0 {
+		req.Samples = DefaultSamples
+	}
+	if req.MaxRuns <= 0 {
+		req.MaxRuns = DefaultMaxRuns
+	}
+	return nil
+}
+
+// Combinations 生成全部需要回测的参数组合,已经包含固定参数
+func Combinations(req Request, ps []strategy.Param) ([]strategy.Params, error) {
+	if len(ps) == 0 {
+		return nil, fmt.Errorf("策略[%s]不支持参数", req.Strategy)
+	}
+	decl := make([]strategy.Param, len(req.Ranges))
+	for i, r := range req.Ranges {
+		found := false
+		for _, p := range ps {
+			if p.Name == r.Name {
+				decl[i], found = p, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("参数[%s]不存在", r.Name)
+		}
+		if len(r.Values) == 0 && !isNumber(decl[i]) {
+			return nil, fmt.Errorf("参数[%s]不是数值类型,需要指定取值", r.Name)
+		}
+	}
+
+	with := func(values []any) strategy.Params {
+		out := strategy.Params{}
+		for k, v := range req.Params {
+			out[k] = v
+		}
+		for i, r := range req.Ranges {
+			out[r.Name] = values[i]
+		}
+		return out
+	}
+
+	var combos []strategy.Params
+	switch req.Method {
+	case MethodRandom:
+		seed := req.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		r := rand.New(rand.NewSource(seed))
+		seen := map[string]bool{}
+		//取值有限时可能无法生成足够多不重复的组合,限制尝试次数
+		for try := 0; len(combos) < req.Samples && try < req.Samples*10; try++ {
+			values := make([]any, len(req.Ranges))
+			for i, rg := range req.Ranges {
+				values[i] = rg.random(decl[i], r)
+			}
+			if k := key(values); !seen[k] {
+				seen[k] = true
+				combos = append(combos, with(values))
+			}
+		}
+	default:
+		lists := make([][]any, len(req.Ranges))
+		total := 1
+		for i, rg := range req.Ranges {
+			vs, err := rg.values(decl[i])
+			if err != nil {
+				return nil, err
+			}
+			lists[i] = vs
+			total *= len(vs)
+			if total > req.MaxRuns {
+				return nil, fmt.Errorf("参数组合数量超过%d,请减小范围或增大步长", req.MaxRuns)
+			}
+		}
+		idx := make([]int, len(lists))
+		for n := 0; n < total; n++ {
+			values := make([]any, len(lists))
+			for i := range lists {
+				values[i] = lists[i][idx[i]]
+			}
+			combos = append(combos, with(values))
+			//最后一个参数变化最快
+			for i := len(idx) - 1; i >= 0; i-- {
+				idx[i]++
+				if idx[i] < len(lists[i]) {
+					break
+				}
+				idx[i] = 0
+			}
+		}
+	}
+	return combos, nil
+}
+
+// Evaluate 用一组参数在全部股票上回测,指标取平均值,盈利因子按全部交易的总盈利和总亏损计算
+func Evaluate(name string, params strategy.Params, objective string, series []backtest.Series, settings backtest.Settings) *Row {
+	return evaluate(name, params, objective, series, settings, nil)
+}
+
+// evaluate 同Evaluate,每只股票回测完成后回调each
+func evaluate(name string, params strategy.Params, objective string, series []backtest.Series, settings backtest.Settings, each func(se backtest.Series, res backtest.Result)) *Row {
+	row := &Row{Params: params}
+	s, err := strategy.With(name, params)
+	if err != nil {
+		row.Error = err.Error()
+		row.Objective = math.Inf(-1)
+		return row
+	}
+	n := 0
+	var win, loss float64
+	for _, se := range series {
+		if len(se.Day) == 0 {
+			continue
+		}
+		res := backtest.RunBacktestAdvanced(se.Info, se.Day, se.Min, s, settings)
+		if each != nil {
+			each(se, res)
+		}
+		m := res.Metrics
+		row.Return += m.Return
+		row.CAGR += m.CAGR
+		row.Sharpe += m.Sharpe
+		row.Calmar += m.Calmar
+		row.MaxDD += m.MaxDD
+		wins := m.WinRate * float64(m.Trades)
+		win += m.AvgWin * wins
+		loss += m.AvgLoss * (float64(m.Trades) - wins)
+		row.WinRate += m.WinRate
+		row.Trades += m.Trades
+		n++
+	}
+	if n > 0 {
+		row.Return /= float64(n)
+		row.CAGR /= float64(n)
+		row.Sharpe /= float64(n)
+		row.Calmar /= float64(n)
+		row.MaxDD /= float64(n)
+		row.WinRate /= float64(n)
+	}
+	row.ProfitFactor = backtest.ProfitFactor(win, loss)
+	row.Objective = row.objective(objective)
+	return row
+}
+
+func (this *Row) objective(objective string) float64 {
+	switch objective {
+	case ObjectiveCAGR:
+		return this.CAGR
+	case ObjectiveCalmar:
+		return this.Calmar
+	case ObjectiveProfitFactor:
+		return this.ProfitFactor
+	case ObjectiveReturn:
+		return this.Return
+	default:
+		return this.Sharpe
+	}
+}
+
+// Rank 按优化目标从高到低排序,失败的排在最后,目标相同时交易次数多的在前,
+// 只有盈利没有亏损的盈利因子为backtest.InfProfitFactor,排在有亏损的前面
+func Rank(rows []*Row) []Row {
+	out := make([]Row, 0, len(rows))
+	for _, r := range rows {
+		if r != nil {
+			out = append(out, *r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if (out[i].Error == "") != (out[j].Error == "") {
+			return out[i].Error == ""
+		}
+		if out[i].Objective != out[j].Objective {
+			return out[i].Objective > out[j].Objective
+		}
+		return out[i].Trades > out[j].Trades
+	})
+	for i := range out {
+		out[i].Rank = i + 1
+		if math.IsInf(out[i].Objective, 0) {
+			//json不支持无穷大
+			out[i].Objective = 0
+		}
+	}
+	return out
+}
+
+func isNumber(p strategy.Param) bool {
+	return p.Type == strategy.ParamInt || p.Type == strategy.ParamFloat
+}
+
+func key(values []any) string {
+	ss := make([]string, len(values))
+	for i, v := range values {
+		ss[i] = conv.String(v)
+	}
+	return strings.Join(ss, ",")
+}
+
+func codes(series []backtest.Series) []string {
+	out := make([]string, 0, len(series))
+	for _, s := range series {
+		out = append(out, s.Info.Code)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package optimize
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+func TestRank(t *testing.T) {
+	rows := []*Row{
+		{Params: map[string]any{"n": 1}, Objective: 2, Trades: 5},
+		{Params: map[string]any{"n": 2}, Objective: math.Inf(-1), Error: "失败"},
+		{Params: map[string]any{"n": 3}, Objective: backtest.InfProfitFactor, Trades: 3},
+		nil,
+		{Params: map[string]any{"n": 4}, Objective: 0.5, Trades: 9},
+		{Params: map[string]any{"n": 5}, Objective: 2, Trades: 8},
+	}
+	out := Rank(rows)
+	want := []int{3, 5, 1, 4, 2}
+	if len(out) != len(want) {
+		t.Fatalf("排序结果 %d 条", len(out))
+	}
+	for i, n := range want {
+		if out[i].Params["n"] != n || out[i].Rank != i+1 {
+			t.Errorf("第%d名 %+v, 期望参数 %d", i+1, out[i], n)
+		}
+	}
+	//失败的目标值是负无穷,json不支持
+	if _, err := json.Marshal(out); err != nil {
+		t.Error(err)
+	}
+}
+
+func f64(f float64) *float64 { return &f }
+
+var testParams = []strategy.Param{
+	{Name: "N", Type: strategy.ParamInt, Min: f64(1), Max: f64(100)},
+	{Name: "Rate", Type: strategy.ParamFloat},
+	{Name: "On", Type: strategy.ParamBool},
+	{Name: "Label", Type: strategy.ParamString},
+}
+
+func TestCombinations(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		req  Request
+		want []strategy.Params
+		err  bool
+	}{
+		{"网格,最后一个参数变化最快,包含固定参数", Request{
+			Params: strategy.Params{"On": true},
+			Ranges: []Range{{Name: "N", Min: 1, Max: 2}, {Name: "Rate", Min: 0, Max: 0.2, Step: 0.1}},
+		}, []strategy.Params{
+			{"On": true, "N": 1, "Rate": 0.0}, {"On": true, "N": 1, "Rate": 0.1}, {"On": true, "N": 1, "Rate": 0.2},
+			{"On": true, "N": 2, "Rate": 0.0}, {"On": true, "N": 2, "Rate": 0.1}, {"On": true, "N": 2, "Rate": 0.2},
+		}, false},
+		{"小数默认10等分,包含最大值", Request{Ranges: []Range{{Name: "Rate", Min: 0, Max: 0.5}}}, []strategy.Params{
+			{"Rate": 0.0}, {"Rate": 0.05}, {"Rate": 0.1}, {"Rate": 0.15}, {"Rate": 0.2}, {"Rate": 0.25},
+			{"Rate": 0.3}, {"Rate": 0.35}, {"Rate": 0.4}, {"Rate": 0.45}, {"Rate": 0.5},
+		}, false},
+		{"整数步长", Request{Ranges: []Range{{Name: "N", Min: 5, Max: 20, Step: 5}}}, []strategy.Params{
+			{"N": 5}, {"N": 10}, {"N": 15}, {"N": 20},
+		}, false},
+		{"指定取值", Request{Ranges: []Range{{Name: "Label", Values: []any{"a", "b"}}, {Name: "On", Values: []any{true, false}}}}, []strategy.Params{
+			{"Label": "a", "On": true}, {"Label": "a", "On": false}, {"Label": "b", "On": true}, {"Label": "b", "On": false},
+		}, false},
+		{"参数不存在", Request{Ranges: []Range{{Name: "M", Min: 1, Max: 2}}}, nil, true},
+		{"非数值参数没有指定取值", Request{Ranges: []Range{{Name: "On"}}}, nil, true},
+		{"最大值小于最小值", Request{Ranges: []Range{{Name: "N", Min: 5, Max: 1}}}, nil, true},
+		{"超过最多组合数量", Request{MaxRuns: 10, Ranges: []Range{{Name: "N", Min: 1, Max: 4}, {Name: "Rate", Min: 0, Max: 0.2, Step: 0.1}}}, nil, true},
+	} {
+		c.req.Strategy = "测试"
+		if err := Check(&c.req); err != nil {
+			t.Fatalf("[%s] %v", c.name, err)
+		}
+		got, err := Combinations(c.req, testParams)
+		if (err != nil) != c.err {
+			t.Errorf("[%s] 错误 %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("[%s] %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+
+	if _, err := Combinations(Request{Strategy: "测试", Ranges: []Range{{Name: "N"}}}, nil); err == nil {
+		t.Error("不支持参数的策略需要返回错误")
+	}
+}
+
+func TestCombinationsRandom(t *testing.T) {
+	req := Request{
+		Strategy: "测试",
+		Method:   MethodRandom,
+		Samples:  20,
+		Seed:     1,
+		Ranges:   []Range{{Name: "N", Min: 1, Max: 100}, {Name: "Rate", Min: 0, Max: 1, Step: 0.25}},
+	}
+	if err := Check(&req); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Combinations(req, testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != req.Samples {
+		t.Fatalf("随机组合 %d 个, 期望 %d", len(got), req.Samples)
+	}
+	seen := map[string]bool{}
+	for _, p := range got {
+		n, ok := p["N"].(int)
+		rate := p["Rate"].(float64)
+		if !ok || n < 1 || n > 100 || rate < 0 || rate > 1 || math.Mod(rate, 0.25) != 0 {
+			t.Errorf("随机组合超出范围或没有对齐步长 %v", p)
+		}
+		k := fmt.Sprint(n, rate)
+		if seen[k] {
+			t.Errorf("重复的组合 %v", p)
+		}
+		seen[k] = true
+	}
+	//相同的种子生成相同的组合
+	again, _ := Combinations(req, testParams)
+	if !reflect.DeepEqual(got, again) {
+		t.Error("相同的种子生成了不同的组合")
+	}
+
+	//取值有限时不重复的组合数量不足
+	req.Ranges = []Range{{Name: "Label", Values: []any{"a", "b"}}}
+	if got, _ = Combinations(req, testParams); len(got) != 2 {
+		t.Errorf("只有2种取值时生成了 %d 个组合", len(got))
+	}
+}
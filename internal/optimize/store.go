@@ -0,0 +1,87 @@
+package optimize
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+// Optimization 一次参数优化的结果,保存到数据库
+type Optimization struct {
+	ID        int64           `xorm:"pk autoincr" json:"id"`
+	Strategy  string          `json:"strategy"`
+	Method    string          `json:"method"`
+	Objective string          `json:"objective"`
+	Params    strategy.Params `xorm:"json" json:"params"` //固定参数
+	Ranges    []Range         `xorm:"json" json:"ranges"`
+	Codes     []string        `xorm:"json" json:"codes"`
+	Rows      []Row           `xorm:"json" json:"rows,omitempty"` //按优化目标排序
+	Elapsed   int64           `json:"elapsed"`                    //耗时,毫秒
+	Created   int64           `json:"created"`
+}
+
+// Best 最好的参数组合
+func (this *Optimization) Best() *Row {
+	if len(this.Rows) == 0 || this.Rows[0].Error != "" {
+		return nil
+	}
+	return &this.Rows[0]
+}
+
+var syncOnce struct {
+	sync.Once
+	err error
+}
+
+func table() error {
+	syncOnce.Do(func() {
+		syncOnce.err = common.DB.Sync2(new(Optimization))
+	})
+	return syncOnce.err
+}
+
+// Save 保存优化结果,保存后ID有值
+func Save(o *Optimization) error {
+	if err := table(); err != nil {
+		return err
+	}
+	_, err := common.DB.Insert(o)
+	return err
+}
+
+// Get 获取优化结果
+func Get(id int64) (*Optimization, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	o := new(Optimization)
+	has, err := common.DB.ID(id).Get(o)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("优化结果[%d]不存在", id)
+	}
+	return o, nil
+}
+
+// List 优化结果列表,不包含明细,按时间倒序
+func List() ([]*Optimization, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Optimization(nil)
+	err := common.DB.Omit("Rows").Desc("ID").Find(&ls)
+	return ls, err
+}
+
+// Delete 删除优化结果
+func Delete(id int64) error {
+	if err := table(); err != nil {
+		return err
+	}
+	_, err := common.DB.ID(id).Delete(new(Optimization))
+	return err
+}
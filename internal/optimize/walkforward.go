@@ -0,0 +1,349 @@
+package optimize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+const (
+	WalkRolling  = "rolling"  //滚动窗口,样本内窗口长度固定,整体向后移动
+	WalkAnchored = "anchored" //锚定窗口,样本内开始时间固定,结束时间向后移动
+
+	DefaultInSample  = 500 //默认样本内交易日数量
+	DefaultOutSample = 120 //默认样本外交易日数量
+	DefaultWarmup    = 250 //默认样本外回测前用于指标预热的交易日数量
+)
+
+// WalkForwardRequest 滚动优化请求,在每个样本内窗口优化参数,用最优参数回测之后的样本外窗口
+type WalkForwardRequest struct {
+	Request
+	Mode      string `json:"mode"`       //rolling:滚动窗口(默认) anchored:锚定窗口
+	InSample  int    `json:"in_sample"`  //样本内交易日数量,默认500
+	OutSample int    `json:"out_sample"` //样本外交易日数量,默认120,同时也是窗口移动的步长
+	Warmup    int    `json:"warmup"`     //样本外回测前用于指标预热的交易日数量,默认250,预热期不交易
+}
+
+// Window 一个样本内窗口和之后的样本外窗口
+type Window struct {
+	Index      int             `json:"index"`
+	InStart    int64           `json:"in_start"`   //样本内开始日期
+	InEnd      int64           `json:"in_end"`     //样本内结束日期(不含),也是样本外开始日期
+	OutEnd     int64           `json:"out_end"`    //样本外结束日期(不含)
+	Params     strategy.Params `json:"params"`     //样本内的最优参数
+	InSample   *Row            `json:"in_sample"`  //最优参数在样本内的结果
+	OutSample  *Row            `json:"out_sample"` //最优参数在样本外的结果
+	Efficiency float64         `json:"efficiency"` //样本外年化收益率/样本内年化收益率
+	Error      string          `json:"error,omitempty"`
+}
+
+// Stability 某个参数在各个窗口的取值,用于判断参数是否稳定
+type Stability struct {
+	Name     string  `json:"name"`
+	Values   []any   `json:"values"`    //每个窗口选中的值,失败的窗口不计入
+	Mean     float64 `json:"mean"`      //平均值,非数值参数为0
+	Std      float64 `json:"std"`       //标准差,非数值参数为0
+	CV       float64 `json:"cv"`        //变异系数,标准差/平均值的绝对值,越小越稳定
+	Mode     any     `json:"mode"`      //选中次数最多的值
+	ModeRate float64 `json:"mode_rate"` //选中次数最多的值的占比
+	Changes  int     `json:"changes"`   //相邻窗口取值变化的次数
+}
+
+// WalkForward 滚动优化结果
+type WalkForward struct {
+	Strategy   string           `json:"strategy"`
+	Mode       string           `json:"mode"`
+	Objective  string           `json:"objective"`
+	InSample   int              `json:"in_sample"`
+	OutSample  int              `json:"out_sample"`
+	Codes      []string         `json:"codes"`
+	Windows    []Window         `json:"windows"`
+	Efficiency float64          `json:"efficiency"` //滚动优化效率,样本外平均年化收益率/样本内平均年化收益率,一般认为大于0.5时参数比较稳健
+	Stability  []Stability      `json:"stability"`
+	Times      []int64          `json:"times"`   //样本外交易日
+	Equity     []float64        `json:"equity"`  //拼接后的样本外资金曲线,每个窗口使用当时的最优参数,多只股票按等权每日再平衡合成
+	Metrics    backtest.Metrics `json:"metrics"` //样本外资金曲线的绩效指标,不包含交易统计
+	Elapsed    int64            `json:"elapsed"` //耗时,毫秒
+}
+
+// WalkProgress 滚动优化进度
+type WalkProgress struct {
+	Window   int     `json:"window"`  //当前窗口,从0开始
+	Windows  int     `json:"windows"` //窗口数量
+	Progress         //当前窗口样本内优化的进度
+	Result   *Window `json:"result,omitempty"` //当前窗口完成后的结果
+}
+
+// CheckWalkForward 校验并填充默认值
+func CheckWalkForward(req *WalkForwardRequest) error {
+	if err := Check(&req.Request); err != nil {
+		return err
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = WalkRolling
+	case WalkRolling, WalkAnchored:
+	default:
+		return fmt.Errorf("未知的窗口方式[%s],可选rolling/anchored", req.Mode)
+	}
+	if req.InSample <= 0 {
+		req.InSample = DefaultInSample
+	}
+	if req.OutSample <= 0 {
+		req.OutSample = DefaultOutSample
+	}
+	if req.Warmup < 0 {
+		return errors.New("预热交易日数量不能小于0")
+	}
+	if req.Warmup == 0 {
+		req.Warmup = DefaultWarmup
+	}
+	return nil
+}
+
+// RunWalkForward 滚动优化,按全部股票的交易日划分窗口,每个窗口先在样本内调用Run优化参数,
+// 再用最优参数回测样本外,最后把各个窗口样本外的收益拼接成一条资金曲线
+func RunWalkForward(ctx context.Context, req WalkForwardRequest, series []backtest.Series, settings backtest.Settings, goroutines int, progress func(p WalkProgress)) (*WalkForward, error) {
+	if err := CheckWalkForward(&req); err != nil {
+		return nil, err
+	}
+	dates := tradingDays(series)
+	if len(dates) <= req.InSample {
+		return nil, fmt.Errorf("交易日数量(%d)不足,需要超过样本内交易日数量(%d)", len(dates), req.InSample)
+	}
+	windows := splitWindows(dates, req.Mode, req.InSample, req.OutSample)
+
+	start := time.Now()
+	cash := settings.Cash
+	if cash <= 0 {
+		cash = 1
+	}
+	result := &WalkForward{
+		Strategy:  req.Strategy,
+		Mode:      req.Mode,
+		Objective: req.Objective,
+		InSample:  req.InSample,
+		OutSample: req.OutSample,
+		Codes:     codes(series),
+	}
+	equity := cash
+	for i := range windows {
+		w := &windows[i]
+		report := func(p Progress, done bool) {
+			if progress != nil {
+				wp := WalkProgress{Window: i, Windows: len(windows), Progress: p}
+				if done {
+					wp.Result = w
+				}
+				progress(wp)
+			}
+		}
+
+		//样本内优化
+		o, err := Run(ctx, req.Request, slice(series, w.InStart, w.InStart, w.InEnd), settings, goroutines, func(p Progress) { report(p, false) })
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && o.Best() == nil {
+			err = errors.New("没有可用的参数组合")
+		}
+		if err != nil {
+			w.Error = err.Error()
+			report(Progress{}, true)
+			continue
+		}
+		best := o.Best()
+		w.Params = best.Params
+		w.InSample = best
+
+		//样本外回测,之前的交易日用于预热,按日期汇总每只股票的日收益
+		oos := settings
+		oos.Start = w.InEnd
+		rets := map[int64][2]float64{} //日期->[收益合计,股票数量]
+		w.OutSample = evaluate(req.Strategy, w.Params, req.Objective, slice(series, warmupStart(dates, w.InEnd, req.Warmup), w.InEnd, w.OutEnd), oos, func(se backtest.Series, res backtest.Result) {
+			addReturns(rets, se, res.Equity, oos.Cash)
+		})
+		if w.InSample.CAGR != 0 {
+			w.Efficiency = w.OutSample.CAGR / w.InSample.CAGR
+		}
+
+		//拼接资金曲线
+		var times []int64
+		var curve []float64
+		times, curve, equity = stitch(equity, rets)
+		result.Times = append(result.Times, times...)
+		result.Equity = append(result.Equity, curve...)
+
+		report(Progress{Done: len(o.Rows), Total: len(o.Rows), Best: best}, true)
+	}
+
+	//滚动优化效率
+	var in, out float64
+	for _, w := range windows {
+		if w.Error == "" {
+			in += w.InSample.CAGR
+			out += w.OutSample.CAGR
+		}
+	}
+	if in > 0 {
+		result.Efficiency = out / in
+	}
+
+	result.Windows = windows
+	result.Stability = stability(req.Ranges, windows)
+	result.Metrics = backtest.Analyze(result.Times, result.Equity, nil, nil, cash, backtest.MetricsConfig{RiskFree: settings.RiskFree, Periods: settings.Periods})
+	result.Elapsed = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// splitWindows 按交易日划分窗口,样本外窗口依次相连,最后一个可能不足outSample个交易日
+// 窗口的结束日期不含,超出最后一个交易日时取最后一个交易日的下一天
+func splitWindows(dates []int64, mode string, inSample, outSample int) []Window {
+	at := func(i int) int64 {
+		if i < len(dates) {
+			return dates[i]
+		}
+		return dates[len(dates)-1] + 24*60*60
+	}
+	var windows []Window
+	for b := inSample; b < len(dates); b += outSample {
+		a := b - inSample
+		if mode == WalkAnchored {
+			a = 0
+		}
+		windows = append(windows, Window{
+			Index:   len(windows),
+			InStart: at(a),
+			InEnd:   at(b),
+			OutEnd:  at(b + outSample),
+		})
+	}
+	return windows
+}
+
+// addReturns 按日期累加一只股票的日收益,equity是回测结果的资金曲线,对应Day的最后几根K线,
+// rets为日期->[收益合计,股票数量]
+func addReturns(rets map[int64][2]float64, se backtest.Series, equity []float64, cash float64) {
+	prev := cash
+	offset := len(se.Day) - len(equity)
+	for j, v := range equity {
+		if prev > 0 {
+			t := data.DayStart(time.Unix(se.Day[offset+j].Unix, 0))
+			r := rets[t]
+			rets[t] = [2]float64{r[0] + v/prev - 1, r[1] + 1}
+		}
+		prev = v
+	}
+}
+
+// stitch 从equity开始按日期顺序复利每天的等权平均收益,返回日期,资金曲线和最后的资金
+func stitch(equity float64, rets map[int64][2]float64) ([]int64, []float64, float64) {
+	days := make([]int64, 0, len(rets))
+	for t := range rets {
+		days = append(days, t)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	curve := make([]float64, len(days))
+	for i, t := range days {
+		equity *= 1 + rets[t][0]/rets[t][1]
+		curve[i] = equity
+	}
+	return days, curve, equity
+}
+
+// stability 统计每个优化参数在各个窗口的取值
+func stability(ranges []Range, windows []Window) []Stability {
+	out := make([]Stability, 0, len(ranges))
+	for _, r := range ranges {
+		s := Stability{Name: r.Name}
+		count := map[string]int{}
+		var xs []float64
+		numeric := true
+		for _, w := range windows {
+			if w.Error != "" {
+				continue
+			}
+			v := w.Params[r.Name]
+			if len(s.Values) > 0 && conv.String(s.Values[len(s.Values)-1]) != conv.String(v) {
+				s.Changes++
+			}
+			s.Values = append(s.Values, v)
+			k := conv.String(v)
+			count[k]++
+			if s.Mode == nil || count[k] > count[conv.String(s.Mode)] {
+				s.Mode = v
+			}
+			switch v.(type) {
+			case int, int64, float64:
+				xs = append(xs, conv.Float64(v))
+			default:
+				numeric = false
+			}
+		}
+		if len(s.Values) > 0 {
+			s.ModeRate = float64(count[conv.String(s.Mode)]) / float64(len(s.Values))
+		}
+		if numeric && len(xs) > 0 {
+			for _, x := range xs {
+				s.Mean += x
+			}
+			s.Mean /= float64(len(xs))
+			for _, x := range xs {
+				s.Std += (x - s.Mean) * (x - s.Mean)
+			}
+			s.Std = math.Sqrt(s.Std / float64(len(xs)))
+			if s.Mean != 0 {
+				s.CV = s.Std / math.Abs(s.Mean)
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// tradingDays 全部股票的交易日(当天0点),从小到大
+func tradingDays(series []backtest.Series) []int64 {
+	m := map[int64]struct{}{}
+	for _, se := range series {
+		for _, k := range se.Day {
+			m[data.DayStart(time.Unix(k.Unix, 0))] = struct{}{}
+		}
+	}
+	out := make([]int64, 0, len(m))
+	for t := range m {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// warmupStart start之前第n个交易日
+func warmupStart(dates []int64, start int64, n int) int64 {
+	i := sort.Search(len(dates), func(i int) bool { return dates[i] >= start })
+	return dates[max(i-n, 0)]
+}
+
+// slice 截取[from,end)之间的K线,只返回在[start,end)之间有日线的股票
+func slice(series []backtest.Series, from, start, end int64) []backtest.Series {
+	out := make([]backtest.Series, 0, len(series))
+	for _, se := range series {
+		a := sort.Search(len(se.Day), func(i int) bool { return se.Day[i].Unix >= from })
+		b := sort.Search(len(se.Day), func(i int) bool { return se.Day[i].Unix >= end })
+		s := sort.Search(len(se.Day), func(i int) bool { return se.Day[i].Unix >= start })
+		if s >= b {
+			continue
+		}
+		ma := sort.Search(len(se.Min), func(i int) bool { return se.Min[i].Unix >= from })
+		mb := sort.Search(len(se.Min), func(i int) bool { return se.Min[i].Unix >= end })
+		out = append(out, backtest.Series{Info: se.Info, Day: se.Day[a:b], Min: se.Min[ma:mb]})
+	}
+	return out
+}
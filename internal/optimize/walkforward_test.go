@@ -0,0 +1,149 @@
+package optimize
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// wfDay 2024-01-01之后第i天0点
+func wfDay(i int) int64 {
+	return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.Local).Unix()
+}
+
+// wfKlines 第from到第to天(含)每天15:00的日线
+func wfKlines(from, to int) extend.Klines {
+	var ks extend.Klines
+	for i := from; i <= to; i++ {
+		t := time.Date(2024, 1, 1+i, 15, 0, 0, 0, time.Local)
+		ks = append(ks, &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{Time: t}})
+	}
+	return ks
+}
+
+func wfDates(n int) []int64 {
+	dates := make([]int64, n)
+	for i := range dates {
+		dates[i] = wfDay(i)
+	}
+	return dates
+}
+
+func TestSplitWindows(t *testing.T) {
+	dates := wfDates(10)
+	// 窗口: 样本内开始,样本外开始,样本外结束,超出最后一个交易日的取下一天
+	for _, c := range []struct {
+		name    string
+		mode    string
+		in, out int
+		want    [][3]int64
+	}{
+		{"滚动窗口", WalkRolling, 4, 3, [][3]int64{{wfDay(0), wfDay(4), wfDay(7)}, {wfDay(3), wfDay(7), wfDay(10)}}},
+		{"锚定窗口", WalkAnchored, 4, 3, [][3]int64{{wfDay(0), wfDay(4), wfDay(7)}, {wfDay(0), wfDay(7), wfDay(10)}}},
+		{"最后一个样本外不足", WalkRolling, 5, 3, [][3]int64{{wfDay(0), wfDay(5), wfDay(8)}, {wfDay(3), wfDay(8), wfDay(10)}}},
+		{"样本外超过剩余交易日", WalkRolling, 8, 5, [][3]int64{{wfDay(0), wfDay(8), wfDay(10)}}},
+		{"样本内不足", WalkRolling, 10, 3, nil},
+	} {
+		ws := splitWindows(dates, c.mode, c.in, c.out)
+		if len(ws) != len(c.want) {
+			t.Errorf("[%s] %d 个窗口, 期望 %d", c.name, len(ws), len(c.want))
+			continue
+		}
+		for i, w := range ws {
+			if w.Index != i || [3]int64{w.InStart, w.InEnd, w.OutEnd} != c.want[i] {
+				t.Errorf("[%s] 第%d个窗口 %+v", c.name, i, w)
+			}
+		}
+	}
+}
+
+func TestWarmupStart(t *testing.T) {
+	dates := wfDates(10)
+	for _, c := range []struct {
+		name  string
+		start int64
+		n     int
+		want  int64
+	}{
+		{"之前第n个交易日", wfDay(5), 2, wfDay(3)},
+		{"不预热", wfDay(5), 0, wfDay(5)},
+		{"交易日不足", wfDay(5), 10, wfDay(0)},
+		{"开始日期不是交易日", wfDay(5) + 3600, 2, wfDay(4)},
+		{"开始日期在最后一个交易日之后", wfDay(12), 2, wfDay(8)},
+	} {
+		if got := warmupStart(dates, c.start, c.n); got != c.want {
+			t.Errorf("[%s] %v, 期望 %v", c.name, time.Unix(got, 0), time.Unix(c.want, 0))
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	//每天10:00一根分钟线
+	var mins extend.Klines
+	for _, k := range wfKlines(0, 9) {
+		tm := time.Unix(k.Unix, 0).Add(-5 * time.Hour)
+		mins = append(mins, &extend.Kline{Unix: tm.Unix(), Kline: &protocol.Kline{Time: tm}})
+	}
+	series := []backtest.Series{
+		{Info: extend.Info{Code: "a"}, Day: wfKlines(0, 9), Min: mins},
+		{Info: extend.Info{Code: "b"}, Day: wfKlines(6, 9)}, //区间之后才上市
+		{Info: extend.Info{Code: "c"}, Day: wfKlines(0, 2)}, //区间之前已经停牌
+		{Info: extend.Info{Code: "d"}, Day: wfKlines(0, 3)}, //区间内有日线
+	}
+	out := slice(series, wfDay(1), wfDay(3), wfDay(6))
+	if len(out) != 2 || out[0].Info.Code != "a" || out[1].Info.Code != "d" {
+		t.Fatalf("截取 %d 只股票", len(out))
+	}
+	//包含预热的[from,end)
+	if !reflect.DeepEqual(out[0].Day, series[0].Day[1:6]) || !reflect.DeepEqual(out[0].Min, mins[1:6]) {
+		t.Errorf("日线 %d 根, 分钟线 %d 根", len(out[0].Day), len(out[0].Min))
+	}
+	if !reflect.DeepEqual(out[1].Day, series[3].Day[1:4]) || len(out[1].Min) != 0 {
+		t.Errorf("日线 %d 根", len(out[1].Day))
+	}
+}
+
+func TestStitch(t *testing.T) {
+	rets := map[int64][2]float64{}
+	//资金曲线对应日线的最后几根,之前的是预热期
+	addReturns(rets, backtest.Series{Day: wfKlines(0, 9)}, []float64{100, 110, 99}, 100)
+	addReturns(rets, backtest.Series{Day: wfKlines(5, 9)}, []float64{100, 100}, 100)
+	want := map[int64][2]float64{
+		wfDay(7): {0, 1},
+		wfDay(8): {0.1, 2},
+		wfDay(9): {-0.1, 2},
+	}
+	if len(rets) != len(want) {
+		t.Fatalf("日收益 %v", rets)
+	}
+	for d, w := range want {
+		if r := rets[d]; math.Abs(r[0]-w[0]) > 1e-9 || r[1] != w[1] {
+			t.Errorf("%v 日收益 %v, 期望 %v", time.Unix(d, 0), r, w)
+		}
+	}
+
+	//按日期顺序复利等权平均收益
+	times, curve, end := stitch(1000, rets)
+	if !reflect.DeepEqual(times, []int64{wfDay(7), wfDay(8), wfDay(9)}) {
+		t.Errorf("日期 %v", times)
+	}
+	wantCurve := []float64{1000, 1050, 997.5}
+	for i := range wantCurve {
+		if math.Abs(curve[i]-wantCurve[i]) > 1e-9 {
+			t.Fatalf("资金曲线 %v, 期望 %v", curve, wantCurve)
+		}
+	}
+	if end != curve[len(curve)-1] {
+		t.Errorf("最后的资金 %v", end)
+	}
+
+	//没有收益时资金不变
+	if times, curve, end = stitch(1000, nil); len(times) != 0 || len(curve) != 0 || end != 1000 {
+		t.Errorf("没有收益时 %v %v %v", times, curve, end)
+	}
+}
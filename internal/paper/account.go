@@ -0,0 +1,400 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/alert"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/quote"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// Account 模拟账户,每天数据更新完成后按策略信号下单,按回测相同的手续费,滑点和交易规则成交
+type Account struct {
+	ID          int64                      `xorm:"pk autoincr" json:"id"`
+	Name        string                     `json:"name"`
+	Strategies  []string                   `xorm:"json" json:"strategies"`
+	Tree        *strategy.Node             `xorm:"json" json:"tree"`   //组合策略树,优先于Strategies
+	Params      map[string]strategy.Params `xorm:"json" json:"params"` //策略参数覆盖,策略名称->参数
+	Codes       []string                   `xorm:"json" json:"codes"`  //股票池
+	WatchlistID int64                      `json:"watchlist_id"`       //使用自选股分组作为股票池,和Codes合并
+	Cash        float64                    `json:"cash"`               //初始资金,默认100000
+	Balance     float64                    `json:"balance"`            //可用现金
+	FeeRate     float64                    `json:"fee_rate"`           //默认0.0005
+	MinFee      float64                    `json:"min_fee"`            //默认5
+	Slippage    float64                    `json:"slippage"`
+	StopLoss    float64                    `json:"stop_loss"`
+	TakeProfit  float64                    `json:"take_profit"`
+	Size        int                        `json:"size"`              //固定股数
+	Sizer       *backtest.SizerConfig      `xorm:"json" json:"sizer"` //仓位模型,为空时按Size固定股数,Size也为空时按股票池等权
+	Market      string                     `json:"market"`            //交易规则,cn:A股规则(T+1,整手,涨跌停,印花税,过户费,停牌),空不限制
+	Returns     []float64                  `xorm:"json" json:"-"`     //已平仓交易的收益率,用于凯利公式
+	Enable      bool                       `json:"enable"`
+	LastBar     int64                      `json:"last_bar"` //最后处理的K线时间
+	Created     int64                      `xorm:"created" json:"created"`
+	Updated     int64                      `xorm:"updated" json:"updated"`
+
+	Value  float64 `xorm:"-" json:"value"`  //持仓市值
+	Equity float64 `xorm:"-" json:"equity"` //总资产
+	PnL    float64 `xorm:"-" json:"pnl"`    //总盈亏
+	Return float64 `xorm:"-" json:"return"` //总收益率
+}
+
+// Check 检查配置并填充默认值
+func (this *Account) Check() error {
+	if this.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if len(this.Codes) == 0 && this.WatchlistID == 0 {
+		return errors.New("股票池不能为空")
+	}
+	if this.WatchlistID > 0 {
+		if _, err := alert.GetWatchlist(this.WatchlistID); err != nil {
+			return err
+		}
+	}
+	for i := range this.Codes {
+		this.Codes[i] = strings.ToLower(strings.TrimSpace(this.Codes[i]))
+	}
+	if this.Cash <= 0 {
+		this.Cash = 100000
+	}
+	if this.FeeRate <= 0 {
+		this.FeeRate = 0.0005
+	}
+	if this.MinFee <= 0 {
+		this.MinFee = 5
+	}
+	if _, err := strategy.Build(this.Tree, this.Strategies, nil, this.Params); err != nil {
+		return err
+	}
+	if this.Sizer != nil {
+		if _, err := backtest.NewSizer(*this.Sizer, this.FeeRate); err != nil {
+			return err
+		}
+	}
+	switch this.Market {
+	case backtest.MarketNone, backtest.MarketCN:
+	default:
+		return fmt.Errorf("未知的交易规则[%s]", this.Market)
+	}
+	return nil
+}
+
+// universe 股票池,合并Codes和自选股分组
+func (this *Account) universe() ([]string, error) {
+	codes := append([]string(nil), this.Codes...)
+	if this.WatchlistID > 0 {
+		w, err := alert.GetWatchlist(this.WatchlistID)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, w.Codes...)
+	}
+	out := make([]string, 0, len(codes))
+	set := map[string]struct{}{}
+	for _, code := range codes {
+		if _, ok := set[code]; !ok && code != "" {
+			set[code] = struct{}{}
+			out = append(out, code)
+		}
+	}
+	return out, nil
+}
+
+// settings 和回测使用相同的配置
+func (this *Account) settings() backtest.Settings {
+	return backtest.Settings{
+		Cash:       this.Cash,
+		Size:       this.Size,
+		FeeRate:    this.FeeRate,
+		MinFee:     this.MinFee,
+		Slippage:   this.Slippage,
+		StopLoss:   this.StopLoss,
+		TakeProfit: this.TakeProfit,
+		Market:     this.Market,
+	}
+}
+
+// sizer 仓位模型,未配置时按固定股数,股数也未配置时按股票池等权
+func (this *Account) sizer(n int) (backtest.Sizer, error) {
+	switch {
+	case this.Sizer != nil:
+		return backtest.NewSizer(*this.Sizer, this.FeeRate)
+	case this.Size > 0:
+		return backtest.FixedShares{Shares: this.Size}, nil
+	default:
+		return backtest.PercentEquity{Percent: 1 / float64(max(n, 1)), FeeRate: this.FeeRate}, nil
+	}
+}
+
+// fill 按持仓计算市值和盈亏
+func (this *Account) fill(ps []*Position) {
+	this.Value = 0
+	for _, p := range ps {
+		this.Value += p.Value
+	}
+	this.Equity = this.Balance + this.Value
+	this.PnL = this.Equity - this.Cash
+	if this.Cash > 0 {
+		this.Return = this.PnL / this.Cash
+	}
+}
+
+// Position 持仓
+type Position struct {
+	ID        int64   `xorm:"pk autoincr" json:"id"`
+	AccountID int64   `json:"account_id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Qty       int     `json:"qty"`
+	Cost      float64 `json:"cost"`     //持仓均价,包含滑点,不包含手续费
+	Price     float64 `json:"price"`    //最新价
+	Value     float64 `json:"value"`    //市值
+	PnL       float64 `json:"pnl"`      //浮动盈亏,不包含手续费
+	Return    float64 `json:"return"`   //浮动收益率
+	BuyTime   int64   `json:"buy_time"` //最近一次买入时间,用于T+1
+	Adds      int     `json:"adds"`     //加仓次数
+	LastBuy   float64 `json:"last_buy"` //最近一次买入价
+	Pending   string  `json:"pending"`  //被交易规则挡住的卖出原因,之后继续尝试卖出
+	Updated   int64   `xorm:"updated" json:"updated"`
+}
+
+// mark 按最新价计算市值和盈亏
+func (this *Position) mark(price float64) {
+	this.Price = price
+	this.Value = price * float64(this.Qty)
+	this.PnL = (price - this.Cost) * float64(this.Qty)
+	if this.Cost > 0 {
+		this.Return = price/this.Cost - 1
+	}
+}
+
+// xrxd 按除权除息调整持仓,返回现金分红和送转的股数,
+// 送转股按比例增加数量(不足1股舍去),均价和最近买入价按总成本扣除分红后摊薄,
+// 配股需要缴款认购,模拟账户不参与
+func (this *Position) xrxd(x *protocol.XRXD) (float64, int) {
+	dividend := float64(this.Qty) * x.Fenhong / 10
+	shares := int(float64(this.Qty) * x.Songzhuangu / 10)
+	if qty := this.Qty + shares; qty > 0 {
+		ratio := float64(this.Qty) / float64(qty)
+		this.Cost = (this.Cost - x.Fenhong/10) * ratio
+		this.LastBuy = (this.LastBuy - x.Fenhong/10) * ratio
+		this.Qty = qty
+	}
+	return dividend, shares
+}
+
+const (
+	StatusFilled   = "filled"   //已成交
+	StatusRejected = "rejected" //未成交,例涨停买不进,现金不足
+)
+
+// Order 订单,按K线收盘价加滑点成交
+type Order struct {
+	ID        int64   `xorm:"pk autoincr" json:"id"`
+	AccountID int64   `json:"account_id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Side      string  `json:"side"` //buy/sell/xrxd(除权除息,Qty为送转股数,Amount为现金分红)
+	Qty       int     `json:"qty"`
+	Price     float64 `json:"price"`  //成交价,未成交时为委托价
+	Amount    float64 `json:"amount"` //成交额,未成交时为0
+	Fee       float64 `json:"fee"`    //手续费和税费
+	PnL       float64 `json:"pnl"`    //卖出的盈亏,扣除卖出手续费
+	Status    string  `json:"status"` //filled/rejected
+	Reason    string  `json:"reason"` //下单原因,信号/止损/止盈或策略给出的说明
+	Message   string  `json:"message"`
+	Time      int64   `json:"time"` //K线时间
+	Created   int64   `xorm:"created" json:"created"`
+}
+
+// EquityPoint 每个交易日收盘后的资产
+type EquityPoint struct {
+	ID        int64   `xorm:"pk autoincr" json:"id"`
+	AccountID int64   `json:"account_id"`
+	Time      int64   `json:"time"` //K线时间
+	Cash      float64 `json:"cash"`
+	Value     float64 `json:"value"`
+	Equity    float64 `json:"equity"`
+	Return    float64 `json:"return"` //相对初始资金的收益率
+}
+
+var syncOnce struct {
+	sync.Once
+	err error
+}
+
+func table() error {
+	syncOnce.Do(func() {
+		syncOnce.err = common.DB.Sync2(new(Account), new(Position), new(Order), new(EquityPoint))
+	})
+	return syncOnce.err
+}
+
+// SaveAccount 新建或修改模拟账户,新建时可用现金为初始资金,修改不影响资金和持仓
+func SaveAccount(a *Account) error {
+	if err := table(); err != nil {
+		return err
+	}
+	if err := a.Check(); err != nil {
+		return err
+	}
+	if a.ID == 0 {
+		a.Balance = a.Cash
+		a.Returns, a.LastBar = nil, 0
+		_, err := common.DB.Insert(a)
+		return err
+	}
+	old, err := GetAccount(a.ID)
+	if err != nil {
+		return err
+	}
+	a.Cash, a.Balance, a.Returns, a.LastBar, a.Created = old.Cash, old.Balance, old.Returns, old.LastBar, old.Created
+	_, err = common.DB.ID(a.ID).AllCols().Update(a)
+	return err
+}
+
+// EnableAccount 启用或暂停模拟账户,暂停期间不下单
+func EnableAccount(id int64, enable bool) error {
+	a, err := GetAccount(id)
+	if err != nil {
+		return err
+	}
+	a.Enable = enable
+	_, err = common.DB.ID(id).Cols("Enable").Update(a)
+	return err
+}
+
+// GetAccount 获取模拟账户,包含市值和盈亏
+func GetAccount(id int64) (*Account, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	a := new(Account)
+	has, err := common.DB.ID(id).Get(a)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("模拟账户[%d]不存在", id)
+	}
+	ps, err := ListPosition(id)
+	if err != nil {
+		return nil, err
+	}
+	a.fill(ps)
+	return a, nil
+}
+
+// ListAccount 模拟账户列表,包含市值和盈亏
+func ListAccount() ([]*Account, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Account(nil)
+	if err := common.DB.Asc("ID").Find(&ls); err != nil {
+		return nil, err
+	}
+	ps := []*Position(nil)
+	if err := common.DB.Find(&ps); err != nil {
+		return nil, err
+	}
+	markLatest(ps)
+	m := map[int64][]*Position{}
+	for _, p := range ps {
+		m[p.AccountID] = append(m[p.AccountID], p)
+	}
+	for _, a := range ls {
+		a.fill(m[a.ID])
+	}
+	return ls, nil
+}
+
+// DeleteAccount 删除模拟账户及持仓,订单和资产记录
+func DeleteAccount(id int64) error {
+	if err := table(); err != nil {
+		return err
+	}
+	for _, v := range []any{new(Position), new(Order), new(EquityPoint)} {
+		if _, err := common.DB.Where("AccountID=?", id).Delete(v); err != nil {
+			return err
+		}
+	}
+	_, err := common.DB.ID(id).Delete(new(Account))
+	return err
+}
+
+// ListPosition 持仓,按最新价计算市值和盈亏
+func ListPosition(accountID int64) ([]*Position, error) {
+	ls, err := listPosition(accountID)
+	if err != nil {
+		return nil, err
+	}
+	markLatest(ls)
+	return ls, nil
+}
+
+// listPosition 数据库中保存的持仓,价格是最后一次模拟交易时的收盘价
+func listPosition(accountID int64) ([]*Position, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Position(nil)
+	err := common.DB.Where("AccountID=?", accountID).Asc("Code").Find(&ls)
+	return ls, err
+}
+
+// markLatest 按最新价估值,实时行情服务启动后包含盘中价格,读取不到时沿用保存的价格
+func markLatest(ps []*Position) {
+	var reader data.Reader = common.Klines
+	if quote.Default != nil {
+		reader = quote.Default
+	}
+	now := time.Now()
+	for _, p := range ps {
+		ks, err := reader.GetDayKlines(p.Code, now.AddDate(0, 0, -30), now)
+		if err != nil {
+			logs.Err(err)
+			continue
+		}
+		if len(ks) > 0 {
+			p.mark(ks[len(ks)-1].Close.Float64())
+		}
+	}
+}
+
+// ListOrder 订单,按时间倒序,code为空时返回全部,limit为0时不限制
+func ListOrder(accountID int64, code string, limit int) ([]*Order, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Order(nil)
+	session := common.DB.Where("AccountID=?", accountID).Desc("ID")
+	if code != "" {
+		session = session.And("Code=?", strings.ToLower(code))
+	}
+	if limit > 0 {
+		session = session.Limit(limit)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
+
+// ListEquity 每个交易日的资产
+func ListEquity(accountID int64) ([]*EquityPoint, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*EquityPoint(nil)
+	err := common.DB.Where("AccountID=?", accountID).Asc("Time").Find(&ls)
+	return ls, err
+}
@@ -0,0 +1,41 @@
+package paper
+
+import (
+	"math"
+	"testing"
+
+	"github.com/injoyai/tdx/protocol"
+)
+
+func TestPositionXRXD(t *testing.T) {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	for _, c := range []struct {
+		name      string
+		x         protocol.XRXD
+		qty       int
+		cost      float64
+		dividend  float64
+		shares    int
+		wantQty   int
+		wantCost  float64
+		wantValue float64 //除权后按除权价计算的市值加分红,和除权前的市值一致
+	}{
+		//10派5元,除权价10-0.5
+		{"分红", protocol.XRXD{Fenhong: 5}, 1000, 10, 500, 0, 1000, 9.5, 10000},
+		//10转5股,除权价10/1.5
+		{"送转", protocol.XRXD{Songzhuangu: 5}, 1000, 10, 0, 500, 1500, 10.0 / 1.5, 10000},
+		//10派2元转3股,除权价(10-0.2)/1.3
+		{"分红送转", protocol.XRXD{Fenhong: 2, Songzhuangu: 3}, 1000, 10, 200, 300, 1300, 9.8 / 1.3, 10000},
+		//不足1股舍去,总成本不变
+		{"舍去零股", protocol.XRXD{Songzhuangu: 3}, 105, 10, 0, 31, 136, 1050.0 / 136, 1050},
+	} {
+		p := &Position{Qty: c.qty, Cost: c.cost, LastBuy: c.cost}
+		dividend, shares := p.xrxd(&c.x)
+		if !near(dividend, c.dividend) || shares != c.shares || p.Qty != c.wantQty || !near(p.Cost, c.wantCost) || !near(p.LastBuy, c.wantCost) {
+			t.Errorf("[%s] 分红 %v 送转 %d 持仓 %+v", c.name, dividend, shares, p)
+		}
+		if v := p.Cost*float64(p.Qty) + dividend; !near(v, c.wantValue) {
+			t.Errorf("[%s] 除权后总值 %v, 期望 %v", c.name, v, c.wantValue)
+		}
+	}
+}
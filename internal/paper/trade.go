@@ -0,0 +1,386 @@
+package paper
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+	"xorm.io/xorm"
+)
+
+// history 策略计算读取的日线天数(自然日)
+const history = 400
+
+// runMu 同时只处理一个账户,避免数据更新和手动执行重复下单
+var runMu sync.Mutex
+
+// Step 一次模拟交易的结果
+type Step struct {
+	Time    int64    `json:"time"`   //处理的K线时间,0表示没有新的K线
+	Orders  []*Order `json:"orders"` //本次的订单,包含未成交的
+	Account *Account `json:"account"`
+}
+
+// asset 股票池中单只股票当天的状态
+type asset struct {
+	info  extend.Info
+	day   extend.Klines
+	bar   *extend.Kline //最新K线,当天没有K线时为nil,只更新市值不交易
+	price float64       //最新收盘价
+	last  float64       //前收盘价
+	rules backtest.Rules
+	d     strategy.Decision
+	score float64
+	pos   *Position
+}
+
+// Start 注册数据更新完成后执行模拟交易,需要在数据开始更新之前调用
+func Start() {
+	common.Data.OnUpdated(RunAll)
+}
+
+// RunAll 执行全部启用的模拟账户
+func RunAll() {
+	ls, err := ListAccount()
+	if err != nil {
+		logs.Err(err)
+		return
+	}
+	for _, a := range ls {
+		if a.Enable {
+			_, err = Run(a.ID)
+			logs.PrintErr(err)
+		}
+	}
+}
+
+// Run 用最新的日线执行一次模拟交易,每根K线只处理一次,先卖出再按评分从高到低买入,
+// 按收盘价加滑点成交,手续费,止损止盈,仓位模型和交易规则和回测一致
+func Run(id int64) (*Step, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	a, err := GetAccount(id)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := a.universe()
+	if err != nil {
+		return nil, err
+	}
+	strat, err := strategy.Build(a.Tree, a.Strategies, nil, a.Params)
+	if err != nil {
+		return nil, err
+	}
+	sizer, err := a.sizer(len(codes))
+	if err != nil {
+		return nil, err
+	}
+	cfg := a.settings()
+	ps, err := listPosition(id)
+	if err != nil {
+		return nil, err
+	}
+	//已经不在股票池的持仓也需要处理卖出
+	held := map[string]*Position{}
+	for _, p := range ps {
+		held[p.Code] = p
+		codes = append(codes, p.Code)
+	}
+
+	//读取日线,找到最新的交易日
+	now := time.Now()
+	var latest int64
+	assets := []*asset(nil)
+	seen := map[string]struct{}{}
+	for _, code := range codes {
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		day, err := common.Klines.GetDayKlinesAdjust(code, now.AddDate(0, 0, -history), now, data.AdjustQFQ)
+		if err != nil {
+			logs.Err(err)
+			continue
+		}
+		if len(day) == 0 {
+			continue
+		}
+		latest = max(latest, day[len(day)-1].Unix)
+		assets = append(assets, &asset{day: day, pos: held[code], info: extend.Info{Code: code, Name: common.Data.Codes.GetName(code)}})
+	}
+	if latest <= a.LastBar {
+		return &Step{Account: a}, nil
+	}
+
+	//除权除息在开盘前生效,先调整持仓再估值和交易
+	orders := corporateActions(a, ps, latest)
+
+	for _, v := range assets {
+		k := v.day[len(v.day)-1]
+		v.price = k.Close.Float64()
+		if !data.SameDay(k.Unix, latest) {
+			continue
+		}
+		v.bar = k
+		v.info = data.NewInfo(v.info.Code, v.info.Name, k)
+		v.rules = backtest.GetRules(cfg.Market, v.info.Code)
+		if k.Last > 0 {
+			v.last = k.Last.Float64()
+		} else if len(v.day) > 1 {
+			v.last = v.day[len(v.day)-2].Close.Float64()
+		}
+		v.d = strategy.Decide(strat, v.info, v.day, nil)
+		if v.d.Action == strategy.Buy {
+			v.score, _ = strategy.Score(strat, v.info, v.day, nil)
+		}
+	}
+
+	order := func(v *asset, side string, qty int, px float64, reason string) *Order {
+		return &Order{
+			AccountID: a.ID,
+			Code:      v.info.Code,
+			Name:      v.info.Name,
+			Side:      side,
+			Qty:       qty,
+			Price:     px,
+			Status:    StatusFilled,
+			Reason:    reason,
+			Time:      v.bar.Unix,
+		}
+	}
+	var sold []*Position
+
+	//卖出
+	for _, v := range assets {
+		if v.bar == nil || v.pos == nil {
+			continue
+		}
+		p := v.pos
+		fill := cfg.Fill(v.rules, v.info, v.bar, v.last, p.BuyTime)
+		sellPx := fill.SellPrice
+		reason := ""
+		switch {
+		case v.d.Action == strategy.Sell:
+			reason = v.d.ReasonOr("signal")
+		case p.Pending != "":
+			reason = p.Pending
+		case cfg.StopLoss > 0 && p.Cost > 0 && (sellPx-p.Cost)/p.Cost <= -cfg.StopLoss:
+			reason = "stop_loss"
+		case cfg.TakeProfit > 0 && p.Cost > 0 && (sellPx-p.Cost)/p.Cost >= cfg.TakeProfit:
+			reason = "take_profit"
+		}
+		if reason == "" {
+			continue
+		}
+		o := order(v, "sell", p.Qty, sellPx, reason)
+		if !fill.CanSell() {
+			p.Pending = reason
+			o.Status, o.Message = StatusRejected, fill.NoSell
+			orders = append(orders, o)
+			continue
+		}
+		o.Amount = sellPx * float64(p.Qty)
+		o.Fee = cfg.Fee(v.rules, "sell", o.Amount)
+		o.PnL = (sellPx-p.Cost)*float64(p.Qty) - o.Fee
+		a.Balance += o.Amount - o.Fee
+		if p.Cost > 0 {
+			a.Returns = append(a.Returns, (sellPx-p.Cost)/p.Cost)
+		}
+		orders = append(orders, o)
+		sold = append(sold, p)
+		v.pos = nil
+	}
+
+	//买入,按评分从高到低,总资产使用卖出之后的现金和当前市值
+	equity := a.Balance
+	for _, v := range assets {
+		if v.pos != nil {
+			equity += v.price * float64(v.pos.Qty)
+		}
+	}
+	buys := []*asset(nil)
+	for _, v := range assets {
+		if v.bar != nil && v.d.Action == strategy.Buy {
+			buys = append(buys, v)
+		}
+	}
+	sort.SliceStable(buys, func(i, j int) bool {
+		if buys[i].score != buys[j].score {
+			return buys[i].score > buys[j].score
+		}
+		return buys[i].info.Code < buys[j].info.Code
+	})
+	for _, v := range buys {
+		//买入不受T+1限制
+		fill := cfg.Fill(v.rules, v.info, v.bar, v.last, 0)
+		buyPx := fill.BuyPrice
+		lot := 0
+		if v.rules != nil {
+			lot = v.rules.Lot(v.info)
+		}
+		ctx := backtest.SizeContext{
+			Info:    v.info,
+			Day:     v.day,
+			Price:   buyPx,
+			Equity:  equity,
+			Cash:    a.Balance,
+			Lot:     lot,
+			Returns: a.Returns,
+		}
+		if v.pos != nil {
+			ctx.Qty, ctx.Adds, ctx.Last = v.pos.Qty, v.pos.Adds, v.pos.LastBuy
+		}
+		var size int
+		if v.d.Weight > 0 && ctx.Qty == 0 {
+			//策略给出了目标仓位,按当前总资产折算数量
+			size = backtest.PercentEquity{Percent: v.d.Weight, FeeRate: cfg.FeeRate}.Size(ctx)
+		} else {
+			size = sizer.Size(ctx)
+		}
+		if lot > 0 {
+			size = size / lot * lot
+		}
+		if size <= 0 {
+			//已经持仓且不加仓,或者资金不足一手
+			continue
+		}
+		o := order(v, "buy", size, buyPx, v.d.ReasonOr("signal"))
+		amount := buyPx * float64(size)
+		f := cfg.Fee(v.rules, "buy", amount)
+		switch {
+		case !fill.CanBuy():
+			o.Status, o.Message = StatusRejected, fill.NoBuy
+		case a.Balance < amount+f:
+			o.Status, o.Message = StatusRejected, "现金不足"
+		default:
+			o.Amount, o.Fee = amount, f
+			a.Balance -= o.Amount + o.Fee
+			equity -= o.Fee
+			p := v.pos
+			if p == nil {
+				p = &Position{AccountID: a.ID, Code: v.info.Code, Name: v.info.Name}
+				v.pos = p
+			} else {
+				p.Adds++
+			}
+			p.Cost = (p.Cost*float64(p.Qty) + o.Amount) / float64(p.Qty+size)
+			p.Qty += size
+			p.LastBuy = buyPx
+			p.BuyTime = v.bar.Unix
+		}
+		orders = append(orders, o)
+	}
+
+	//按最新价估值
+	var positions []*Position
+	for _, v := range assets {
+		if v.pos != nil {
+			v.pos.mark(v.price)
+			positions = append(positions, v.pos)
+			delete(held, v.info.Code)
+		}
+	}
+	//没有读取到K线的持仓沿用之前的价格
+	for _, p := range held {
+		if !contains(sold, p) {
+			positions = append(positions, p)
+		}
+	}
+	a.LastBar = latest
+	a.fill(positions)
+	point := &EquityPoint{
+		AccountID: a.ID,
+		Time:      latest,
+		Cash:      a.Balance,
+		Value:     a.Value,
+		Equity:    a.Equity,
+		Return:    a.Return,
+	}
+
+	err = common.DB.SessionFunc(func(session *xorm.Session) error {
+		for _, o := range orders {
+			if _, err := session.Insert(o); err != nil {
+				return err
+			}
+		}
+		for _, p := range sold {
+			if _, err := session.ID(p.ID).Delete(new(Position)); err != nil {
+				return err
+			}
+		}
+		for _, p := range positions {
+			var err error
+			if p.ID == 0 {
+				_, err = session.Insert(p)
+			} else {
+				_, err = session.ID(p.ID).AllCols().Update(p)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := session.Insert(point); err != nil {
+			return err
+		}
+		_, err := session.ID(a.ID).Cols("Balance", "Returns", "LastBar").Update(a)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Step{Time: latest, Orders: orders, Account: a}, nil
+}
+
+// corporateActions 处理上次执行之后到latest之间的除权除息,现金分红计入可用现金,送转股增加持仓
+func corporateActions(a *Account, ps []*Position, latest int64) []*Order {
+	if common.Data.Gbbq == nil {
+		return nil
+	}
+	from := data.DayStart(time.Unix(a.LastBar, 0))
+	var orders []*Order
+	for _, p := range ps {
+		xs := append(protocol.XRXDs(nil), common.Data.Gbbq.GetXRXDs(p.Code)...)
+		sort.Slice(xs, func(i, j int) bool { return xs[i].Time.Before(xs[j].Time) })
+		for _, x := range xs {
+			if t := data.DayStart(x.Time); t <= from || t > latest {
+				continue
+			}
+			if x.Fenhong <= 0 && x.Songzhuangu <= 0 {
+				continue
+			}
+			dividend, shares := p.xrxd(x)
+			a.Balance += dividend
+			orders = append(orders, &Order{
+				AccountID: a.ID,
+				Code:      p.Code,
+				Name:      p.Name,
+				Side:      "xrxd",
+				Qty:       shares,
+				Amount:    dividend,
+				Status:    StatusFilled,
+				Reason:    "xrxd",
+				Message:   fmt.Sprintf("每10股派%v元,送转%v股", x.Fenhong, x.Songzhuangu),
+				Time:      x.Time.Unix(),
+			})
+		}
+	}
+	return orders
+}
+
+func contains(ps []*Position, p *Position) bool {
+	for _, v := range ps {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
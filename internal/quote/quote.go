@@ -0,0 +1,99 @@
+package quote
+
+import (
+	"time"
+
+	"github.com/injoyai/tdx"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// MaxCodes 每次请求最多的股票数量
+const MaxCodes = 80
+
+// Source 行情数据源,可以替换成本地的模拟服务用于测试
+type Source interface {
+	GetQuote(codes ...string) (protocol.QuotesResp, error)
+}
+
+// PoolSource 使用tdx连接池获取行情,tdx.Manage也实现了连接池
+type PoolSource struct {
+	tdx.IPool
+}
+
+func (this PoolSource) GetQuote(codes ...string) (resp protocol.QuotesResp, err error) {
+	err = this.Do(func(c *tdx.Client) error {
+		//GetQuote会修改传入的代码,这里复制一份
+		resp, err = c.GetQuote(append([]string(nil), codes...)...)
+		return err
+	})
+	return
+}
+
+// DialSource 连接指定地址的行情服务,例本地的模拟服务
+func DialSource(address string, clients int) (Source, error) {
+	p, err := tdx.NewPool(func() (*tdx.Client, error) {
+		return tdx.Dial(address, tdx.WithRedial())
+	}, clients)
+	if err != nil {
+		return nil, err
+	}
+	return PoolSource{IPool: p}, nil
+}
+
+// Live 一只股票的实时行情
+type Live struct {
+	Code    string        `json:"code"`
+	Name    string        `json:"name"`
+	Price   float64       `json:"price"`  //最新价
+	Last    float64       `json:"last"`   //昨收
+	Open    float64       `json:"open"`   //今开
+	High    float64       `json:"high"`   //最高
+	Low     float64       `json:"low"`    //最低
+	Volume  int64         `json:"volume"` //成交量,手
+	Amount  float64       `json:"amount"` //成交额,元
+	Rate    float64       `json:"rate"`   //涨跌幅
+	Bar     *extend.Kline `json:"bar"`    //当天的实时日K线,时间和日线一致为15:00
+	Updated int64         `json:"updated"`
+}
+
+// newLive 用快照行情生成实时行情,还没有成交时返回nil
+func newLive(code string, q *protocol.Quote, now time.Time) *Live {
+	if q.K.Open <= 0 || q.K.Close <= 0 {
+		return nil
+	}
+	l := &Live{
+		Code:    code,
+		Price:   q.K.Close.Float64(),
+		Last:    q.K.Last.Float64(),
+		Open:    q.K.Open.Float64(),
+		High:    q.K.High.Float64(),
+		Low:     q.K.Low.Float64(),
+		Volume:  int64(q.TotalHand),
+		Amount:  q.Amount,
+		Updated: now.Unix(),
+	}
+	if l.Last > 0 {
+		l.Rate = l.Price/l.Last - 1
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), 15, 0, 0, 0, now.Location())
+	l.Bar = &extend.Kline{
+		Unix: t.Unix(),
+		Kline: &protocol.Kline{
+			Last:   q.K.Last,
+			Open:   q.K.Open,
+			High:   q.K.High,
+			Low:    q.K.Low,
+			Close:  q.K.Close,
+			Volume: l.Volume,
+			Amount: protocol.Price(q.Amount * 1000), //元转厘
+			Time:   t,
+		},
+	}
+	return l
+}
+
+// changed 行情是否有变化
+func (this *Live) changed(old *Live) bool {
+	return old == nil || old.Price != this.Price || old.Volume != this.Volume || old.Bar.Unix != this.Bar.Unix
+}
@@ -0,0 +1,221 @@
+package quote
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// DefaultInterval 默认的轮询间隔
+const DefaultInterval = time.Second * 3
+
+var _ data.Reader = (*Service)(nil)
+
+// Service 盘中轮询订阅股票的快照行情,在内存中维护当天的实时K线,
+// 读取日线时把实时K线追加到最后,用于/api/quote的接口和盘中提醒,选股和回测仍然读取数据库
+type Service struct {
+	data.Reader                                                 //历史K线
+	Source      Source                                          //行情数据源
+	Interval    time.Duration                                   //轮询间隔,默认3秒
+	Trading     func(t time.Time) bool                          //是否是交易时间,为空时不限制
+	Name        func(code string) string                        //股票名称,可选
+	Equity      func(code string, t time.Time) *protocol.Equity //股本,用于计算换手率和市值,可选
+
+	mu    sync.RWMutex
+	subs  map[string]struct{}
+	lives map[string]*Live
+	hooks []func(l *Live)
+	once  sync.Once
+}
+
+// NewService 新建行情服务,需要调用Start开始轮询
+func NewService(source Source, reader data.Reader) *Service {
+	return &Service{
+		Reader:   reader,
+		Source:   source,
+		Interval: DefaultInterval,
+		subs:     map[string]struct{}{},
+		lives:    map[string]*Live{},
+	}
+}
+
+// OnQuote 注册行情变化后执行的函数,在轮询协程中按顺序执行
+func (this *Service) OnQuote(f func(l *Live)) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.hooks = append(this.hooks, f)
+}
+
+// Subscribe 订阅股票
+func (this *Service) Subscribe(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		if code = strings.ToLower(strings.TrimSpace(code)); code != "" {
+			this.subs[code] = struct{}{}
+		}
+	}
+}
+
+// Unsubscribe 取消订阅,同时删除实时行情
+func (this *Service) Unsubscribe(codes ...string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		delete(this.subs, code)
+		delete(this.lives, code)
+	}
+}
+
+// Codes 订阅的股票
+func (this *Service) Codes() []string {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := make([]string, 0, len(this.subs))
+	for code := range this.subs {
+		ls = append(ls, code)
+	}
+	sort.Strings(ls)
+	return ls
+}
+
+// Get 股票的实时行情,没有时返回nil
+func (this *Service) Get(code string) *Live {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.lives[strings.ToLower(code)]
+}
+
+// List 全部的实时行情
+func (this *Service) List() []*Live {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	ls := make([]*Live, 0, len(this.lives))
+	for _, l := range this.lives {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Code < ls[j].Code })
+	return ls
+}
+
+// Start 开始轮询,只在交易时间请求行情
+func (this *Service) Start() {
+	this.once.Do(func() {
+		go func() {
+			interval := this.Interval
+			if interval <= 0 {
+				interval = DefaultInterval
+			}
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for now := range t.C {
+				if this.Trading != nil && !this.Trading(now) {
+					continue
+				}
+				_, err := this.Poll()
+				logs.PrintErr(err)
+			}
+		}()
+	})
+}
+
+// Poll 请求一次订阅股票的行情,更新实时K线,返回有变化的行情并执行注册的函数
+func (this *Service) Poll() ([]*Live, error) {
+	codes := this.Codes()
+	now := time.Now()
+	var changed []*Live
+	var err error
+	for i := 0; i < len(codes); i += MaxCodes {
+		batch := codes[i:min(i+MaxCodes, len(codes))]
+		resp, e := this.Source.GetQuote(batch...)
+		if e != nil {
+			err = e
+			continue
+		}
+		for _, q := range resp {
+			code := q.Exchange.String() + q.Code
+			l := newLive(code, q, now)
+			if l == nil {
+				continue
+			}
+			this.fill(l)
+			this.mu.Lock()
+			_, ok := this.subs[code]
+			if ok && l.changed(this.lives[code]) {
+				this.lives[code] = l
+				changed = append(changed, l)
+			}
+			this.mu.Unlock()
+		}
+	}
+
+	this.mu.RLock()
+	hooks := this.hooks
+	this.mu.RUnlock()
+	for _, l := range changed {
+		for _, f := range hooks {
+			f(l)
+		}
+	}
+	return changed, err
+}
+
+// fill 填充名称,换手率和股本
+func (this *Service) fill(l *Live) {
+	if this.Name != nil {
+		l.Name = this.Name(l.Code)
+	}
+	if this.Equity != nil {
+		if eq := this.Equity(l.Code, l.Bar.Time); eq != nil {
+			l.Bar.Turnover = eq.Turnover(l.Bar.Volume * 100)
+			l.Bar.FloatStock = eq.Float
+			l.Bar.TotalStock = eq.Total
+		}
+	}
+}
+
+// GetDayKlines 历史日线加上当天的实时K线,数据库中已经有当天的日线时不追加
+func (this *Service) GetDayKlines(code string, start, end time.Time) (extend.Klines, error) {
+	ks, err := this.Reader.GetDayKlines(code, start, end)
+	if err != nil {
+		return nil, err
+	}
+	l := this.Get(code)
+	//结束时间可能是当天盘中,例time.Now()
+	if l == nil || l.Bar.Unix <= start.Unix() || (l.Bar.Unix >= end.Unix() && !data.SameDay(l.Bar.Unix, end.Unix())) {
+		return ks, nil
+	}
+	if len(ks) > 0 && (ks[len(ks)-1].Unix >= l.Bar.Unix || data.SameDay(ks[len(ks)-1].Unix, l.Bar.Unix)) {
+		return ks, nil
+	}
+	//复制一份,调用方可能修改K线
+	bar := *l.Bar
+	k := *bar.Kline
+	bar.Kline = &k
+	return append(ks, &bar), nil
+}
+
+// GetDayKlinesAdjust 复权后的日线,包含当天的实时K线
+func (this *Service) GetDayKlinesAdjust(code string, start, end time.Time, adjust string) (extend.Klines, error) {
+	ks, err := this.GetDayKlines(code, start, end)
+	if err != nil || adjust == data.AdjustNone {
+		return ks, err
+	}
+	return this.Adjust(code, ks, adjust)
+}
+
+// GetKlines 日线包含当天的实时K线,其它周期读取历史数据
+func (this *Service) GetKlines(code, period string, start, end time.Time) (extend.Klines, error) {
+	switch period {
+	case extend.Day, "":
+		return this.GetDayKlines(code, start, end)
+	}
+	return this.Reader.GetKlines(code, period, start, end)
+}
@@ -0,0 +1,180 @@
+package quote
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// fakeSource 按代码返回固定的快照行情,记录每次请求的股票
+type fakeSource struct {
+	prices  map[string]float64 //最新价,0表示还没有成交
+	batches [][]string
+}
+
+func (this *fakeSource) GetQuote(codes ...string) (protocol.QuotesResp, error) {
+	this.batches = append(this.batches, codes)
+	var resp protocol.QuotesResp
+	for _, code := range codes {
+		price, ok := this.prices[code]
+		if !ok {
+			continue
+		}
+		exchange := protocol.ExchangeSZ
+		if strings.HasPrefix(code, "sh") {
+			exchange = protocol.ExchangeSH
+		}
+		p := protocol.Yuan(price)
+		resp = append(resp, &protocol.Quote{
+			Exchange:  exchange,
+			Code:      code[2:],
+			K:         protocol.K{Last: protocol.Yuan(10), Open: p, High: p, Low: p, Close: p},
+			TotalHand: 100,
+			Amount:    price * 100 * 100,
+		})
+	}
+	return resp, nil
+}
+
+// fakeReader 内存中的历史日线,和数据库一样按(start,end)过滤
+type fakeReader struct {
+	data.Reader
+	day map[string]extend.Klines
+}
+
+func (this *fakeReader) GetDayKlines(code string, start, end time.Time) (extend.Klines, error) {
+	var ks extend.Klines
+	for _, k := range this.day[code] {
+		if k.Unix > start.Unix() && k.Unix < end.Unix() {
+			ks = append(ks, k)
+		}
+	}
+	return ks, nil
+}
+
+// dayBar 距离今天offset天的15:00日线
+func dayBar(offset int, close float64) *extend.Kline {
+	now := time.Now()
+	t := time.Date(now.Year(), now.Month(), now.Day()+offset, 15, 0, 0, 0, now.Location())
+	return &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{Close: protocol.Yuan(close), Time: t}}
+}
+
+func TestServicePoll(t *testing.T) {
+	source := &fakeSource{prices: map[string]float64{}}
+	s := NewService(source, &fakeReader{})
+	for i := 0; i < MaxCodes+20; i++ {
+		code := fmt.Sprintf("sz%06d", i)
+		source.prices[code] = 10.5
+		s.Subscribe(code)
+	}
+	//还没有成交的不生成实时行情,没有订阅的忽略
+	source.prices["sz000000"] = 0
+	source.prices["sh600000"] = 10.5
+	var hooked []string
+	s.OnQuote(func(l *Live) { hooked = append(hooked, l.Code) })
+
+	changed, err := s.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(source.batches) != 2 || len(source.batches[0]) != MaxCodes || len(source.batches[1]) != 20 {
+		t.Fatalf("请求批次 %d", len(source.batches))
+	}
+	if len(changed) != MaxCodes+19 || len(hooked) != MaxCodes+19 {
+		t.Fatalf("变化的行情 %d, 执行函数 %d", len(changed), len(hooked))
+	}
+	if s.Get("sz000000") != nil || s.Get("sh600000") != nil {
+		t.Error("没有成交或没有订阅的股票不应该有实时行情")
+	}
+	l := s.Get("SZ000001")
+	if l == nil || l.Price != 10.5 || l.Last != 10 || !near(l.Rate, 0.05) || l.Volume != 100 || l.Bar.Close != protocol.Yuan(10.5) {
+		t.Fatalf("实时行情 %+v", l)
+	}
+	if l.Bar.Amount != protocol.Yuan(l.Amount) || l.Bar.Time.Hour() != 15 {
+		t.Errorf("实时K线 %+v", l.Bar.Kline)
+	}
+
+	//行情没有变化时不返回也不执行
+	hooked = nil
+	if changed, _ = s.Poll(); len(changed) != 0 || len(hooked) != 0 {
+		t.Errorf("没有变化时返回了 %d 条行情", len(changed))
+	}
+	source.prices["sz000001"] = 10.6
+	if changed, _ = s.Poll(); len(changed) != 1 || changed[0].Code != "sz000001" || len(hooked) != 1 {
+		t.Errorf("变化的行情 %d, 执行函数 %v", len(changed), hooked)
+	}
+
+	//取消订阅后删除实时行情,不再请求
+	s.Unsubscribe("sz000001")
+	source.batches = nil
+	s.Poll()
+	if s.Get("sz000001") != nil || len(source.batches[0]) != MaxCodes {
+		t.Error("取消订阅后还在请求")
+	}
+}
+
+func TestServiceGetDayKlines(t *testing.T) {
+	code := "sz000001"
+	reader := &fakeReader{day: map[string]extend.Klines{code: {dayBar(-3, 10), dayBar(-2, 10.2), dayBar(-1, 10)}}}
+	s := NewService(&fakeSource{prices: map[string]float64{code: 10.5}}, reader)
+	s.Subscribe(code)
+	if _, err := s.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	start := now.AddDate(0, 0, -10)
+
+	//追加当天的实时K线,每次读取只追加一根,且不影响保存的实时行情
+	for i := 0; i < 2; i++ {
+		ks, err := s.GetDayKlines(code, start, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ks) != 4 || ks[3].Unix != dayBar(0, 0).Unix || ks[3].Close != protocol.Yuan(10.5) {
+			t.Fatalf("第%d次读取 %d 根K线", i+1, len(ks))
+		}
+		ks[3].Close = 0
+	}
+
+	for _, c := range []struct {
+		name       string
+		start, end time.Time
+		want       int
+	}{
+		{"结束时间在当天之前", start, time.Unix(dayBar(-1, 0).Unix, 0), 2},
+		{"开始时间在实时K线之后", time.Unix(dayBar(0, 0).Unix, 0), now.AddDate(0, 0, 1), 0},
+		{"结束时间在之后的日期", start, now.AddDate(0, 0, 1), 4},
+	} {
+		ks, err := s.GetDayKlines(code, c.start, c.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ks) != c.want {
+			t.Errorf("[%s] %d 根K线, 期望 %d", c.name, len(ks), c.want)
+		}
+	}
+
+	//数据库已经有当天的日线时不重复追加
+	reader.day[code] = append(reader.day[code], dayBar(0, 10.4))
+	ks, err := s.GetDayKlines(code, start, now.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 4 || ks[3].Close != protocol.Yuan(10.4) {
+		t.Errorf("数据库有当天日线时 %d 根K线, 最后收盘价 %v", len(ks), ks[len(ks)-1].Close)
+	}
+
+	//没有订阅的股票只返回历史日线
+	if ks, _ = s.GetDayKlines("sz000002", start, now); len(ks) != 0 {
+		t.Errorf("没有订阅的股票 %d 根K线", len(ks))
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-6 && b-a < 1e-6
+}
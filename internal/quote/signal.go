@@ -0,0 +1,55 @@
+package quote
+
+import (
+	"time"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+// history 策略计算读取的日线天数(自然日)
+const history = 400
+
+// Signal 用实时K线执行策略的结果
+type Signal struct {
+	*Live
+	Action  strategy.Action `json:"action"` //1:买入 0:观望 -1:卖出
+	Weight  float64         `json:"weight"`
+	Reason  string          `json:"reason"`
+	Score   float64         `json:"score"`
+	Explain string          `json:"explain"`
+}
+
+// Evaluate 对有实时行情的股票执行策略,日线使用前复权,最后一根为当天的实时K线
+func (this *Service) Evaluate(s strategy.Interface, codes ...string) ([]*Signal, error) {
+	if len(codes) == 0 {
+		codes = this.Codes()
+	}
+	now := time.Now()
+	var out []*Signal
+	for _, code := range codes {
+		l := this.Get(code)
+		if l == nil {
+			continue
+		}
+		day, err := this.GetDayKlinesAdjust(l.Code, now.AddDate(0, 0, -history), now, data.AdjustQFQ)
+		if err != nil {
+			return nil, err
+		}
+		if len(day) == 0 {
+			continue
+		}
+		info := data.NewInfo(l.Code, l.Name, day[len(day)-1])
+		d := strategy.Decide(s, info, day, nil)
+		score, explain := strategy.Score(s, info, day, nil)
+		out = append(out, &Signal{
+			Live:    l,
+			Action:  d.Action,
+			Weight:  d.Weight,
+			Reason:  d.Reason,
+			Score:   score,
+			Explain: explain,
+		})
+	}
+	return out, nil
+}
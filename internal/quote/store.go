@@ -0,0 +1,141 @@
+package quote
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/injoyai/conv/cfg"
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+)
+
+var (
+	address  = cfg.GetString("quote.address", "") //行情服务地址,为空时使用数据更新的连接池,例本地模拟服务127.0.0.1:7709
+	interval = cfg.GetInt("quote.interval", 3)    //轮询间隔,秒
+)
+
+// Default 全局的行情服务,Start之后可用
+var Default *Service
+
+// Subscription 订阅的股票,重启后继续轮询
+type Subscription struct {
+	ID      int64  `xorm:"pk autoincr" json:"id"`
+	Code    string `xorm:"unique" json:"code"`
+	Created int64  `xorm:"created" json:"created"`
+}
+
+var syncOnce struct {
+	sync.Once
+	err error
+}
+
+func table() error {
+	syncOnce.Do(func() {
+		syncOnce.err = common.DB.Sync2(new(Subscription))
+	})
+	return syncOnce.err
+}
+
+// Start 启动行情服务,加载订阅的股票,只在交易日的交易时间轮询
+func Start() error {
+	var source Source = PoolSource{IPool: common.Data.Manage}
+	if address != "" {
+		var err error
+		source, err = DialSource(address, 1)
+		if err != nil {
+			return err
+		}
+	}
+	s := NewService(source, common.Klines)
+	s.Interval = time.Duration(interval) * time.Second
+	s.Trading = func(t time.Time) bool {
+		if common.Data.Workday != nil && !common.Data.Workday.Is(t) {
+			return false
+		}
+		return Trading(t)
+	}
+	s.Name = common.Data.Codes.GetName
+	if common.Data.Gbbq != nil {
+		s.Equity = common.Data.Gbbq.GetEquity
+	}
+	ls, err := ListSubscription()
+	if err != nil {
+		return err
+	}
+	for _, v := range ls {
+		s.Subscribe(v.Code)
+	}
+	Default = s
+	s.Start()
+	return nil
+}
+
+// Trading 是否是交易时间,包含集合竞价
+func Trading(t time.Time) bool {
+	hm := t.Hour()*100 + t.Minute()
+	return (hm >= 915 && hm <= 1130) || (hm >= 1300 && hm <= 1500)
+}
+
+// Subscribe 订阅股票并保存
+func Subscribe(codes ...string) error {
+	if err := table(); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			return errors.New("股票代码不能为空")
+		}
+		has, err := common.DB.Where("Code=?", code).Exist(new(Subscription))
+		if err != nil {
+			return err
+		}
+		if !has {
+			if _, err = common.DB.Insert(&Subscription{Code: code}); err != nil {
+				return err
+			}
+		}
+		if Default != nil {
+			Default.Subscribe(code)
+		}
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func Unsubscribe(codes ...string) error {
+	if err := table(); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if _, err := common.DB.Where("Code=?", code).Delete(new(Subscription)); err != nil {
+			return err
+		}
+		if Default != nil {
+			Default.Unsubscribe(code)
+		}
+	}
+	return nil
+}
+
+// ListSubscription 订阅的股票
+func ListSubscription() ([]*Subscription, error) {
+	if err := table(); err != nil {
+		return nil, err
+	}
+	ls := []*Subscription(nil)
+	err := common.DB.Asc("Code").Find(&ls)
+	return ls, err
+}
+
+// OnQuote 行情变化后执行,行情服务未启动时不执行
+func OnQuote(f func(l *Live)) {
+	if Default == nil {
+		logs.Err("实时行情服务未启动")
+		return
+	}
+	Default.OnQuote(f)
+}
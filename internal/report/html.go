@@ -0,0 +1,113 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/injoyai/strategy/internal/backtest"
+)
+
+//go:embed report.html
+var htmlTemplate string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"num": func(v float64) string {
+		if v >= backtest.InfProfitFactor {
+			return "∞"
+		}
+		return fmt.Sprintf("%.2f", v)
+	},
+	"date": func(t int64) string { return time.Unix(t, 0).Format(time.DateOnly) },
+	"time": func(t int64) string { return time.Unix(t, 0).Format(time.DateTime) },
+	"join": strings.Join,
+}).Parse(htmlTemplate))
+
+// Chart 内联的SVG折线图
+type Chart struct {
+	Lines      []Line
+	Min, Max   string //纵轴范围
+	Start, End string //横轴范围
+}
+
+// Line 折线
+type Line struct {
+	Name   string
+	Color  string
+	Points string //SVG polyline的points
+	Fill   bool   //填充到0轴
+}
+
+const (
+	chartWidth  = 1000
+	chartHeight = 260
+)
+
+// HTML 导出独立的HTML报告,图表使用内联SVG,不依赖任何外部资源
+func (this *Report) HTML(w io.Writer) error {
+	equity := []Line{{Name: "策略", Color: "#1677ff"}}
+	series := [][]float64{this.Equity}
+	if this.Benchmark != nil && len(this.Benchmark.Equity) > 0 {
+		equity = append(equity, Line{Name: "基准 " + this.Benchmark.Code, Color: "#999999"})
+		series = append(series, this.Benchmark.Equity)
+	}
+	return tmpl.Execute(w, map[string]any{
+		"R":        this,
+		"Equity":   this.chart(equity, series, false),
+		"Drawdown": this.chart([]Line{{Name: "回撤", Color: "#cf1322", Fill: true}}, [][]float64{this.Drawdown}, true),
+	})
+}
+
+// chart 按全部折线的最大最小值缩放到同一个坐标系
+func (this *Report) chart(lines []Line, series [][]float64, percent bool) Chart {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, v := range s {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if percent {
+		hi = math.Max(hi, 0)
+	}
+	c := Chart{}
+	if math.IsInf(lo, 0) {
+		return c
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	format := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	if percent {
+		format = func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) }
+	}
+	c.Min, c.Max = format(lo), format(hi)
+	if len(this.Times) > 0 {
+		c.Start = time.Unix(this.Times[0], 0).Format(time.DateOnly)
+		c.End = time.Unix(this.Times[len(this.Times)-1], 0).Format(time.DateOnly)
+	}
+	y := func(v float64) float64 { return chartHeight - (v-lo)/(hi-lo)*chartHeight }
+	for i, s := range series {
+		if len(s) == 0 {
+			continue
+		}
+		b := strings.Builder{}
+		step := float64(chartWidth) / math.Max(float64(len(s)-1), 1)
+		if lines[i].Fill {
+			fmt.Fprintf(&b, "0,%.1f ", y(0))
+		}
+		for j, v := range s {
+			fmt.Fprintf(&b, "%.1f,%.1f ", float64(j)*step, y(v))
+		}
+		if lines[i].Fill {
+			fmt.Fprintf(&b, "%.1f,%.1f", float64(len(s)-1)*step, y(0))
+		}
+		lines[i].Points = strings.TrimSpace(b.String())
+		c.Lines = append(c.Lines, lines[i])
+	}
+	return c
+}
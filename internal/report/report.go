@@ -0,0 +1,153 @@
+package report
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/injoyai/conv"
+	"github.com/injoyai/strategy/internal/backtest"
+	"github.com/injoyai/strategy/internal/strategy"
+)
+
+// Report 回测报告的数据,由回测记录生成
+type Report struct {
+	Title      string               `json:"title"`
+	Strategy   string               `json:"strategy"` //策略表达式
+	Codes      []string             `json:"codes"`
+	Start      string               `json:"start"`
+	End        string               `json:"end"`
+	Cash       float64              `json:"cash"`
+	Created    int64                `json:"created"`  //报告生成时间
+	Times      []int64              `json:"times"`    //资金曲线的时间
+	Equity     []float64            `json:"equity"`   //资金曲线
+	Drawdown   []float64            `json:"drawdown"` //回撤,总资产相对之前峰值的下跌比例
+	Signals    []int                `json:"signals"`  //策略信号,与Times对齐,组合回测为空
+	Metrics    backtest.Metrics     `json:"metrics"`
+	Benchmark  *backtest.Benchmark  `json:"benchmark,omitempty"`
+	Trades     []backtest.Trade     `json:"trades"`
+	RoundTrips []backtest.RoundTrip `json:"round_trips"`
+	Sources    []strategy.Version   `json:"sources"` //策略及脚本源码
+}
+
+// FromRecord 由保存的回测记录生成报告
+func FromRecord(rec *backtest.Record) *Report {
+	r := &Report{
+		Title:      fmt.Sprintf("回测报告 #%d", rec.ID),
+		Strategy:   rec.Name,
+		Codes:      rec.Codes,
+		Start:      rec.Start,
+		End:        rec.End,
+		Cash:       rec.Cash,
+		Created:    time.Now().Unix(),
+		Times:      rec.Times,
+		Equity:     rec.Equity,
+		Signals:    rec.Signals,
+		Metrics:    rec.Metrics,
+		Benchmark:  rec.Benchmark,
+		Trades:     rec.Trades,
+		RoundTrips: rec.RoundTrips,
+		Sources:    rec.Versions,
+	}
+	r.fill()
+	return r
+}
+
+// fill 填充默认标题,回撤和日期范围
+func (this *Report) fill() {
+	if this.Title == "" {
+		this.Title = "回测报告"
+	}
+	if len(this.Times) > 0 {
+		if this.Start == "" {
+			this.Start = time.Unix(this.Times[0], 0).Format(time.DateOnly)
+		}
+		if this.End == "" {
+			this.End = time.Unix(this.Times[len(this.Times)-1], 0).Format(time.DateOnly)
+		}
+	}
+	peak := this.Cash
+	this.Drawdown = make([]float64, len(this.Equity))
+	for i, v := range this.Equity {
+		peak = max(peak, v)
+		if peak > 0 {
+			this.Drawdown[i] = v/peak - 1
+		}
+	}
+}
+
+// JSON 导出资金曲线,交易和信号等全部数据
+func (this *Report) JSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(this)
+}
+
+// CSV 导出zip压缩包,包含equity.csv,trades.csv,round_trips.csv,signals.csv
+func (this *Report) CSV(w io.Writer) error {
+	z := zip.NewWriter(w)
+	date := func(t int64) string { return time.Unix(t, 0).Format(time.DateTime) }
+	f := func(v float64) string { return conv.String(v) }
+
+	files := []struct {
+		name string
+		rows [][]string
+	}{
+		{name: "equity.csv", rows: func() [][]string {
+			rows := [][]string{{"time", "equity", "drawdown", "benchmark"}}
+			for i, t := range this.Times {
+				row := []string{date(t), "", "", ""}
+				if i < len(this.Equity) {
+					row[1], row[2] = f(this.Equity[i]), f(this.Drawdown[i])
+				}
+				if this.Benchmark != nil && i < len(this.Benchmark.Equity) {
+					row[3] = f(this.Benchmark.Equity[i])
+				}
+				rows = append(rows, row)
+			}
+			return rows
+		}()},
+		{name: "trades.csv", rows: func() [][]string {
+			rows := [][]string{{"time", "code", "side", "price", "qty", "fee", "reason"}}
+			for _, t := range this.Trades {
+				rows = append(rows, []string{date(t.Time), t.Code, t.Side, f(t.Price), conv.String(t.Qty), f(t.Fee), t.Reason})
+			}
+			return rows
+		}()},
+		{name: "round_trips.csv", rows: func() [][]string {
+			rows := [][]string{{"code", "entry_time", "entry_price", "exit_time", "exit_price", "qty", "fee", "pnl", "return", "bars", "reason"}}
+			for _, t := range this.RoundTrips {
+				rows = append(rows, []string{t.Code, date(t.EntryTime), f(t.EntryPrice), date(t.ExitTime), f(t.ExitPrice), conv.String(t.Qty), f(t.Fee), f(t.PnL), f(t.Return), conv.String(t.Bars), t.Reason})
+			}
+			return rows
+		}()},
+		{name: "signals.csv", rows: func() [][]string {
+			rows := [][]string{{"time", "signal"}}
+			for i, s := range this.Signals {
+				if i < len(this.Times) {
+					rows = append(rows, []string{date(this.Times[i]), conv.String(s)})
+				}
+			}
+			return rows
+		}()},
+	}
+
+	for _, file := range files {
+		fw, err := z.Create(file.name)
+		if err != nil {
+			return err
+		}
+		//BOM,Excel打开中文不乱码
+		if _, err = fw.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err = cw.WriteAll(file.rows); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
@@ -0,0 +1,254 @@
+package screener
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+)
+
+// DefaultHorizons 默认统计的持有天数
+var DefaultHorizons = []int{1, 5, 10, 20}
+
+// HistoryRequest 历史选股请求,在[From,To]之间的每个交易日,只用当天及之前的数据选股,
+// 并统计选中的股票之后N个交易日的收益,用于判断选股策略是否真的有效
+type HistoryRequest struct {
+	Request
+	From     int64 `json:"from"`     // 选股开始日期(秒级时间戳)
+	To       int64 `json:"to"`       // 选股结束日期(秒级时间戳),为空时只在From当天选股
+	Horizons []int `json:"horizons"` // 持有交易日数量,默认1,5,10,20
+}
+
+// Pick 某一天选中的股票
+type Pick struct {
+	Code    string          `json:"code"`
+	Name    string          `json:"name"`
+	Price   float64         `json:"price"`   // 当天收盘价,实际价格
+	Score   float64         `json:"score"`   // 评分
+	Signal  int             `json:"signal"`  // 信号类型 1:买入 -1:卖出
+	Reason  string          `json:"reason"`  // 信号原因
+	Returns map[int]float64 `json:"returns"` // 持有天数->收益率,按当天收盘价买入,之后第N个交易日收盘价卖出,使用后复权价格,数据不足时没有
+}
+
+// HistoryDay 某一天的选股结果
+type HistoryDay struct {
+	Date  int64  `json:"date"`
+	Picks []Pick `json:"picks"`
+}
+
+// HitRate 某个持有天数的统计
+type HitRate struct {
+	Horizon   int     `json:"horizon"`    // 持有交易日数量
+	Count     int     `json:"count"`      // 有收益数据的选股数量
+	HitRate   float64 `json:"hit_rate"`   // 命中率,买入信号收益大于0,卖出信号收益小于0
+	AvgReturn float64 `json:"avg_return"` // 平均收益率
+	Median    float64 `json:"median"`     // 收益率中位数
+	Benchmark float64 `json:"benchmark"`  // 选股当天全部股票的平均收益率,按选股数量加权
+	Excess    float64 `json:"excess"`     // 平均超额收益率,平均收益率-基准收益率
+}
+
+// HistoryResult 历史选股结果
+type HistoryResult struct {
+	Days []HistoryDay `json:"days"` // 每天的选股,没有选中股票的日期不返回
+	Buy  []HitRate    `json:"buy"`  // 买入信号的统计
+	Sell []HitRate    `json:"sell"` // 卖出信号的统计
+}
+
+// RunHistory 历史选股
+func RunHistory(req HistoryRequest) (*HistoryResult, error) {
+	if req.From == 0 {
+		return nil, errors.New("选股开始日期不能为空")
+	}
+	if req.To == 0 {
+		req.To = req.From
+	}
+	if req.To < req.From {
+		return nil, errors.New("选股结束日期不能早于开始日期")
+	}
+	if err := data.CheckAdjust(req.Adjust); err != nil {
+		return nil, err
+	}
+	if len(req.Horizons) == 0 {
+		req.Horizons = DefaultHorizons
+	}
+	for _, h := range req.Horizons {
+		if h <= 0 {
+			return nil, errors.New("持有天数需要大于0")
+		}
+	}
+
+	strat, err := strategy.Build(req.Tree, req.Strategies, req.Weights, req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	from := data.DayStart(time.Unix(req.From, 0))
+	to := data.DayStart(time.Unix(req.To, 0)) + 24*60*60
+
+	type benchmark struct {
+		sum   float64
+		count int
+	}
+	mu := sync.Mutex{}
+	picks := map[int64][]Pick{}
+	bench := map[int64]map[int]*benchmark{} //日期->持有天数->全部股票的收益
+
+	//需要之后的数据计算收益,结束时间使用当前时间
+	err = common.Klines.RangeKlinesAdjust(
+		100,
+		time.Unix(req.StartTime, 0),
+		time.Now().AddDate(0, 0, 1),
+		data.AdjustNone,
+		func(info extend.Info, raw, min extend.Klines) {
+			//选股使用请求的复权方式,基本信息使用实际价格
+			day, err := common.Klines.Adjust(info.Code, raw, req.Adjust)
+			if err != nil {
+				logs.Err(err)
+				return
+			}
+			//收益使用后复权,跨越除权除息日时不会出现虚假的涨跌
+			hfq := day
+			if req.Adjust != data.AdjustHFQ {
+				if hfq, err = common.Klines.Adjust(info.Code, raw, data.AdjustHFQ); err != nil {
+					logs.Err(err)
+					return
+				}
+			}
+			st := strategy.NewStream(strat, info, nil)
+			var ps []Pick
+			var pickDate []int64
+			var date []int64
+			var rs []map[int]float64
+			for i, k := range day {
+				d := st.OnBar(k)
+				t := data.DayStart(k.Time)
+				if t >= to {
+					break
+				}
+				if t < from {
+					continue
+				}
+				returns := forwardReturns(hfq, i, req.Horizons)
+				date = append(date, t)
+				rs = append(rs, returns)
+				if d.Action == strategy.Hold {
+					continue
+				}
+				pit := data.NewInfo(info.Code, info.Name, raw[i])
+				score, _ := strategy.Score(strat, pit, day[:i+1], nil)
+				if (req.MinScore != nil && score < *req.MinScore) ||
+					(req.MaxScore != nil && score > *req.MaxScore) {
+					continue
+				}
+				ps = append(ps, Pick{
+					Code:    info.Code,
+					Name:    info.Name,
+					Price:   raw[i].Close.Float64(),
+					Score:   score,
+					Signal:  int(d.Action),
+					Reason:  d.Reason,
+					Returns: returns,
+				})
+				pickDate = append(pickDate, t)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i, p := range ps {
+				picks[pickDate[i]] = append(picks[pickDate[i]], p)
+			}
+			for i, t := range date {
+				if bench[t] == nil {
+					bench[t] = map[int]*benchmark{}
+				}
+				for h, r := range rs[i] {
+					if bench[t][h] == nil {
+						bench[t][h] = &benchmark{}
+					}
+					bench[t][h].sum += r
+					bench[t][h].count++
+				}
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	//按日期排序,每天按评分取前N个
+	result := &HistoryResult{}
+	for t, ps := range picks {
+		sort.Slice(ps, func(i, j int) bool {
+			if ps[i].Score != ps[j].Score {
+				return ps[i].Score > ps[j].Score
+			}
+			return ps[i].Code < ps[j].Code
+		})
+		if req.Limit > 0 && len(ps) > req.Limit {
+			ps = ps[:req.Limit]
+		}
+		result.Days = append(result.Days, HistoryDay{Date: t, Picks: ps})
+	}
+	sort.Slice(result.Days, func(i, j int) bool { return result.Days[i].Date < result.Days[j].Date })
+
+	//统计
+	for _, h := range req.Horizons {
+		for _, signal := range []strategy.Action{strategy.Buy, strategy.Sell} {
+			s := HitRate{Horizon: h}
+			var rets []float64
+			var base float64
+			hits := 0
+			for _, day := range result.Days {
+				for _, p := range day.Picks {
+					r, ok := p.Returns[h]
+					if !ok || p.Signal != int(signal) {
+						continue
+					}
+					rets = append(rets, r)
+					if (signal == strategy.Buy && r > 0) || (signal == strategy.Sell && r < 0) {
+						hits++
+					}
+					if b := bench[day.Date][h]; b != nil && b.count > 0 {
+						base += b.sum / float64(b.count)
+					}
+				}
+			}
+			if s.Count = len(rets); s.Count > 0 {
+				s.HitRate = float64(hits) / float64(s.Count)
+				s.AvgReturn = indicator.Mean(rets)
+				s.Median = indicator.Median(rets)
+				s.Benchmark = base / float64(s.Count)
+				s.Excess = s.AvgReturn - s.Benchmark
+			}
+			if signal == strategy.Buy {
+				result.Buy = append(result.Buy, s)
+			} else {
+				result.Sell = append(result.Sell, s)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// forwardReturns 第i根K线收盘买入,持有h根K线后收盘卖出的收益率,day需要是后复权的K线
+func forwardReturns(day extend.Klines, i int, horizons []int) map[int]float64 {
+	out := map[int]float64{}
+	entry := day[i].Close.Float64()
+	if entry <= 0 {
+		return out
+	}
+	for _, h := range horizons {
+		if i+h < len(day) {
+			out[h] = day[i+h].Close.Float64()/entry - 1
+		}
+	}
+	return out
+}
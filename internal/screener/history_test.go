@@ -0,0 +1,92 @@
+package screener
+
+import (
+	"testing"
+	"time"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// always 每根K线都买入的策略
+type always struct{}
+
+func (always) Name() string { return "测试历史选股" }
+
+func (always) Type() string { return strategy.DayKline }
+
+func (always) Signal(info extend.Info, day, min extend.Klines) bool { return true }
+
+// fakeReader 只有一只股票,后复权时除权日及之后的价格乘以factor
+type fakeReader struct {
+	data.Reader
+	raw    extend.Klines
+	exDate int
+	factor float64
+}
+
+func (this *fakeReader) RangeKlinesAdjust(limit int, start, end time.Time, adjust string, f data.Handler) error {
+	f(extend.Info{Code: "sz000001", Name: "测试"}, this.raw, nil)
+	return nil
+}
+
+func (this *fakeReader) Adjust(code string, ks extend.Klines, adjust string) (extend.Klines, error) {
+	if adjust != data.AdjustHFQ {
+		return ks, nil
+	}
+	out := make(extend.Klines, len(ks))
+	for i, k := range ks {
+		kk := *k.Kline
+		if i >= this.exDate {
+			kk.Close = protocol.Price(float64(kk.Close) * this.factor)
+		}
+		out[i] = &extend.Kline{Unix: k.Unix, Kline: &kk}
+	}
+	return out, nil
+}
+
+func historyBar(day int, close float64) *extend.Kline {
+	t := time.Date(2024, 1, day, 15, 0, 0, 0, time.Local)
+	return &extend.Kline{Unix: t.Unix(), Kline: &protocol.Kline{Close: protocol.Yuan(close), Time: t}}
+}
+
+func TestRunHistoryReturns(t *testing.T) {
+	strategy.Register(always{})
+	old := common.Klines
+	defer func() { common.Klines = old }()
+	//第3天10送10,实际价格减半,后复权价格不变
+	common.Klines = &fakeReader{
+		raw:    extend.Klines{historyBar(2, 10), historyBar(3, 10), historyBar(4, 5), historyBar(5, 5.5)},
+		exDate: 2,
+		factor: 2,
+	}
+
+	for _, adjust := range []string{data.AdjustNone, data.AdjustQFQ, data.AdjustHFQ} {
+		res, err := RunHistory(HistoryRequest{
+			Request:  Request{Strategies: []string{"测试历史选股"}, Adjust: adjust},
+			From:     historyBar(2, 0).Unix,
+			To:       historyBar(3, 0).Unix,
+			Horizons: []int{1, 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Days) != 2 {
+			t.Fatalf("[%s] 选股 %d 天", adjust, len(res.Days))
+		}
+		for i, want := range []map[int]float64{{1: 0, 2: 0}, {1: 0, 2: 0.1}} {
+			p := res.Days[i].Picks[0]
+			if p.Price != []float64{10, 10}[i] {
+				t.Errorf("[%s] 第%d天价格 %v", adjust, i+1, p.Price)
+			}
+			for h, r := range want {
+				if got, ok := p.Returns[h]; !ok || got-r > 1e-9 || r-got > 1e-9 {
+					t.Errorf("[%s] 第%d天持有%d天收益 %v, 期望 %v", adjust, i+1, h, got, r)
+				}
+			}
+		}
+	}
+}
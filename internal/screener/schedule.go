@@ -0,0 +1,379 @@
+package screener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/strategy/internal/notify"
+	"github.com/injoyai/strategy/internal/strategy"
+	"github.com/robfig/cron/v3"
+)
+
+// Source 定时选股在推送日志中的来源
+const Source = "screen"
+
+const (
+	TriggerCron   = "cron"   //按cron表达式执行
+	TriggerUpdate = "update" //数据更新完成后执行
+	TriggerManual = "manual" //手动执行
+)
+
+// Schedule 保存的定时选股,Cron为空时在每天数据更新完成后执行
+type Schedule struct {
+	ID      int64        `xorm:"pk autoincr" json:"id"`
+	Name    string       `json:"name"`
+	Request Request      `xorm:"json" json:"request"` //选股条件,包含策略,参数,评分范围和数量
+	Signal  int          `json:"signal"`              //只推送的信号 1:买入 -1:卖出 0:全部
+	Cron    string       `json:"cron"`                //cron表达式(带秒),例"0 30 15 * * 1-5",为空时在数据更新完成后执行,到点时当天数据还未更新则推迟到更新完成后
+	Sinks   notify.Sinks `xorm:"text" json:"sinks"`   //推送目标
+	Enable  bool         `json:"enable"`
+	LastRun int64        `json:"last_run"` //最后执行时间
+	Created int64        `xorm:"created" json:"created"`
+	Updated int64        `xorm:"updated" json:"updated"`
+}
+
+// Check 检查配置,策略需要已经注册
+func (this *Schedule) Check() error {
+	if this.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if this.Request.Tree == nil && len(this.Request.Strategies) == 0 {
+		return errors.New("策略不能为空")
+	}
+	if this.Signal < -1 || this.Signal > 1 {
+		return errors.New("信号只能是1,-1或0")
+	}
+	if err := data.CheckAdjust(this.Request.Adjust); err != nil {
+		return err
+	}
+	if _, err := strategy.Build(this.Request.Tree, this.Request.Strategies, this.Request.Weights, this.Request.Params); err != nil {
+		return err
+	}
+	if this.Cron != "" {
+		if _, err := cronParser.Parse(this.Cron); err != nil {
+			return fmt.Errorf("cron表达式[%s]错误: %v", this.Cron, err)
+		}
+	}
+	for _, sink := range this.Sinks {
+		if err := sink.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScheduleRun 定时选股的一次执行记录,Picks为推送的选股结果
+type ScheduleRun struct {
+	ID         int64              `xorm:"pk autoincr" json:"id"`
+	ScheduleID int64              `json:"schedule_id"`
+	Name       string             `json:"name"`
+	Trigger    string             `json:"trigger"` //cron/update/manual
+	Picks      []Pick             `xorm:"json" json:"picks"`
+	Count      int                `json:"count"` //选中的股票数量
+	Error      string             `json:"error"`
+	Elapsed    int64              `json:"elapsed"` //选股耗时,毫秒
+	Created    int64              `xorm:"created" json:"created"`
+	Deliveries []*notify.Delivery `xorm:"-" json:"deliveries,omitempty"` //推送结果
+}
+
+// Payload 推送的数据
+type Payload struct {
+	ScheduleID int64  `json:"schedule_id"`
+	RunID      int64  `json:"run_id"`
+	Name       string `json:"name"`
+	Trigger    string `json:"trigger"`
+	Time       int64  `json:"time"`
+	Picks      []Pick `json:"picks"`
+}
+
+// Payload 推送的数据
+func (this *ScheduleRun) Payload() Payload {
+	return Payload{
+		ScheduleID: this.ScheduleID,
+		RunID:      this.ID,
+		Name:       this.Name,
+		Trigger:    this.Trigger,
+		Time:       this.Created,
+		Picks:      this.Picks,
+	}
+}
+
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var scheduleOnce struct {
+	sync.Once
+	err error
+}
+
+func scheduleTable() error {
+	scheduleOnce.Do(func() {
+		scheduleOnce.err = common.DB.Sync2(new(Schedule), new(ScheduleRun))
+	})
+	return scheduleOnce.err
+}
+
+// scheduler 定时选股的cron,全市场选股比较耗资源,同时只执行一个
+var scheduler = struct {
+	sync.Mutex
+	run     sync.Mutex
+	cron    *cron.Cron
+	entries map[int64]cron.EntryID
+	pending map[int64]struct{} //到点时当天数据还未更新,等数据更新完成后执行
+}{
+	cron:    cron.New(cron.WithParser(cronParser)),
+	entries: map[int64]cron.EntryID{},
+	pending: map[int64]struct{}{},
+}
+
+// StartSchedule 加载启用的定时选股并开始计时,需要在数据开始更新之前调用
+func StartSchedule() error {
+	ls, err := ListSchedule()
+	if err != nil {
+		return err
+	}
+	for _, v := range ls {
+		register(v)
+	}
+	common.Data.OnUpdated(RunAfterUpdate)
+	scheduler.cron.Start()
+	return nil
+}
+
+// register 按最新配置注册cron,未启用或没有cron表达式时只移除
+func register(s *Schedule) {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+	if id, ok := scheduler.entries[s.ID]; ok {
+		scheduler.cron.Remove(id)
+		delete(scheduler.entries, s.ID)
+	}
+	if !s.Enable || s.Cron == "" {
+		return
+	}
+	id := s.ID
+	entry, err := scheduler.cron.AddFunc(s.Cron, func() {
+		//数据还未更新时使用的是上一个交易日的K线,推迟到数据更新完成后执行
+		if updated, err := common.Data.Updated.Updated(data.Kline); err == nil && !updated {
+			scheduler.Lock()
+			scheduler.pending[id] = struct{}{}
+			scheduler.Unlock()
+			logs.Infof("定时选股[%d]等待数据更新完成后执行\n", id)
+			return
+		}
+		_, err := RunSchedule(id, TriggerCron)
+		logs.PrintErr(err)
+	})
+	if err != nil {
+		logs.Err(err)
+		return
+	}
+	scheduler.entries[s.ID] = entry
+}
+
+func unregister(id int64) {
+	scheduler.Lock()
+	defer scheduler.Unlock()
+	if entry, ok := scheduler.entries[id]; ok {
+		scheduler.cron.Remove(entry)
+		delete(scheduler.entries, id)
+	}
+	delete(scheduler.pending, id)
+}
+
+// RunAfterUpdate 执行启用的且没有cron表达式的定时选股,以及到点时数据还未更新的定时选股
+func RunAfterUpdate() {
+	scheduler.Lock()
+	pending := scheduler.pending
+	scheduler.pending = map[int64]struct{}{}
+	scheduler.Unlock()
+
+	ls, err := ListSchedule()
+	if err != nil {
+		logs.Err(err)
+		return
+	}
+	for _, v := range ls {
+		if !v.Enable {
+			continue
+		}
+		if _, ok := pending[v.ID]; ok {
+			_, err = RunSchedule(v.ID, TriggerCron)
+			logs.PrintErr(err)
+		} else if v.Cron == "" {
+			_, err = RunSchedule(v.ID, TriggerUpdate)
+			logs.PrintErr(err)
+		}
+	}
+}
+
+// RunSchedule 执行定时选股,保存选股结果并推送,选股失败也会保存执行记录
+func RunSchedule(id int64, trigger string) (*ScheduleRun, error) {
+	s, err := GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler.run.Lock()
+	defer scheduler.run.Unlock()
+
+	start := time.Now()
+	r := &ScheduleRun{ScheduleID: s.ID, Name: s.Name, Trigger: trigger, Picks: []Pick{}}
+	items, err := Run(s.Request)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for _, v := range items {
+		if s.Signal != 0 && v.Signal != s.Signal {
+			continue
+		}
+		r.Picks = append(r.Picks, Pick{
+			Code:   v.Code,
+			Name:   v.Name,
+			Price:  v.Price.Float64(),
+			Score:  v.Score,
+			Signal: v.Signal,
+			Reason: v.Reason,
+		})
+	}
+	r.Count = len(r.Picks)
+	r.Elapsed = time.Since(start).Milliseconds()
+
+	if _, err := common.DB.Insert(r); err != nil {
+		return nil, err
+	}
+	if _, err := common.DB.ID(s.ID).Cols("LastRun").Update(&Schedule{LastRun: start.Unix()}); err != nil {
+		logs.Err(err)
+	}
+	if r.Error != "" {
+		return r, errors.New(r.Error)
+	}
+
+	payload, err := json.Marshal(r.Payload())
+	if err != nil {
+		return r, err
+	}
+	name := fmt.Sprintf("screen-%d-%s", s.ID, start.Format("20060102-150405"))
+	r.Deliveries = notify.Deliver(Source, s.ID, r.ID, s.Sinks, name, payload)
+	return r, nil
+}
+
+// SaveSchedule 新建或修改定时选股,ID为0时新建
+func SaveSchedule(s *Schedule) error {
+	if err := scheduleTable(); err != nil {
+		return err
+	}
+	if err := s.Check(); err != nil {
+		return err
+	}
+	if s.ID == 0 {
+		s.Sinks.KeepPassword(nil)
+		if _, err := common.DB.Insert(s); err != nil {
+			return err
+		}
+	} else {
+		old, err := GetSchedule(s.ID)
+		if err != nil {
+			return err
+		}
+		s.Sinks.KeepPassword(old.Sinks)
+		s.LastRun, s.Created = old.LastRun, old.Created
+		if _, err = common.DB.ID(s.ID).AllCols().Update(s); err != nil {
+			return err
+		}
+	}
+	register(s)
+	return nil
+}
+
+// EnableSchedule 启用或禁用定时选股
+func EnableSchedule(id int64, enable bool) error {
+	s, err := GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	s.Enable = enable
+	if _, err = common.DB.ID(id).Cols("Enable").Update(s); err != nil {
+		return err
+	}
+	register(s)
+	return nil
+}
+
+// GetSchedule 获取定时选股
+func GetSchedule(id int64) (*Schedule, error) {
+	if err := scheduleTable(); err != nil {
+		return nil, err
+	}
+	s := new(Schedule)
+	has, err := common.DB.ID(id).Get(s)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("定时选股[%d]不存在", id)
+	}
+	return s, nil
+}
+
+// ListSchedule 定时选股列表
+func ListSchedule() ([]*Schedule, error) {
+	if err := scheduleTable(); err != nil {
+		return nil, err
+	}
+	ls := []*Schedule(nil)
+	err := common.DB.Asc("ID").Find(&ls)
+	return ls, err
+}
+
+// DeleteSchedule 删除定时选股及执行记录
+func DeleteSchedule(id int64) error {
+	if err := scheduleTable(); err != nil {
+		return err
+	}
+	unregister(id)
+	if _, err := common.DB.Where("ScheduleID=?", id).Delete(new(ScheduleRun)); err != nil {
+		return err
+	}
+	_, err := common.DB.ID(id).Delete(new(Schedule))
+	return err
+}
+
+// ListScheduleRun 定时选股的执行记录,不包含选股结果,按时间倒序,scheduleID为0时返回全部
+func ListScheduleRun(scheduleID int64, limit int) ([]*ScheduleRun, error) {
+	if err := scheduleTable(); err != nil {
+		return nil, err
+	}
+	ls := []*ScheduleRun(nil)
+	session := common.DB.Omit("Picks").Desc("ID")
+	if scheduleID > 0 {
+		session = session.Where("ScheduleID=?", scheduleID)
+	}
+	if limit > 0 {
+		session = session.Limit(limit)
+	}
+	err := session.Find(&ls)
+	return ls, err
+}
+
+// GetScheduleRun 执行记录详情,包含选股结果和推送日志
+func GetScheduleRun(id int64) (*ScheduleRun, error) {
+	if err := scheduleTable(); err != nil {
+		return nil, err
+	}
+	r := new(ScheduleRun)
+	has, err := common.DB.ID(id).Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, fmt.Errorf("执行记录[%d]不存在", id)
+	}
+	r.Deliveries, err = notify.ListDelivery(Source, r.ScheduleID, r.ID, 0)
+	return r, err
+}
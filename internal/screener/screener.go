@@ -1,6 +1,8 @@
 package screener
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/injoyai/strategy/internal/common"
@@ -11,23 +13,32 @@ import (
 // Item 选股结果项
 type Item struct {
 	extend.Info               //基本信息
-	Score       float64       `json:"score"`  // 评分
-	Signal      int           `json:"signal"` // 信号类型 1:买入 -1:卖出
-	Klines      extend.Klines `json:"klines"` //
+	Score       float64       `json:"score"`   // 评分
+	Signal      int           `json:"signal"`  // 信号类型 1:买入 -1:卖出
+	Reason      string        `json:"reason"`  // 信号原因
+	Explain     string        `json:"explain"` // 评分说明
+	Klines      extend.Klines `json:"klines"`  //
 }
 
 // Request 选股请求参数
 type Request struct {
-	Strategies []string `json:"strategies"` // 策略名称列表
-	StartTime  int64    `json:"start_time"` // 开始时间(秒级时间戳)
-	EndTime    int64    `json:"end_time"`   // 结束时间(秒级时间戳)
+	Strategies []string                   `json:"strategies"` // 策略名称列表
+	Tree       *strategy.Node             `json:"tree"`       // 组合策略树,优先于Strategies
+	Weights    map[string]float64         `json:"weights"`    // 策略评分权重,未配置的默认为1
+	Params     map[string]strategy.Params `json:"params"`     // 策略参数覆盖,策略名称->参数
+	StartTime  int64                      `json:"start_time"` // 开始时间(秒级时间戳)
+	EndTime    int64                      `json:"end_time"`   // 结束时间(秒级时间戳)
+	Limit      int                        `json:"limit"`      // 按评分取前N个,0表示不限制
+	MinScore   *float64                   `json:"min_score"`  // 最低评分,可选
+	MaxScore   *float64                   `json:"max_score"`  // 最高评分,可选
+	Adjust     string                     `json:"adjust"`     // 复权类型 qfq:前复权 hfq:后复权 空:不复权
 }
 
 // Run 执行选股策略
 func Run(req Request) (items []Item, err error) {
 
 	// 获取策略实例
-	strat, err := strategy.Group(req.Strategies)
+	strat, err := strategy.Build(req.Tree, req.Strategies, req.Weights, req.Params)
 	if err != nil {
 		return nil, err
 	}
@@ -38,23 +49,51 @@ func Run(req Request) (items []Item, err error) {
 	}
 
 	// 遍历所有股票的日K线数据
-	err = common.Data.RangeKlines(
+	mu := sync.Mutex{}
+	err = common.Klines.RangeKlinesAdjust(
 		100, // 并发数
 		time.Unix(req.StartTime, 0),
 		time.Unix(req.EndTime, 0),
+		req.Adjust,
 		func(info extend.Info, day, min extend.Klines) {
-			// 判断是否满足策略条件
-			if strat.Signal(info, day, min) {
-				// 构造返回结果
-				items = append(items, Item{
-					Info:   info, //基本信息
-					Score:  0,    // 默认评分
-					Signal: 0,    // 买卖信号
-					Klines: day,  // K线数据
-				})
+			// 判断是否满足策略条件,买入和卖出信号都输出
+			d := strategy.Decide(strat, info, day, min)
+			if d.Action == strategy.Hold {
+				return
 			}
+			// 评分并按阈值过滤
+			score, explain := strategy.Score(strat, info, day, min)
+			if (req.MinScore != nil && score < *req.MinScore) ||
+				(req.MaxScore != nil && score > *req.MaxScore) {
+				return
+			}
+			// 构造返回结果
+			mu.Lock()
+			defer mu.Unlock()
+			items = append(items, Item{
+				Info:    info,          //基本信息
+				Score:   score,         // 评分
+				Signal:  int(d.Action), // 买卖信号
+				Reason:  d.Reason,      // 信号原因
+				Explain: explain,       // 评分说明
+				Klines:  day,           // K线数据
+			})
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按评分从高到低排序,取前N个
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return items[i].Code < items[j].Code
+	})
+	if req.Limit > 0 && len(items) > req.Limit {
+		items = items[:req.Limit]
+	}
 
 	return
 
@@ -1,10 +1,17 @@
 package strategy
 
 import (
+	"fmt"
+
+	"github.com/injoyai/strategy/internal/indicator"
 	"github.com/injoyai/tdx/extend"
 )
 
-var _ Interface = (*BullishAlignment)(nil)
+var (
+	_ Interface = (*BullishAlignment)(nil)
+	_ Scorer    = (*BullishAlignment)(nil)
+	_ Streamer  = (*BullishAlignment)(nil)
+)
 
 type BullishAlignment struct{}
 
@@ -49,6 +56,50 @@ func (BullishAlignment) Signal(info extend.Info, dks, min extend.Klines) bool {
 	return true
 }
 
+// NewStream 增量计算均线,逻辑同Signal
+func (BullishAlignment) NewStream(info extend.Info, min extend.Klines) Stream {
+	ns := []int{5, 10, 20, 30}
+	mas := make([]*indicator.SMAStream, len(ns))
+	for i, n := range ns {
+		mas[i] = indicator.NewSMAStream(n)
+	}
+	prev := make([]float64, len(ns))
+	curr := make([]float64, len(ns))
+	count := 0
+	return funcStream(func(k *extend.Kline) Decision {
+		count++
+		copy(prev, curr)
+		for i, ma := range mas {
+			curr[i] = ma.Update(k.Close.Float64())
+		}
+		if count < 31 {
+			return Decision{Action: Hold}
+		}
+		isCurrentBullish := curr[0] > curr[1] && curr[1] > curr[2] && curr[2] > curr[3]
+		isPrevBullish := prev[0] > prev[1] && prev[1] > prev[2] && prev[2] > prev[3]
+		if !isCurrentBullish || isPrevBullish {
+			return Decision{Action: Hold}
+		}
+		for i := range curr {
+			if !(curr[i] > prev[i]) {
+				return Decision{Action: Hold}
+			}
+		}
+		return Decision{Action: Buy}
+	})
+}
+
+// Score 评分为MA5相对MA30的发散程度(百分比),越大说明多头越强
+func (BullishAlignment) Score(info extend.Info, dks, min extend.Klines) (float64, string) {
+	ma5 := MA(dks, 5)
+	ma30 := MA(dks, 30)
+	if ma30 == 0 {
+		return 0, ""
+	}
+	score := (ma5 - ma30) / ma30 * 100
+	return score, fmt.Sprintf("MA5高于MA30 %.2f%%", score)
+}
+
 func init() {
 	Register(BullishAlignment{})
 }
@@ -0,0 +1,355 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/injoyai/tdx/extend"
+)
+
+const (
+	OpAnd      = "and"      //全部满足
+	OpOr       = "or"       //任一满足
+	OpNot      = "not"      //取反
+	OpAtLeast  = "at_least" //至少满足K个
+	OpWeighted = "weighted" //加权投票
+)
+
+// Node 组合策略节点,可以序列化成JSON,叶子节点只需填写策略名称
+// 例 A and (B or C) and not D:
+// {"op":"and","children":[{"strategy":"A"},{"op":"or","children":[{"strategy":"B"},{"strategy":"C"}]},{"op":"not","children":[{"strategy":"D"}]}]}
+type Node struct {
+	Op        string    `json:"op,omitempty"`        //操作符,为空表示叶子节点
+	Strategy  string    `json:"strategy,omitempty"`  //叶子节点的策略名称
+	Children  []*Node   `json:"children,omitempty"`  //子节点
+	K         int       `json:"k,omitempty"`         //at_least 至少满足的数量
+	Weights   []float64 `json:"weights,omitempty"`   //weighted 各子节点的权重,默认1,也用于评分加权
+	Threshold float64   `json:"threshold,omitempty"` //weighted 投票阈值,默认为总权重的一半
+}
+
+// String 表达式形式,便于展示
+func (this *Node) String() string {
+	if this == nil {
+		return ""
+	}
+	if this.Op == "" {
+		return this.Strategy
+	}
+	ls := make([]string, len(this.Children))
+	for i, v := range this.Children {
+		ls[i] = v.String()
+	}
+	switch this.Op {
+	case OpAnd:
+		return "(" + strings.Join(ls, " and ") + ")"
+	case OpOr:
+		return "(" + strings.Join(ls, " or ") + ")"
+	case OpNot:
+		return "not " + strings.Join(ls, "")
+	case OpAtLeast:
+		return fmt.Sprintf("at_least(%d, %s)", this.K, strings.Join(ls, ", "))
+	default:
+		return fmt.Sprintf("%s(%s)", this.Op, strings.Join(ls, ", "))
+	}
+}
+
+// Build 生成策略实例
+func (this *Node) Build() (Interface, error) {
+	return this.BuildWith(nil)
+}
+
+// BuildWith 生成策略实例,params为各策略的参数,策略名称->参数
+func (this *Node) BuildWith(params map[string]Params) (Interface, error) {
+	return this.build(map[string]bool{}, params)
+}
+
+// build visiting 用于检测组合策略的循环引用
+func (this *Node) build(visiting map[string]bool, params map[string]Params) (Interface, error) {
+	if this == nil {
+		return nil, errors.New("未选择策略")
+	}
+
+	if this.Op == "" {
+		if this.Strategy == "" {
+			return nil, errors.New("策略名称不能为空")
+		}
+		if n, ok := composite[this.Strategy]; ok {
+			if visiting[this.Strategy] {
+				return nil, fmt.Errorf("组合策略[%s]存在循环引用", this.Strategy)
+			}
+			visiting[this.Strategy] = true
+			defer delete(visiting, this.Strategy)
+			s, err := n.Node.build(visiting, params)
+			if err != nil {
+				return nil, err
+			}
+			if c, ok := s.(*compose); ok {
+				c.name = n.Name
+			}
+			return s, nil
+		}
+		return With(this.Strategy, params[this.Strategy])
+	}
+
+	if len(this.Children) == 0 {
+		return nil, fmt.Errorf("操作[%s]缺少子节点", this.Op)
+	}
+	c := &compose{Node: this, weights: make([]float64, len(this.Children))}
+	for i, child := range this.Children {
+		s, err := child.build(visiting, params)
+		if err != nil {
+			return nil, err
+		}
+		c.list = append(c.list, s)
+		c.weights[i] = 1
+		if i < len(this.Weights) {
+			c.weights[i] = this.Weights[i]
+		}
+		c.total += c.weights[i]
+	}
+
+	switch this.Op {
+	case OpAnd, OpOr:
+	case OpNot:
+		if len(this.Children) != 1 {
+			return nil, errors.New("操作[not]只能有一个子节点")
+		}
+	case OpAtLeast:
+		if this.K <= 0 || this.K > len(this.Children) {
+			return nil, fmt.Errorf("操作[at_least]的K(%d)需要在1~%d之间", this.K, len(this.Children))
+		}
+	case OpWeighted:
+		c.threshold = this.Threshold
+		if c.threshold <= 0 {
+			c.threshold = c.total / 2
+		}
+	default:
+		return nil, fmt.Errorf("未知操作[%s]", this.Op)
+	}
+
+	return c, nil
+}
+
+// Names 叶子节点引用的全部策略名称
+func (this *Node) Names() []string {
+	if this == nil {
+		return nil
+	}
+	if this.Op == "" {
+		return []string{this.Strategy}
+	}
+	var out []string
+	for _, v := range this.Children {
+		out = append(out, v.Names()...)
+	}
+	return out
+}
+
+var (
+	_ Decider  = (*compose)(nil)
+	_ Scorer   = (*compose)(nil)
+	_ Streamer = (*compose)(nil)
+)
+
+type compose struct {
+	*Node
+	name      string
+	list      []Interface
+	weights   []float64
+	total     float64
+	threshold float64
+}
+
+func (this *compose) Name() string {
+	if this.name != "" {
+		return this.name
+	}
+	return this.Node.String()
+}
+
+func (this *compose) Type() string { return DayKline }
+
+func (this *compose) Signal(info extend.Info, day, min extend.Klines) bool {
+	return this.Decide(info, day, min).Action == Buy
+}
+
+// Decide
+// and: 任一卖出则卖出,全部买入则买入
+// or: 任一买入则买入,否则任一卖出则卖出
+// not: 子节点买入则观望,否则买入
+// at_least: 买入数量>=K则买入,卖出数量>=K则卖出
+// weighted: 按权重对买入(+1)卖出(-1)投票,>=阈值买入,<=-阈值卖出
+func (this *compose) Decide(info extend.Info, day, min extend.Klines) Decision {
+	return this.combine(func(i int) Decision {
+		return Decide(this.list[i], info, day, min)
+	})
+}
+
+// NewStream 子节点各自维护流式状态,每根K线全部推送后再组合
+func (this *compose) NewStream(info extend.Info, min extend.Klines) Stream {
+	streams := make([]Stream, len(this.list))
+	for i, s := range this.list {
+		streams[i] = NewStream(s, info, min)
+	}
+	ds := make([]Decision, len(this.list))
+	return funcStream(func(k *extend.Kline) Decision {
+		for i, s := range streams {
+			ds[i] = s.OnBar(k)
+		}
+		return this.combine(func(i int) Decision { return ds[i] })
+	})
+}
+
+// combine 按操作符组合子节点的决策,get按需获取第i个子节点的决策
+func (this *compose) combine(get func(i int) Decision) Decision {
+	switch this.Op {
+	case OpAnd:
+		out := Decision{Action: Buy}
+		for i := range this.list {
+			d := get(i)
+			switch d.Action {
+			case Sell:
+				return d
+			case Hold:
+				out.Action = Hold
+			case Buy:
+				if d.Weight > 0 && (out.Weight == 0 || d.Weight < out.Weight) {
+					out.Weight = d.Weight
+				}
+				out.Reason = joinReason(out.Reason, d.Reason)
+			}
+		}
+		if out.Action == Hold {
+			return Decision{Action: Hold}
+		}
+		return out
+
+	case OpOr:
+		out := Decision{Action: Hold}
+		for i := range this.list {
+			d := get(i)
+			if d.Action == Buy {
+				return d
+			}
+			if d.Action == Sell && out.Action == Hold {
+				out = d
+			}
+		}
+		return out
+
+	case OpNot:
+		if get(0).Action == Buy {
+			return Decision{Action: Hold}
+		}
+		return Decision{Action: Buy}
+
+	case OpAtLeast:
+		var buy, sell int
+		for i := range this.list {
+			switch get(i).Action {
+			case Buy:
+				buy++
+			case Sell:
+				sell++
+			}
+		}
+		switch {
+		case buy >= this.K:
+			return Decision{Action: Buy, Reason: fmt.Sprintf("%d/%d个策略买入", buy, len(this.list))}
+		case sell >= this.K:
+			return Decision{Action: Sell, Reason: fmt.Sprintf("%d/%d个策略卖出", sell, len(this.list))}
+		}
+		return Decision{Action: Hold}
+
+	case OpWeighted:
+		var vote float64
+		for i := range this.list {
+			vote += float64(get(i).Action) * this.weights[i]
+		}
+		switch {
+		case vote >= this.threshold:
+			return Decision{Action: Buy, Reason: fmt.Sprintf("加权投票%.2f", vote)}
+		case vote <= -this.threshold:
+			return Decision{Action: Sell, Reason: fmt.Sprintf("加权投票%.2f", vote)}
+		}
+		return Decision{Action: Hold}
+	}
+	return Decision{Action: Hold}
+}
+
+// Score 子节点评分按权重加权求和,not节点不参与评分
+func (this *compose) Score(info extend.Info, day, min extend.Klines) (float64, string) {
+	if this.Op == OpNot {
+		return 0, ""
+	}
+	var score float64
+	var reason string
+	for i, s := range this.list {
+		v, r := Score(s, info, day, min)
+		score += v * this.weights[i]
+		reason = joinReason(reason, r)
+	}
+	return score, reason
+}
+
+/*
+
+
+
+ */
+
+// Composite 保存的组合策略
+type Composite struct {
+	Name string `xorm:"pk"`
+	Node *Node  `xorm:"json"`
+	Memo string
+}
+
+// RegisterComposite 注册组合策略,会校验能否正常生成
+func RegisterComposite(c *Composite) error {
+	if c.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if _, ok := internal[c.Name]; ok {
+		return fmt.Errorf("名称[%s]与内置策略冲突", c.Name)
+	}
+	if _, ok := custom[c.Name]; ok {
+		return fmt.Errorf("名称[%s]与脚本策略冲突", c.Name)
+	}
+	if _, err := c.Node.build(map[string]bool{c.Name: true}, nil); err != nil {
+		return err
+	}
+	composite[c.Name] = c
+	return nil
+}
+
+// Build 生成策略实例,优先使用组合树,否则把策略列表按"全部满足"组合
+// weights为策略列表的评分权重,params为各策略的参数覆盖
+func Build(tree *Node, names []string, weights map[string]float64, params map[string]Params) (Interface, error) {
+	if tree == nil {
+		if len(names) == 0 {
+			return nil, errors.New("未选择策略")
+		}
+		tree = &Node{Op: OpAnd}
+		for _, name := range names {
+			w, ok := weights[name]
+			if !ok {
+				w = 1
+			}
+			tree.Children = append(tree.Children, &Node{Strategy: name})
+			tree.Weights = append(tree.Weights, w)
+		}
+	}
+	return tree.BuildWith(params)
+}
+
+func joinReason(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + ";" + b
+}
@@ -0,0 +1,205 @@
+package strategy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+)
+
+// fixed 固定信号和评分的策略
+type fixed struct {
+	name   string
+	action Action
+	score  float64
+}
+
+func (this fixed) Name() string { return this.name }
+
+func (this fixed) Type() string { return DayKline }
+
+func (this fixed) Signal(info extend.Info, day, min extend.Klines) bool { return this.action == Buy }
+
+func (this fixed) Decide(info extend.Info, day, min extend.Klines) Decision {
+	return Decision{Action: this.action, Reason: this.name}
+}
+
+func (this fixed) Score(info extend.Info, day, min extend.Klines) (float64, string) {
+	return this.score, this.name
+}
+
+// registerFixed 注册测试用的策略,测试结束后删除
+func registerFixed(t *testing.T, ls ...fixed) {
+	for _, s := range ls {
+		Register(s)
+	}
+	t.Cleanup(func() {
+		for _, s := range ls {
+			delete(internal, s.name)
+		}
+	})
+}
+
+func TestRegisterComposites(t *testing.T) {
+	registerFixed(t, fixed{name: "买"}, fixed{name: "卖"})
+	t.Cleanup(func() {
+		for _, name := range []string{"A", "B", "C", "D"} {
+			delete(composite, name)
+		}
+	})
+	//B引用A,C引用B,按表中的顺序无法一次注册
+	ls := []*Composite{
+		{Name: "C", Node: &Node{Op: OpNot, Children: []*Node{{Strategy: "B"}}}},
+		{Name: "B", Node: &Node{Op: OpOr, Children: []*Node{{Strategy: "A"}, {Strategy: "卖"}}}},
+		{Name: "A", Node: &Node{Op: OpAnd, Children: []*Node{{Strategy: "买"}}}},
+		{Name: "D", Node: &Node{Op: OpAnd, Children: []*Node{{Strategy: "不存在"}}}},
+	}
+	errs := registerComposites(ls)
+	if len(errs) != 1 {
+		t.Fatalf("注册失败 %v", errs)
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		if Get(name) == nil {
+			t.Errorf("组合策略[%s]没有注册", name)
+		}
+	}
+	if Get("D") != nil {
+		t.Error("引用不存在策略的组合策略不能注册")
+	}
+
+	//被引用的组合策略不能删除
+	if err := DelComposite("A"); err == nil {
+		t.Error("被B引用的A不能删除")
+	}
+	if err := DelComposite("C"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DelComposite("B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DelComposite("A"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leaves 生成叶子节点
+func leaves(names ...string) []*Node {
+	ls := make([]*Node, len(names))
+	for i, name := range names {
+		ls[i] = &Node{Strategy: name}
+	}
+	return ls
+}
+
+func TestNodeDecide(t *testing.T) {
+	registerFixed(t,
+		fixed{name: "买入", action: Buy, score: 1},
+		fixed{name: "卖出", action: Sell, score: 2},
+		fixed{name: "观望", action: Hold, score: 3},
+	)
+	for _, c := range []struct {
+		name  string
+		node  *Node
+		want  Action
+		score float64
+	}{
+		{"and全部买入", &Node{Op: OpAnd, Children: leaves("买入", "买入")}, Buy, 2},
+		{"and有观望", &Node{Op: OpAnd, Children: leaves("买入", "观望")}, Hold, 4},
+		{"and有卖出", &Node{Op: OpAnd, Children: leaves("买入", "观望", "卖出")}, Sell, 6},
+		{"or有买入", &Node{Op: OpOr, Children: leaves("卖出", "买入")}, Buy, 3},
+		{"or只有卖出", &Node{Op: OpOr, Children: leaves("观望", "卖出")}, Sell, 5},
+		{"or全部观望", &Node{Op: OpOr, Children: leaves("观望", "观望")}, Hold, 6},
+		{"not买入", &Node{Op: OpNot, Children: leaves("买入")}, Hold, 0},
+		{"not卖出", &Node{Op: OpNot, Children: leaves("卖出")}, Buy, 0},
+		{"at_least买入达到K", &Node{Op: OpAtLeast, K: 2, Children: leaves("买入", "买入", "卖出")}, Buy, 4},
+		{"at_least卖出达到K", &Node{Op: OpAtLeast, K: 2, Children: leaves("买入", "卖出", "卖出")}, Sell, 5},
+		{"at_least都未达到K", &Node{Op: OpAtLeast, K: 2, Children: leaves("买入", "卖出", "观望")}, Hold, 6},
+		{"weighted默认阈值", &Node{Op: OpWeighted, Weights: []float64{3, 1, 1}, Children: leaves("买入", "卖出", "卖出")}, Hold, 7},
+		{"weighted指定阈值", &Node{Op: OpWeighted, Weights: []float64{3, 1, 1}, Threshold: 1, Children: leaves("买入", "卖出", "卖出")}, Buy, 7},
+		{"weighted默认权重", &Node{Op: OpWeighted, Children: leaves("卖出", "卖出", "观望")}, Sell, 7},
+		{"嵌套", &Node{Op: OpAnd, Children: []*Node{{Strategy: "买入"}, {Op: OpNot, Children: leaves("卖出")}}}, Buy, 1},
+	} {
+		s, err := c.node.Build()
+		if err != nil {
+			t.Fatalf("[%s] %v", c.name, err)
+		}
+		k := &extend.Kline{Kline: &protocol.Kline{}}
+		if d := Decide(s, extend.Info{}, extend.Klines{k}, nil); d.Action != c.want {
+			t.Errorf("[%s] 信号 %v, 期望 %v", c.name, d.Action, c.want)
+		}
+		if d := NewStream(s, extend.Info{}, nil).OnBar(k); d.Action != c.want {
+			t.Errorf("[%s] 流式信号 %v, 期望 %v", c.name, d.Action, c.want)
+		}
+		if score, _ := Score(s, extend.Info{}, extend.Klines{k}, nil); score != c.score {
+			t.Errorf("[%s] 评分 %v, 期望 %v", c.name, score, c.score)
+		}
+	}
+}
+
+func TestNodeBuildError(t *testing.T) {
+	registerFixed(t, fixed{name: "买入"})
+	for _, c := range []struct {
+		name string
+		node *Node
+	}{
+		{"空节点", nil},
+		{"叶子没有名称", &Node{}},
+		{"策略不存在", &Node{Strategy: "不存在"}},
+		{"没有子节点", &Node{Op: OpAnd}},
+		{"not多个子节点", &Node{Op: OpNot, Children: leaves("买入", "买入")}},
+		{"at_least的K为0", &Node{Op: OpAtLeast, Children: leaves("买入")}},
+		{"at_least的K超过子节点数量", &Node{Op: OpAtLeast, K: 2, Children: leaves("买入")}},
+		{"未知操作", &Node{Op: "xor", Children: leaves("买入")}},
+	} {
+		if _, err := c.node.Build(); err == nil {
+			t.Errorf("[%s] 需要返回错误", c.name)
+		}
+	}
+}
+
+func TestNodeJSON(t *testing.T) {
+	//Node文档中的例子
+	const s = `{"op":"and","children":[{"strategy":"A"},{"op":"or","children":[{"strategy":"B"},{"strategy":"C"}]},{"op":"not","children":[{"strategy":"D"}]}]}`
+	want := &Node{Op: OpAnd, Children: []*Node{
+		{Strategy: "A"},
+		{Op: OpOr, Children: leaves("B", "C")},
+		{Op: OpNot, Children: leaves("D")},
+	}}
+	node := new(Node)
+	if err := json.Unmarshal([]byte(s), node); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(node, want) {
+		t.Fatalf("解析结果 %s", node)
+	}
+	if node.String() != "(A and (B or C) and not D)" {
+		t.Errorf("表达式 %s", node)
+	}
+	if names := node.Names(); !reflect.DeepEqual(names, []string{"A", "B", "C", "D"}) {
+		t.Errorf("引用的策略 %v", names)
+	}
+	bs, err := json.Marshal(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != s {
+		t.Errorf("序列化结果 %s", bs)
+	}
+
+	//at_least和weighted的参数
+	node = &Node{Op: OpWeighted, Weights: []float64{2, 1}, Threshold: 1.5, Children: []*Node{
+		{Op: OpAtLeast, K: 1, Children: leaves("A")}, {Strategy: "B"},
+	}}
+	if bs, err = json.Marshal(node); err != nil {
+		t.Fatal(err)
+	}
+	back := new(Node)
+	if err = json.Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, node) {
+		t.Errorf("往返结果 %s", bs)
+	}
+}
@@ -0,0 +1,106 @@
+package strategy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/injoyai/logs"
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/tdx/extend"
+)
+
+// ContextDecider 多周期策略,可选实现,通过上下文获取周线,月线,分钟线等,
+// 例如周线MACD金叉且日线突破
+type ContextDecider interface {
+	Interface
+	DecideContext(ctx *Context) Decision
+}
+
+// Context 策略上下文,除日线外的周期都在第一次使用时合成,
+// 周线/月线/季线/年线由日线合成,5/15/30/60分钟线由1分钟线合成,
+// 分钟线没有传入时从数据源加载,只使用最后一根日线当天及之前的数据,回测时不会用到未来数据
+// 同一个上下文不能并发使用
+type Context struct {
+	Info extend.Info
+	Day  extend.Klines
+
+	min     extend.Klines
+	loadMin func() extend.Klines //加载分钟线,可能包含最后一根日线之后的数据
+	loaded  bool
+	cache   map[string]extend.Klines
+}
+
+// NewContext 新建上下文,min为空时按需从数据源加载
+func NewContext(info extend.Info, day, min extend.Klines) *Context {
+	c := &Context{Info: info, Day: day, min: min, loaded: min != nil}
+	c.loadMin = func() extend.Klines {
+		if len(c.Day) == 0 {
+			return nil
+		}
+		return loadMinute(c.Info.Code, time.Unix(data.DayStart(c.Day[0].Time), 0), time.Unix(dayEnd(c.Day[len(c.Day)-1].Time), 0))
+	}
+	return c
+}
+
+// Minute 1分钟线,截至最后一根日线当天
+func (this *Context) Minute() extend.Klines {
+	if !this.loaded {
+		this.min = this.loadMin()
+		this.loaded = true
+	}
+	if len(this.Day) == 0 {
+		return nil
+	}
+	end := dayEnd(this.Day[len(this.Day)-1].Time)
+	i := sort.Search(len(this.min), func(i int) bool { return this.min[i].Unix >= end })
+	return this.min[:i]
+}
+
+// Klines 指定周期的K线,周期见extend.Day,extend.Week,extend.Minute5等
+func (this *Context) Klines(period string) extend.Klines {
+	switch period {
+	case extend.Day:
+		return this.Day
+	case extend.Minute:
+		return this.Minute()
+	}
+	if ks, ok := this.cache[period]; ok {
+		return ks
+	}
+	src := this.Day
+	switch period {
+	case extend.Minute5, extend.Minute15, extend.Minute30, extend.Minute60:
+		src = this.Minute()
+	}
+	ks, err := data.Resample(src, period)
+	logs.PrintErr(err)
+	if this.cache == nil {
+		this.cache = map[string]extend.Klines{}
+	}
+	this.cache[period] = ks
+	return ks
+}
+
+func (this *Context) Week() extend.Klines     { return this.Klines(extend.Week) }
+func (this *Context) Month() extend.Klines    { return this.Klines(extend.Month) }
+func (this *Context) Quarter() extend.Klines  { return this.Klines(extend.Quarter) }
+func (this *Context) Year() extend.Klines     { return this.Klines(extend.Year) }
+func (this *Context) Minute5() extend.Klines  { return this.Klines(extend.Minute5) }
+func (this *Context) Minute15() extend.Klines { return this.Klines(extend.Minute15) }
+func (this *Context) Minute30() extend.Klines { return this.Klines(extend.Minute30) }
+func (this *Context) Minute60() extend.Klines { return this.Klines(extend.Minute60) }
+
+// loadMinute 从数据源加载分钟线
+func loadMinute(code string, start, end time.Time) extend.Klines {
+	if common.Klines == nil || code == "" {
+		return nil
+	}
+	ks, err := common.Klines.GetMinKlines(code, start, end)
+	logs.PrintErr(err)
+	return ks
+}
+
+func dayEnd(t time.Time) int64 {
+	return data.DayStart(t) + 24*60*60
+}
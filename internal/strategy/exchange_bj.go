@@ -7,7 +7,10 @@ import (
 	"github.com/injoyai/tdx/protocol"
 )
 
-var _ Interface = (*BJExchange)(nil)
+var (
+	_ Interface = (*BJExchange)(nil)
+	_ Streamer  = (*BJExchange)(nil)
+)
 
 type BJExchange struct{}
 
@@ -21,6 +24,11 @@ func (BJExchange) Signal(info extend.Info, day, min extend.Klines) bool {
 	return strings.HasPrefix(info.Code, protocol.ExchangeSH.String())
 }
 
+// NewStream 只依赖基本信息,不需要保留历史K线
+func (this BJExchange) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 1)
+}
+
 func init() {
 	Register(BJExchange{})
 }
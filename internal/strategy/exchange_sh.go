@@ -7,7 +7,10 @@ import (
 	"github.com/injoyai/tdx/protocol"
 )
 
-var _ Interface = (*SHExchange)(nil)
+var (
+	_ Interface = (*SHExchange)(nil)
+	_ Streamer  = (*SHExchange)(nil)
+)
 
 type SHExchange struct{}
 
@@ -21,6 +24,11 @@ func (SHExchange) Signal(info extend.Info, day, min extend.Klines) bool {
 	return strings.HasPrefix(info.Code, protocol.ExchangeSH.String())
 }
 
+// NewStream 只依赖基本信息,不需要保留历史K线
+func (this SHExchange) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 1)
+}
+
 func init() {
 	Register(SHExchange{})
 }
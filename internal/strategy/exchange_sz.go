@@ -7,7 +7,10 @@ import (
 	"github.com/injoyai/tdx/protocol"
 )
 
-var _ Interface = (*SZExchange)(nil)
+var (
+	_ Interface = (*SZExchange)(nil)
+	_ Streamer  = (*SZExchange)(nil)
+)
 
 type SZExchange struct{}
 
@@ -21,6 +24,11 @@ func (SZExchange) Signal(info extend.Info, day, min extend.Klines) bool {
 	return strings.HasPrefix(info.Code, protocol.ExchangeSH.String())
 }
 
+// NewStream 只依赖基本信息,不需要保留历史K线
+func (this SZExchange) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 1)
+}
+
 func init() {
 	Register(SZExchange{})
 }
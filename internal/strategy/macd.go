@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/tdx/extend"
+)
+
+var (
+	_ Decider       = (*MACDCross)(nil)
+	_ Streamer      = (*MACDCross)(nil)
+	_ Parameterized = (*MACDCross)(nil)
+)
+
+func init() {
+	Register(&MACDCross{
+		Fast:   12, // 快线周期 (默认12)
+		Slow:   26, // 慢线周期 (默认26)
+		Period: 9,  // 信号线周期 (默认9)
+	})
+}
+
+// MACDCross MACD金叉买入,死叉卖出
+type MACDCross struct {
+	Fast   int // 快线周期 (默认12)
+	Slow   int // 慢线周期 (默认26)
+	Period int // 信号线周期 (默认9)
+}
+
+func (s *MACDCross) Name() string { return "MACD金叉死叉" }
+
+func (s *MACDCross) Type() string { return DayKline }
+
+func (s *MACDCross) Params() []Param {
+	return []Param{
+		{Name: "Fast", Type: ParamInt, Default: 12, Min: f64(2), Max: f64(60), Desc: "快线周期"},
+		{Name: "Slow", Type: ParamInt, Default: 26, Min: f64(5), Max: f64(120), Desc: "慢线周期"},
+		{Name: "Period", Type: ParamInt, Default: 9, Min: f64(2), Max: f64(60), Desc: "信号线周期"},
+	}
+}
+
+func (s *MACDCross) WithParams(values Params) (Interface, error) {
+	cp := *s
+	if err := setFields(&cp, s.Params(), values); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Signal 当日金叉
+func (s *MACDCross) Signal(info extend.Info, day, min extend.Klines) bool {
+	return s.Decide(info, day, min).Action == Buy
+}
+
+// Decide 每次都用全部历史计算MACD,回测时优先使用NewStream
+func (s *MACDCross) Decide(info extend.Info, day, min extend.Klines) Decision {
+	if len(day) < 2 {
+		return Decision{Action: Hold}
+	}
+	dif, dea, _ := indicator.MACD(indicator.Closes(day), s.Fast, s.Slow, s.Period)
+	n := len(day) - 1
+	return s.cross(dif[n-1], dea[n-1], dif[n], dea[n])
+}
+
+// NewStream 增量计算MACD
+func (s *MACDCross) NewStream(info extend.Info, min extend.Klines) Stream {
+	m := indicator.NewMACDStream(s.Fast, s.Slow, s.Period)
+	count := 0
+	var lastDif, lastDea float64
+	return funcStream(func(k *extend.Kline) Decision {
+		dif, dea, _ := m.Update(k.Close.Float64())
+		defer func() { lastDif, lastDea = dif, dea }()
+		count++
+		if count < 2 {
+			return Decision{Action: Hold}
+		}
+		return s.cross(lastDif, lastDea, dif, dea)
+	})
+}
+
+func (s *MACDCross) cross(lastDif, lastDea, dif, dea float64) Decision {
+	switch {
+	case dif > dea && lastDif <= lastDea:
+		return Decision{Action: Buy, Reason: "MACD金叉"}
+	case dif < dea && lastDif >= lastDea:
+		return Decision{Action: Sell, Reason: "MACD死叉"}
+	}
+	return Decision{Action: Hold}
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/injoyai/tdx/extend"
 )
 
-var _ Interface = (*SZExchange)(nil)
+var (
+	_ Interface = (*SZExchange)(nil)
+	_ Streamer  = (*NoBuyLimit)(nil)
+)
 
 type NoBuyLimit struct{}
 
@@ -21,6 +24,11 @@ func (NoBuyLimit) Signal(info extend.Info, day, min extend.Klines) bool {
 		strings.HasPrefix(info.Code, "sz0")
 }
 
+// NewStream 只依赖基本信息,不需要保留历史K线
+func (this NoBuyLimit) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 1)
+}
+
 func init() {
 	Register(NoBuyLimit{})
 }
@@ -4,6 +4,11 @@ import (
 	"github.com/injoyai/tdx/extend"
 )
 
+var (
+	_ Parameterized = (*Ouy)(nil)
+	_ Streamer      = (*Ouy)(nil)
+)
+
 // Ouy 欧阳总策略结构体
 type Ouy struct {
 	LimitUpThreshold    float64 // 涨停阈值（默认0.098，即9.8%）
@@ -16,17 +21,47 @@ func (o *Ouy) Name() string { return "欧阳总策略" }
 
 func (o *Ouy) Type() string { return DayKline }
 
+func (o *Ouy) Params() []Param {
+	return []Param{
+		{Name: "LimitUpThreshold", Type: ParamFloat, Default: 0.098, Min: f64(0.01), Max: f64(0.3), Desc: "涨停阈值"},
+		{Name: "RecentDaysToCheck", Type: ParamInt, Default: 20, Min: f64(2), Max: f64(250), Desc: "检查最近多少个交易日"},
+		{Name: "ConsecutiveBullDays", Type: ParamInt, Default: 2, Min: f64(1), Max: f64(20), Desc: "跳空后要求的连续阳线天数(含跳空当天)"},
+		{Name: "VolumeAvgDays", Type: ParamInt, Default: 5, Min: f64(1), Max: f64(60), Desc: "成交量均线计算天数"},
+	}
+}
+
+func (o *Ouy) WithParams(values Params) (Interface, error) {
+	cp := *o
+	if err := setFields(&cp, o.Params(), values); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// NewStream 只需要最近的K线,保留固定长度的历史
+func (o *Ouy) NewStream(info extend.Info, min extend.Klines) Stream {
+	n := o.RecentDaysToCheck
+	if n <= 0 {
+		n = 20
+	}
+	//多保留1根用于计算涨跌幅
+	n = max(n+1, o.VolumeAvgDays+1)
+	return newWindowStream(o, info, min, n)
+}
+
 // Signal 选股策略，满足以下4个条件：
 // 1. 近N个交易日内出现过一次涨停（涨幅 ≥ LimitUpThreshold）
 // 2. 涨停之后的下一天出现向上跳空高开（当日开盘价 > 涨停日收盘价）
 // 3. 跳空之后出现连续阳线（至少 ConsecutiveBullDays 根连续阳线，含跳空当天）
 // 4. 最新一个交易日的成交量明显放大（> 过去M日均量 且 > 昨日成交量）
 func (o *Ouy) Signal(info extend.Info, klines, minKlines extend.Klines) bool {
-	if o.RecentDaysToCheck <= 0 {
-		o.RecentDaysToCheck = 20
+	//注册的实例会被并发使用,不修改自身的字段
+	recent := o.RecentDaysToCheck
+	if recent <= 0 {
+		recent = 20
 	}
 
-	if len(klines) < o.RecentDaysToCheck {
+	if len(klines) < recent {
 		return false
 	}
 
@@ -34,7 +69,7 @@ func (o *Ouy) Signal(info extend.Info, klines, minKlines extend.Klines) bool {
 
 	// 从最近的K线往前检查，限定在最近N个交易日内
 	n := len(klines)
-	startIndex := n - o.RecentDaysToCheck
+	startIndex := n - recent
 	if startIndex < 0 {
 		startIndex = 0
 	}
@@ -119,7 +154,7 @@ func (o *Ouy) Signal(info extend.Info, klines, minKlines extend.Klines) bool {
 }
 
 func init() {
-	// 使用默认配置注册
+	// 和data/strategy/欧阳总策略.go同名,脚本优先,注册后不会被使用,参数由脚本的包级变量声明
 	//Register(&Ouy{
 	//	LimitUpThreshold:    0.098, // 涨停阈值（默认0.098，即9.8%）
 	//	RecentDaysToCheck:   20,    // 检查最近多少个交易日（默认20天）
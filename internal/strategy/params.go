@@ -0,0 +1,257 @@
+package strategy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/injoyai/conv"
+)
+
+const (
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+	ParamString = "string"
+)
+
+// Param 策略参数声明
+type Param struct {
+	Name    string   `json:"name"`          //参数名称,内置策略对应结构体字段名,脚本对应包级变量名
+	Type    string   `json:"type"`          //参数类型 int/float/bool/string
+	Default any      `json:"default"`       //默认值
+	Min     *float64 `json:"min,omitempty"` //最小值,数值类型有效
+	Max     *float64 `json:"max,omitempty"` //最大值,数值类型有效
+	Desc    string   `json:"desc"`          //参数说明
+}
+
+// Convert 把参数值转换成声明的类型,并校验范围
+func (this Param) Convert(v any) (any, error) {
+	var out any
+	switch this.Type {
+	case ParamInt:
+		out = conv.Int(v)
+	case ParamFloat:
+		out = conv.Float64(v)
+	case ParamBool:
+		return conv.Bool(v), nil
+	default:
+		return conv.String(v), nil
+	}
+	f := conv.Float64(out)
+	if this.Min != nil && f < *this.Min {
+		return nil, fmt.Errorf("参数[%s]不能小于%v", this.Name, *this.Min)
+	}
+	if this.Max != nil && f > *this.Max {
+		return nil, fmt.Errorf("参数[%s]不能大于%v", this.Name, *this.Max)
+	}
+	return out, nil
+}
+
+// Params 参数取值,参数名称->值
+type Params = map[string]any
+
+// Parameterized 可调参数策略,可选实现
+type Parameterized interface {
+	Interface
+	Params() []Param                             //参数声明
+	WithParams(values Params) (Interface, error) //按参数生成新实例,不修改原策略
+}
+
+// GetParams 获取策略的参数声明,未实现Parameterized的策略返回nil
+func GetParams(s Interface) []Param {
+	if p, ok := s.(Parameterized); ok {
+		return p.Params()
+	}
+	return nil
+}
+
+// With 获取策略并应用参数,values为空时返回原策略
+func With(name string, values Params) (Interface, error) {
+	s := Get(name)
+	if s == nil {
+		return nil, fmt.Errorf("策略[%s]不存在", name)
+	}
+	if len(values) == 0 {
+		return s, nil
+	}
+	p, ok := s.(Parameterized)
+	if !ok {
+		return nil, fmt.Errorf("策略[%s]不支持参数", name)
+	}
+	return p.WithParams(values)
+}
+
+// convertParams 校验参数并转换成声明的类型
+func convertParams(ps []Param, values Params) (Params, error) {
+	out := make(Params, len(values))
+	for k, v := range values {
+		found := false
+		for _, p := range ps {
+			if p.Name == k {
+				val, err := p.Convert(v)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = val
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("参数[%s]不存在", k)
+		}
+	}
+	return out, nil
+}
+
+// setFields 按参数名称设置结构体字段,ptr需要是结构体指针
+func setFields(ptr any, ps []Param, values Params) error {
+	values, err := convertParams(ps, values)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(ptr).Elem()
+	for k, v := range values {
+		f := rv.FieldByName(k)
+		if !f.IsValid() || !f.CanSet() {
+			return fmt.Errorf("参数[%s]不存在", k)
+		}
+		f.Set(reflect.ValueOf(v).Convert(f.Type()))
+	}
+	return nil
+}
+
+// paramsKey 参数的唯一标识,用于缓存
+func paramsKey(values Params) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ls := make([]string, len(keys))
+	for i, k := range keys {
+		ls[i] = fmt.Sprintf("%s=%v", k, values[k])
+	}
+	return strings.Join(ls, "&")
+}
+
+func f64(f float64) *float64 { return &f }
+
+/*
+
+
+
+ */
+
+// scriptParamRange 注释末尾的取值范围,例 // 涨停阈值 [0.05,0.3]
+var scriptParamRange = regexp.MustCompile(`\[\s*(-?[\d.]+)?\s*,\s*(-?[\d.]+)?\s*\]\s*$`)
+
+// scriptVar 脚本中可调的包级变量
+type scriptVar struct {
+	Param
+	goType string //变量的类型,未声明时按字面量推断
+}
+
+// parseScriptParams 解析脚本的包级变量作为参数,只识别导出的、用字面量初始化的变量
+// 变量的行尾注释作为说明,注释末尾可以用[min,max]声明取值范围
+func parseScriptParams(script string) ([]scriptVar, error) {
+	const prefix = "package p\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", prefix+script, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var out []scriptVar
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if len(vs.Names) != len(vs.Values) {
+				continue
+			}
+			desc := ""
+			if vs.Comment != nil {
+				desc = strings.TrimSpace(vs.Comment.Text())
+			}
+			for i, name := range vs.Names {
+				if !name.IsExported() {
+					continue
+				}
+				v := scriptVar{Param: Param{Name: name.Name, Desc: desc}}
+				lit, neg := vs.Values[i], false
+				if u, ok := lit.(*ast.UnaryExpr); ok && u.Op == token.SUB {
+					lit, neg = u.X, true
+				}
+				switch x := lit.(type) {
+				case *ast.BasicLit:
+					switch x.Kind {
+					case token.INT:
+						n, _ := strconv.Atoi(x.Value)
+						v.Type, v.Default = ParamInt, conv.Select(neg, -n, n)
+					case token.FLOAT:
+						n, _ := strconv.ParseFloat(x.Value, 64)
+						v.Type, v.Default = ParamFloat, conv.Select(neg, -n, n)
+					case token.STRING:
+						s, _ := strconv.Unquote(x.Value)
+						v.Type, v.Default = ParamString, s
+					default:
+						continue
+					}
+				case *ast.Ident:
+					if x.Name != "true" && x.Name != "false" {
+						continue
+					}
+					v.Type, v.Default = ParamBool, x.Name == "true"
+				default:
+					continue
+				}
+				if m := scriptParamRange.FindStringSubmatch(desc); m != nil {
+					if m[1] != "" {
+						n, _ := strconv.ParseFloat(m[1], 64)
+						v.Min = f64(n)
+					}
+					if m[2] != "" {
+						n, _ := strconv.ParseFloat(m[2], 64)
+						v.Max = f64(n)
+					}
+					v.Desc = strings.TrimSpace(scriptParamRange.ReplaceAllString(desc, ""))
+				}
+				v.goType = map[string]string{ParamInt: "int", ParamFloat: "float64", ParamBool: "bool", ParamString: "string"}[v.Type]
+				if vs.Type != nil {
+					start, end := fset.Position(vs.Type.Pos()).Offset, fset.Position(vs.Type.End()).Offset
+					v.goType = (prefix + script)[start:end]
+				}
+				out = append(out, v)
+			}
+		}
+	}
+	return out, nil
+}
+
+// scriptSetter 生成设置包级变量的脚本函数,切换参数时调用,不需要重新解释脚本
+func scriptSetter(name string, vars []scriptVar) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "\nfunc %s(m map[string]interface{}) {\n", name)
+	for _, v := range vars {
+		base := v.Type
+		switch v.Type {
+		case ParamFloat:
+			base = "float64"
+		case ParamString:
+			base = "string"
+		}
+		fmt.Fprintf(&b, "\tif v, ok := m[%q]; ok {\n\t\t%s = %s(v.(%s))\n\t}\n", v.Name, v.Name, v.goType, base)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testParams = []Param{
+	{Name: "N", Type: ParamInt, Min: f64(1), Max: f64(10)},
+	{Name: "Rate", Type: ParamFloat, Min: f64(0)},
+	{Name: "On", Type: ParamBool},
+	{Name: "Label", Type: ParamString},
+}
+
+func TestConvertParams(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		values Params
+		want   Params
+		err    bool
+	}{
+		{"转换成声明的类型", Params{"N": 5.0, "Rate": "0.5", "On": "true", "Label": 1}, Params{"N": 5, "Rate": 0.5, "On": true, "Label": "1"}, false},
+		{"边界值", Params{"N": 10, "Rate": 0}, Params{"N": 10, "Rate": 0.0}, false},
+		{"空参数", Params{}, Params{}, false},
+		{"小于最小值", Params{"N": 0}, nil, true},
+		{"大于最大值", Params{"N": 11}, nil, true},
+		{"浮点数小于最小值", Params{"Rate": -0.1}, nil, true},
+		{"参数不存在", Params{"M": 1}, nil, true},
+	} {
+		got, err := convertParams(testParams, c.values)
+		if (err != nil) != c.err {
+			t.Errorf("[%s] 错误 %v", c.name, err)
+			continue
+		}
+		if !c.err && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("[%s] %v, 期望 %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSetFields(t *testing.T) {
+	type fields struct {
+		N     int64
+		Rate  float32
+		On    bool
+		Label string
+	}
+	for _, c := range []struct {
+		name   string
+		ps     []Param
+		values Params
+		want   fields
+		err    bool
+	}{
+		{"按字段类型设置", testParams, Params{"N": 3, "Rate": 0.5, "On": true, "Label": "a"}, fields{N: 3, Rate: 0.5, On: true, Label: "a"}, false},
+		{"只设置传入的参数", testParams, Params{"Label": "b"}, fields{N: 1, Label: "b"}, false},
+		{"超出范围", testParams, Params{"N": 20}, fields{N: 1}, true},
+		{"声明了但没有字段", append(testParams, Param{Name: "Missing", Type: ParamInt}), Params{"Missing": 1}, fields{N: 1}, true},
+	} {
+		got := fields{N: 1}
+		err := setFields(&got, c.ps, c.values)
+		if (err != nil) != c.err {
+			t.Errorf("[%s] 错误 %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("[%s] %+v, 期望 %+v", c.name, got, c.want)
+		}
+	}
+}
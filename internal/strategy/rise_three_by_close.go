@@ -1,10 +1,16 @@
 package strategy
 
 import (
+	"fmt"
+
 	"github.com/injoyai/tdx/extend"
 )
 
-var _ Interface = (*RiseThreeByClose)(nil)
+var (
+	_ Interface = (*RiseThreeByClose)(nil)
+	_ Scorer    = (*RiseThreeByClose)(nil)
+	_ Streamer  = (*RiseThreeByClose)(nil)
+)
 
 type RiseThreeByClose struct{}
 
@@ -22,6 +28,21 @@ func (RiseThreeByClose) Signal(info extend.Info, day, min extend.Klines) bool {
 		day[len(day)-2].Close > day[len(day)-3].Close
 }
 
+// NewStream 只需要最近3根K线
+func (this RiseThreeByClose) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 3)
+}
+
+// Score 评分为3天累计涨幅(百分比)
+func (RiseThreeByClose) Score(info extend.Info, day, min extend.Klines) (float64, string) {
+	if len(day) < 4 || day[len(day)-4].Close == 0 {
+		return 0, ""
+	}
+	base := day[len(day)-4].Close.Float64()
+	score := (day[len(day)-1].Close.Float64() - base) / base * 100
+	return score, fmt.Sprintf("3日涨幅 %.2f%%", score)
+}
+
 func init() {
 	Register(RiseThreeByClose{})
 }
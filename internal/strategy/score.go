@@ -0,0 +1,22 @@
+package strategy
+
+import (
+	"github.com/injoyai/tdx/extend"
+)
+
+// Scorer 评分策略,可选实现,用于选股结果排序
+type Scorer interface {
+	Interface
+	Score(info extend.Info, day, min extend.Klines) (float64, string) //评分和简短说明
+}
+
+// ScoreFunc 脚本评分函数
+type ScoreFunc = func(info extend.Info, day, min extend.Klines) (float64, string)
+
+// Score 获取策略评分,未实现Scorer的策略评分为0
+func Score(s Interface, info extend.Info, day, min extend.Klines) (float64, string) {
+	if sc, ok := s.(Scorer); ok {
+		return sc.Score(info, day, min)
+	}
+	return 0, ""
+}
@@ -2,24 +2,44 @@ package strategy
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/injoyai/tdx/extend"
 )
 
 var (
 	_ Interface = (*script)(nil)
+	_ Decider   = (*script)(nil)
+	_ Scorer    = (*script)(nil)
+
+	_ Parameterized = (*script)(nil)
+	_ Streamer      = (*script)(nil)
 )
 
 type SignalFunc = func(info extend.Info, day, min extend.Klines) bool
 
 func NewScript(name, _type string, handler SignalFunc) *script {
-	return &script{name: name, _type: _type, handler: handler}
+	s := &script{name: name, _type: _type, handler: handler}
+	s.base = s
+	return s
 }
 
 type script struct {
 	name    string
 	_type   string
 	handler SignalFunc
+	decide  DecideFunc
+	score   ScoreFunc
+	stream  StreamFunc
+
+	source *Script      //脚本源码,为空时不支持参数
+	vars   []scriptVar  //可调的包级变量
+	set    func(Params) //设置包级变量,脚本有参数时生成
+	values Params       //这个实例使用的全部参数值
+	key    string       //values的唯一标识
+	base   *script      //解释生成的实例,按参数生成的实例共用同一个包
+	mu     sync.Mutex   //包级变量只有一份,同一时间只能按一组参数执行
+	cur    string       //包级变量当前对应的参数
 }
 
 func (this *script) Name() string {
@@ -28,8 +48,108 @@ func (this *script) Name() string {
 
 func (this *script) Type() string { return this._type }
 
-func (this *script) Signal(info extend.Info, day, min extend.Klines) bool {
-	return this.handler(info, day, min)
+// use 把包级变量切换到这个实例的参数后执行f,没有参数的脚本直接执行
+func (this *script) use(f func()) {
+	base := this.base
+	if base.set == nil {
+		f()
+		return
+	}
+	base.mu.Lock()
+	defer base.mu.Unlock()
+	if base.cur != this.key {
+		base.set(this.values)
+		base.cur = this.key
+	}
+	f()
+}
+
+func (this *script) Signal(info extend.Info, day, min extend.Klines) (ok bool) {
+	this.use(func() {
+		if this.handler == nil {
+			ok = this.decide(info, day, min) > 0
+		} else {
+			ok = this.handler(info, day, min)
+		}
+	})
+	return
+}
+
+// Decide 脚本定义了Decide函数时使用三态信号,否则使用Signal
+func (this *script) Decide(info extend.Info, day, min extend.Klines) (d Decision) {
+	this.use(func() {
+		if this.decide == nil {
+			if this.handler(info, day, min) {
+				d.Action = Buy
+			}
+			return
+		}
+		d.Action = NewAction(this.decide(info, day, min))
+	})
+	return
+}
+
+// Score 脚本定义了Score函数时使用,否则评分为0
+func (this *script) Score(info extend.Info, day, min extend.Klines) (score float64, reason string) {
+	if this.score == nil {
+		return 0, ""
+	}
+	this.use(func() { score, reason = this.score(info, day, min) })
+	return
+}
+
+// NewStream 脚本定义了Stream函数时使用,否则按前缀切片调用Decide
+func (this *script) NewStream(info extend.Info, min extend.Klines) Stream {
+	if this.stream == nil {
+		return &prefixStream{s: this, info: info, min: min}
+	}
+	var f func(k *extend.Kline) int
+	this.use(func() { f = this.stream(info) })
+	return funcStream(func(k *extend.Kline) (d Decision) {
+		this.use(func() { d.Action = NewAction(f(k)) })
+		return
+	})
+}
+
+// Params 脚本中导出的包级变量作为参数
+func (this *script) Params() []Param {
+	out := make([]Param, len(this.vars))
+	for i, v := range this.vars {
+		out[i] = v.Param
+	}
+	return out
+}
+
+// WithParams 按参数生成新实例,和原策略共用解释好的包,执行前把包级变量设置成参数值,
+// 不会为每组参数重新解释脚本
+func (this *script) WithParams(values Params) (Interface, error) {
+	if this.source == nil || this.base.set == nil {
+		return nil, fmt.Errorf("策略[%s]不支持参数", this.name)
+	}
+	values, err := convertParams(this.Params(), values)
+	if err != nil {
+		return nil, err
+	}
+	all := Params{}
+	for k, v := range this.values {
+		all[k] = v
+	}
+	for k, v := range values {
+		all[k] = v
+	}
+	return &script{
+		name:    this.name,
+		_type:   this._type,
+		handler: this.handler,
+		decide:  this.decide,
+		score:   this.score,
+		stream:  this.stream,
+		source:  this.source,
+		vars:    this.vars,
+		values:  all,
+		key:     paramsKey(all),
+		base:    this.base,
+	}, nil
 }
 
 /*
@@ -48,6 +168,24 @@ func Signal(info extend.Info,day,min extend.Klines) bool {
 	return false
 }
 
+// Decide 可选,返回 1:买入 0:观望 -1:卖出,定义后优先于Signal
+// func Decide(info extend.Info,day,min extend.Klines) int {
+// 	return 0
+// }
+
+// Score 可选,返回评分和简短说明,用于选股结果排序
+// func Score(info extend.Info,day,min extend.Klines) (float64, string) {
+// 	return 0, ""
+// }
+
+// Stream 可选,回测时每只股票调用一次,返回的函数逐根K线调用,返回 1:买入 0:观望 -1:卖出
+// 在闭包中保存状态并增量计算指标,避免每根K线重新计算全部历史
+// func Stream(info extend.Info) func(k *extend.Kline) int {
+// 	return func(k *extend.Kline) int {
+// 		return 0
+// 	}
+// }
+
 `
 )
 
@@ -63,6 +201,25 @@ func (this *Script) FuncName() string {
 	return fmt.Sprintf("p_%s.Signal", this.Package)
 }
 
+func (this *Script) DecideName() string {
+	return fmt.Sprintf("p_%s.Decide", this.Package)
+}
+
+func (this *Script) ScoreName() string {
+	return fmt.Sprintf("p_%s.Score", this.Package)
+}
+
+func (this *Script) StreamName() string {
+	return fmt.Sprintf("p_%s.Stream", this.Package)
+}
+
+// scriptSetterName 生成的设置参数的函数名称,脚本中不能使用
+const scriptSetterName = "SetParams__"
+
+func (this *Script) SetterName() string {
+	return fmt.Sprintf("p_%s.%s", this.Package, scriptSetterName)
+}
+
 func (this *Script) Content() string {
 	return fmt.Sprintf("package p_%s\n%s", this.Package, this.Script)
 }
@@ -0,0 +1,196 @@
+package strategy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/injoyai/strategy/internal/common"
+	"github.com/injoyai/strategy/internal/lib"
+	"github.com/injoyai/tdx/extend"
+	"github.com/injoyai/tdx/protocol"
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// testScript K线数量达到N根时买入,Limit声明了类型
+const testScript = `
+import (
+	"github.com/injoyai/tdx/extend"
+)
+
+var N = 3 // 最少K线数量 [1,10]
+
+var Limit int64 = 100
+
+func Decide(info extend.Info, day, min extend.Klines) int {
+	if int64(len(day)) >= Limit {
+		return -1
+	}
+	if len(day) >= N {
+		return 1
+	}
+	return 0
+}
+
+func Stream(info extend.Info) func(k *extend.Kline) int {
+	n := 0
+	return func(k *extend.Kline) int {
+		n++
+		if n >= N {
+			return 1
+		}
+		return 0
+	}
+}
+`
+
+func loadTestScript(t *testing.T, pkg string) *script {
+	if common.Script == nil {
+		common.Script = interp.New(interp.Options{})
+		if err := common.Script.Use(stdlib.Symbols); err != nil {
+			t.Fatal(err)
+		}
+		if err := common.Script.Use(lib.Symbols); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := (&Script{Name: "测试脚本", Script: testScript, Package: pkg}).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testDays(n int) extend.Klines {
+	ks := make(extend.Klines, n)
+	for i := range ks {
+		ks[i] = &extend.Kline{Kline: &protocol.Kline{}}
+	}
+	return ks
+}
+
+func TestScriptParams(t *testing.T) {
+	s := loadTestScript(t, "params")
+	ps := s.Params()
+	if len(ps) != 2 || ps[0].Name != "N" || ps[0].Type != ParamInt || ps[0].Default != 3 || *ps[0].Max != 10 ||
+		ps[1].Name != "Limit" || ps[1].Type != ParamInt {
+		t.Fatalf("参数声明 %+v", ps)
+	}
+	if _, err := s.WithParams(Params{"N": 20}); err == nil {
+		t.Error("超出范围需要返回错误")
+	}
+	if _, err := s.WithParams(Params{"M": 1}); err == nil {
+		t.Error("不存在的参数需要返回错误")
+	}
+
+	p5, err := s.WithParams(Params{"N": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit, err := s.WithParams(Params{"Limit": 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p5.(*script).base != s || limit.(*script).base != s {
+		t.Error("按参数生成的实例需要共用解释好的包")
+	}
+
+	//交替执行,每次执行前切换到自己的参数
+	day := testDays(4)
+	for i := 0; i < 2; i++ {
+		if d := s.Decide(extend.Info{}, day, nil); d.Action != Buy {
+			t.Errorf("默认参数 %v", d.Action)
+		}
+		if d := p5.(Decider).Decide(extend.Info{}, day, nil); d.Action != Hold {
+			t.Errorf("N=5 %v", d.Action)
+		}
+		if d := limit.(Decider).Decide(extend.Info{}, day, nil); d.Action != Sell {
+			t.Errorf("Limit=4 %v", d.Action)
+		}
+	}
+
+	//流式状态在创建后也按各自的参数执行
+	base, five := s.NewStream(extend.Info{}, nil), p5.(Streamer).NewStream(extend.Info{}, nil)
+	var got []Action
+	for _, k := range testDays(5) {
+		got = append(got, base.OnBar(k).Action, five.OnBar(k).Action)
+	}
+	want := []Action{Hold, Hold, Hold, Hold, Buy, Hold, Buy, Hold, Buy, Buy}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("流式信号 %v, 期望 %v", got, want)
+		}
+	}
+}
+
+func TestParseScriptParams(t *testing.T) {
+	vars, err := parseScriptParams(`
+var (
+	N           = 3     // 周期 [1,10]
+	Rate        = 0.05  // 阈值 [,0.3]
+	Neg         = -2    // 负数
+	On          = true
+	Label       = "a"
+	Limit int64 = 100
+	Ratio float32 = 1.5
+	lower       = 1
+	Expr        = 1 + 2
+)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name, typ, goType, desc string
+		def                     any
+		min, max                *float64
+	}{
+		{"N", ParamInt, "int", "周期", 3, f64(1), f64(10)},
+		{"Rate", ParamFloat, "float64", "阈值", 0.05, nil, f64(0.3)},
+		{"Neg", ParamInt, "int", "负数", -2, nil, nil},
+		{"On", ParamBool, "bool", "", true, nil, nil},
+		{"Label", ParamString, "string", "", "a", nil, nil},
+		{"Limit", ParamInt, "int64", "", 100, nil, nil},
+		{"Ratio", ParamFloat, "float32", "", 1.5, nil, nil},
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("解析出 %d 个参数, 期望 %d", len(vars), len(want))
+	}
+	for i, w := range want {
+		v := vars[i]
+		if v.Name != w.name || v.Type != w.typ || v.goType != w.goType || v.Desc != w.desc || v.Default != w.def ||
+			!reflect.DeepEqual(v.Min, w.min) || !reflect.DeepEqual(v.Max, w.max) {
+			t.Errorf("[%s] %+v", w.name, v)
+		}
+	}
+}
+
+func TestScriptSetter(t *testing.T) {
+	vars := []scriptVar{
+		{Param: Param{Name: "N", Type: ParamInt}, goType: "int"},
+		{Param: Param{Name: "Limit", Type: ParamInt}, goType: "int64"},
+		{Param: Param{Name: "Rate", Type: ParamFloat}, goType: "float32"},
+		{Param: Param{Name: "On", Type: ParamBool}, goType: "bool"},
+		{Param: Param{Name: "Label", Type: ParamString}, goType: "string"},
+	}
+	code := scriptSetter("Set", vars)
+	if !strings.HasPrefix(code, "\nfunc Set(m map[string]interface{}) {\n") || !strings.HasSuffix(code, "}\n") {
+		t.Fatalf("生成的函数\n%s", code)
+	}
+	//参数值已经由convertParams转换成int/float64/bool/string,再转换成变量声明的类型
+	for _, want := range []string{
+		`if v, ok := m["N"]; ok {` + "\n\t\tN = int(v.(int))",
+		`if v, ok := m["Limit"]; ok {` + "\n\t\tLimit = int64(v.(int))",
+		`if v, ok := m["Rate"]; ok {` + "\n\t\tRate = float32(v.(float64))",
+		`if v, ok := m["On"]; ok {` + "\n\t\tOn = bool(v.(bool))",
+		`if v, ok := m["Label"]; ok {` + "\n\t\tLabel = string(v.(string))",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("缺少 %q\n%s", want, code)
+		}
+	}
+	if code := scriptSetter("Set", nil); code != "\nfunc Set(m map[string]interface{}) {\n}\n" {
+		t.Errorf("没有参数时 %q", code)
+	}
+}
@@ -0,0 +1,75 @@
+package strategy
+
+import (
+	"github.com/injoyai/tdx/extend"
+)
+
+// Action 交易动作
+type Action int
+
+const (
+	Sell Action = -1 //卖出/离场
+	Hold Action = 0  //观望/持有
+	Buy  Action = 1  //买入/入场
+)
+
+func (this Action) String() string {
+	switch this {
+	case Buy:
+		return "buy"
+	case Sell:
+		return "sell"
+	default:
+		return "hold"
+	}
+}
+
+// Decision 策略决策
+type Decision struct {
+	Action Action  `json:"action"` //动作 1:买入 0:观望 -1:卖出
+	Weight float64 `json:"weight"` //目标仓位权重(0~1),0表示由回测配置决定
+	Reason string  `json:"reason"` //原因说明
+}
+
+// ReasonOr 原因说明,策略没有给出时使用def
+func (this Decision) ReasonOr(def string) string {
+	if this.Reason == "" {
+		return def
+	}
+	return this.Reason
+}
+
+// Decider 三态信号策略,可选实现,未实现的策略通过Signal适配
+type Decider interface {
+	Interface
+	Decide(info extend.Info, day, min extend.Klines) Decision
+}
+
+// DecideFunc 脚本三态信号函数,返回 1:买入 0:观望 -1:卖出
+type DecideFunc = func(info extend.Info, day, min extend.Klines) int
+
+// Decide 获取策略的三态信号,兼容只实现了Signal的策略
+func Decide(s Interface, info extend.Info, day, min extend.Klines) Decision {
+	if d, ok := s.(ContextDecider); ok {
+		return d.DecideContext(NewContext(info, day, min))
+	}
+	if d, ok := s.(Decider); ok {
+		return d.Decide(info, day, min)
+	}
+	if s.Signal(info, day, min) {
+		return Decision{Action: Buy}
+	}
+	return Decision{Action: Hold}
+}
+
+// NewAction 把脚本返回的整数转换成动作
+func NewAction(i int) Action {
+	switch {
+	case i > 0:
+		return Buy
+	case i < 0:
+		return Sell
+	default:
+		return Hold
+	}
+}
@@ -3,9 +3,11 @@ package strategy
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/injoyai/conv"
@@ -26,8 +28,10 @@ type Interface interface {
 }
 
 var (
-	custom   = map[string]Interface{}
-	internal = map[string]Interface{}
+	custom    = map[string]Interface{}
+	internal  = map[string]Interface{}
+	composite = map[string]*Composite{}
+	evalMu    sync.Mutex
 )
 
 func Register(s Interface) {
@@ -38,22 +42,88 @@ func RegisterScript(s *Script) error {
 	if !s.Enable {
 		return nil
 	}
-
-	res, err := common.Script.Eval(s.Content())
+	i, err := s.load()
 	if err != nil {
 		return err
 	}
-	res, err = common.Script.Eval(s.FuncName())
+	custom[i.Name()] = i
+	return nil
+}
+
+// load 解释执行脚本,生成策略实例
+func (s *Script) load() (*script, error) {
+	vars, err := parseScriptParams(s.Script)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	f, ok := res.Interface().(func(extend.Info, extend.Klines, extend.Klines) bool)
-	if !ok {
-		return errors.New("脚本函数有误")
+
+	//解释器不支持并发Eval
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	content := s.Content()
+	if len(vars) > 0 {
+		content += scriptSetter(scriptSetterName, vars)
 	}
-	i := NewScript(s.Name, s.Type, f)
-	custom[i.Name()] = i
-	return nil
+	res, err := common.Script.Eval(content)
+	if err != nil {
+		return nil, err
+	}
+	i := NewScript(s.Name, s.Type, nil)
+	i.source = s
+	i.vars = vars
+	i.values = Params{}
+	for _, v := range vars {
+		i.values[v.Name] = v.Default
+	}
+	i.key = paramsKey(i.values)
+	i.cur = i.key
+	if len(vars) > 0 {
+		res, err = common.Script.Eval(s.SetterName())
+		if err != nil {
+			return nil, err
+		}
+		f, ok := res.Interface().(func(map[string]interface{}))
+		if !ok {
+			return nil, errors.New("脚本参数函数有误")
+		}
+		i.set = f
+	}
+
+	//Signal和Decide至少定义一个,Decide可选
+	if res, err = common.Script.Eval(s.FuncName()); err == nil {
+		f, ok := res.Interface().(SignalFunc)
+		if !ok {
+			return nil, errors.New("脚本函数Signal有误")
+		}
+		i.handler = f
+	}
+	if res, err = common.Script.Eval(s.DecideName()); err == nil {
+		f, ok := res.Interface().(DecideFunc)
+		if !ok {
+			return nil, errors.New("脚本函数Decide有误")
+		}
+		i.decide = f
+	}
+	if res, err = common.Script.Eval(s.ScoreName()); err == nil {
+		f, ok := res.Interface().(ScoreFunc)
+		if !ok {
+			return nil, errors.New("脚本函数Score有误")
+		}
+		i.score = f
+	}
+	if res, err = common.Script.Eval(s.StreamName()); err == nil {
+		f, ok := res.Interface().(StreamFunc)
+		if !ok {
+			return nil, errors.New("脚本函数Stream有误")
+		}
+		i.stream = f
+	}
+	if i.handler == nil && i.decide == nil {
+		return nil, errors.New("脚本未定义Signal或Decide函数")
+	}
+
+	return i, nil
 }
 
 func Get(name string) Interface {
@@ -61,6 +131,14 @@ func Get(name string) Interface {
 	if ok {
 		return i
 	}
+	if _, ok = composite[name]; ok {
+		i, err := (&Node{Strategy: name}).Build()
+		if err != nil {
+			logs.Err(err)
+			return nil
+		}
+		return i
+	}
 	return internal[name]
 }
 
@@ -68,6 +146,22 @@ func Del(name string) {
 	delete(custom, name)
 }
 
+// DelComposite 删除组合策略,被其它组合策略引用时不能删除
+func DelComposite(name string) error {
+	for _, c := range composite {
+		if c.Name == name {
+			continue
+		}
+		for _, v := range c.Node.Names() {
+			if v == name {
+				return fmt.Errorf("组合策略[%s]被组合策略[%s]引用,不能删除", name, c.Name)
+			}
+		}
+	}
+	delete(composite, name)
+	return nil
+}
+
 func Names(_type string) (out []string) {
 	switch _type {
 	case "custom":
@@ -75,6 +169,21 @@ func Names(_type string) (out []string) {
 		for k := range custom {
 			out = append(out, k)
 		}
+	case "all":
+		out = make([]string, 0, len(custom)+len(composite)+len(internal))
+		for _, m := range []map[string]Interface{custom, internal} {
+			for k := range m {
+				out = append(out, k)
+			}
+		}
+		for k := range composite {
+			out = append(out, k)
+		}
+	case "composite":
+		out = make([]string, 0, len(composite))
+		for k := range composite {
+			out = append(out, k)
+		}
 	case "internal":
 		fallthrough
 	default:
@@ -104,6 +213,12 @@ func Loading(dir string) error {
 		return err
 	}
 
+	//组合策略依赖其他策略,最后加载
+	err = LoadingComposite()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -125,6 +240,42 @@ func LoadingDatabase() error {
 	return nil
 }
 
+func LoadingComposite() error {
+	err := common.DB.Sync2(new(Composite))
+	if err != nil {
+		return err
+	}
+	ls := []*Composite(nil)
+	err = common.DB.Find(&ls)
+	if err != nil {
+		return err
+	}
+	for _, err = range registerComposites(ls) {
+		logs.Err(err)
+	}
+	return nil
+}
+
+// registerComposites 组合策略之间可以互相引用,按依赖顺序注册,
+// 每轮注册引用的策略都已经存在的,直到没有进展,返回注册失败的原因
+func registerComposites(ls []*Composite) []error {
+	for len(ls) > 0 {
+		var failed []*Composite
+		var errs []error
+		for _, c := range ls {
+			if err := RegisterComposite(c); err != nil {
+				failed = append(failed, c)
+				errs = append(errs, fmt.Errorf("组合策略[%s]加载失败: %v", c.Name, err))
+			}
+		}
+		if len(failed) == len(ls) {
+			return errs
+		}
+		ls = failed
+	}
+	return nil
+}
+
 func LoadingFile(dir string) error {
 	es, err := os.ReadDir(dir)
 	if err != nil {
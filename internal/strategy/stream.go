@@ -0,0 +1,84 @@
+package strategy
+
+import (
+	"time"
+
+	"github.com/injoyai/strategy/internal/data"
+	"github.com/injoyai/tdx/extend"
+)
+
+// Stream 单只股票的流式策略状态,每次推送一根新的K线,返回最新决策
+type Stream interface {
+	OnBar(k *extend.Kline) Decision
+}
+
+// Streamer 流式策略,可选实现,回测时逐根推送K线并增量更新指标,
+// 避免每根K线都用全部历史重新计算
+type Streamer interface {
+	Interface
+	NewStream(info extend.Info, min extend.Klines) Stream //每只股票创建独立的状态
+}
+
+// StreamFunc 脚本流式函数,每只股票调用一次,返回的函数每根K线调用一次,返回 1:买入 0:观望 -1:卖出
+type StreamFunc = func(info extend.Info) func(k *extend.Kline) int
+
+// NewStream 创建策略的流式状态,未实现Streamer的策略使用前缀切片适配,
+// 每根K线用截至当前的全部历史调用Decide
+func NewStream(s Interface, info extend.Info, min extend.Klines) Stream {
+	if st, ok := s.(Streamer); ok {
+		return st.NewStream(info, min)
+	}
+	return &prefixStream{s: s, info: info, min: min}
+}
+
+// prefixStream 兼容未实现Streamer的策略
+type prefixStream struct {
+	s    Interface
+	info extend.Info
+	min  extend.Klines
+	day  extend.Klines
+}
+
+func (this *prefixStream) OnBar(k *extend.Kline) Decision {
+	this.day = append(this.day, k)
+	//基本信息使用当前K线的数据,复权时价格和市值也是复权后的
+	this.info = data.NewInfo(this.info.Code, this.info.Name, k)
+	if d, ok := this.s.(ContextDecider); ok {
+		//分钟线只加载一次,上下文会截取到当前K线
+		ctx := NewContext(this.info, this.day, this.min)
+		if this.min == nil {
+			ctx.loadMin = func() extend.Klines {
+				this.min = loadMinute(this.info.Code, time.Unix(data.DayStart(this.day[0].Time), 0), time.Now().AddDate(0, 0, 1))
+				if this.min == nil {
+					this.min = extend.Klines{}
+				}
+				return this.min
+			}
+		}
+		return d.DecideContext(ctx)
+	}
+	return Decide(this.s, this.info, this.day, this.min)
+}
+
+// windowStream 只需要最近N根K线的策略,保留固定长度的历史,每根K线的计算量不随历史增长
+type windowStream struct {
+	prefixStream
+	n int
+}
+
+func newWindowStream(s Interface, info extend.Info, min extend.Klines, n int) *windowStream {
+	return &windowStream{prefixStream: prefixStream{s: s, info: info, min: min, day: make(extend.Klines, 0, n*2)}, n: n}
+}
+
+func (this *windowStream) OnBar(k *extend.Kline) Decision {
+	if len(this.day) == cap(this.day) {
+		//复用底层数组,只保留最近n-1根
+		this.day = append(this.day[:0], this.day[len(this.day)-this.n+1:]...)
+	}
+	return this.prefixStream.OnBar(k)
+}
+
+// funcStream 函数形式的流式状态
+type funcStream func(k *extend.Kline) Decision
+
+func (this funcStream) OnBar(k *extend.Kline) Decision { return this(k) }
@@ -4,7 +4,10 @@ import (
 	"github.com/injoyai/tdx/extend"
 )
 
-var _ Interface = (*Test)(nil)
+var (
+	_ Interface = (*Test)(nil)
+	_ Streamer  = (*Test)(nil)
+)
 
 type Test struct {
 	selected map[string]struct{}
@@ -21,6 +24,11 @@ func (this Test) Signal(info extend.Info, day, min extend.Klines) bool {
 	return ok
 }
 
+// NewStream 只依赖基本信息,不需要保留历史K线
+func (this Test) NewStream(info extend.Info, min extend.Klines) Stream {
+	return newWindowStream(this, info, min, 1)
+}
+
 func init() {
 	//Register(Test{map[string]struct{}{
 	//	"bj920000": {},
@@ -4,6 +4,8 @@ import (
 	"github.com/injoyai/tdx/extend"
 )
 
+var _ Parameterized = (*TrendUp)(nil)
+
 func init() {
 	Register(&TrendUp{
 		Window:          8,  // 顶底判断窗口大小 (默认8)
@@ -34,6 +36,22 @@ func (s *TrendUp) Type() string {
 	return DayKline
 }
 
+func (s *TrendUp) Params() []Param {
+	return []Param{
+		{Name: "Window", Type: ParamInt, Default: 8, Min: f64(2), Max: f64(60), Desc: "顶底判断窗口大小"},
+		{Name: "MinKlines", Type: ParamInt, Default: 30, Min: f64(10), Max: f64(1000), Desc: "最小K线数量要求"},
+		{Name: "MaxGainMultiple", Type: ParamFloat, Default: 5, Min: f64(1), Max: f64(100), Desc: "高点涨幅和低点涨幅的最大差距倍数"},
+	}
+}
+
+func (s *TrendUp) WithParams(values Params) (Interface, error) {
+	cp := *s
+	if err := setFields(&cp, s.Params(), values); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
 func (s *TrendUp) Signal(info extend.Info, day, min extend.Klines) bool {
 
 	ks := day
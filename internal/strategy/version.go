@@ -0,0 +1,62 @@
+package strategy
+
+import (
+	"sort"
+)
+
+// Version 策略的版本,用于记录回测时使用的策略
+type Version struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`              //internal:内置 custom:脚本 composite:组合
+	Version string `json:"version,omitempty"` //脚本的包名,每次修改脚本都会变化
+	Script  string `json:"script,omitempty"`  //脚本内容
+	Node    string `json:"node,omitempty"`    //组合策略的表达式
+}
+
+// Versions 策略树或策略列表用到的全部策略,组合策略会展开,按名称排序
+func Versions(tree *Node, names []string) []Version {
+	if tree == nil {
+		tree = &Node{Op: OpAnd}
+		for _, name := range names {
+			tree.Children = append(tree.Children, &Node{Strategy: name})
+		}
+	}
+	m := map[string]Version{}
+	tree.versions(m)
+	out := make([]Version, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (this *Node) versions(m map[string]Version) {
+	if this == nil {
+		return
+	}
+	for _, child := range this.Children {
+		child.versions(m)
+	}
+	if this.Op != "" || this.Strategy == "" {
+		return
+	}
+	if _, ok := m[this.Strategy]; ok {
+		return
+	}
+	if s, ok := custom[this.Strategy]; ok {
+		v := Version{Name: this.Strategy, Type: "custom"}
+		if sc, ok := s.(*script); ok && sc.source != nil {
+			v.Version = sc.source.Package
+			v.Script = sc.source.Script
+		}
+		m[this.Strategy] = v
+		return
+	}
+	if c, ok := composite[this.Strategy]; ok {
+		m[this.Strategy] = Version{Name: this.Strategy, Type: "composite", Node: c.Node.String()}
+		c.Node.versions(m)
+		return
+	}
+	m[this.Strategy] = Version{Name: this.Strategy, Type: "internal"}
+}
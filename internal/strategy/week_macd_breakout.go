@@ -0,0 +1,84 @@
+package strategy
+
+import (
+	"github.com/injoyai/strategy/internal/indicator"
+	"github.com/injoyai/tdx/extend"
+)
+
+var (
+	_ ContextDecider = (*WeekMACDBreakout)(nil)
+	_ Parameterized  = (*WeekMACDBreakout)(nil)
+)
+
+func init() {
+	Register(&WeekMACDBreakout{
+		Fast:   12, // 周线MACD快线周期 (默认12)
+		Slow:   26, // 周线MACD慢线周期 (默认26)
+		Period: 9,  // 周线MACD信号线周期 (默认9)
+		N:      20, // 日线突破周期 (默认20)
+	})
+}
+
+// WeekMACDBreakout 周线MACD多头(DIF在DEA之上)且日线收盘价突破前N日最高价时买入,周线MACD死叉卖出
+// 周线由日线合成,当周未结束时使用截至当天的数据
+type WeekMACDBreakout struct {
+	Fast   int // 周线MACD快线周期 (默认12)
+	Slow   int // 周线MACD慢线周期 (默认26)
+	Period int // 周线MACD信号线周期 (默认9)
+	N      int // 日线突破周期 (默认20)
+}
+
+func (s *WeekMACDBreakout) Name() string { return "周线MACD多头日线突破" }
+
+func (s *WeekMACDBreakout) Type() string { return DayKline }
+
+func (s *WeekMACDBreakout) Params() []Param {
+	return []Param{
+		{Name: "Fast", Type: ParamInt, Default: 12, Min: f64(2), Max: f64(60), Desc: "周线MACD快线周期"},
+		{Name: "Slow", Type: ParamInt, Default: 26, Min: f64(5), Max: f64(120), Desc: "周线MACD慢线周期"},
+		{Name: "Period", Type: ParamInt, Default: 9, Min: f64(2), Max: f64(60), Desc: "周线MACD信号线周期"},
+		{Name: "N", Type: ParamInt, Default: 20, Min: f64(2), Max: f64(250), Desc: "日线突破周期"},
+	}
+}
+
+func (s *WeekMACDBreakout) WithParams(values Params) (Interface, error) {
+	cp := *s
+	if err := setFields(&cp, s.Params(), values); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (s *WeekMACDBreakout) Signal(info extend.Info, day, min extend.Klines) bool {
+	return Decide(s, info, day, min).Action == Buy
+}
+
+func (s *WeekMACDBreakout) DecideContext(ctx *Context) Decision {
+	day := ctx.Day
+	if len(day) <= s.N {
+		return Decision{Action: Hold}
+	}
+	week := ctx.Week()
+	if len(week) < 2 {
+		return Decision{Action: Hold}
+	}
+	dif, dea, _ := indicator.MACD(indicator.Closes(week), s.Fast, s.Slow, s.Period)
+	n := len(week) - 1
+	if dif[n] < dea[n] && dif[n-1] >= dea[n-1] {
+		return Decision{Action: Sell, Reason: "周线MACD死叉"}
+	}
+	if dif[n] <= dea[n] {
+		return Decision{Action: Hold}
+	}
+
+	//前N日最高价,不含当天
+	last := day[len(day)-1]
+	high := day[len(day)-1-s.N].High
+	for _, k := range day[len(day)-s.N : len(day)-1] {
+		high = max(high, k.High)
+	}
+	if last.Close > high {
+		return Decision{Action: Buy, Reason: "周线MACD多头,日线突破前高"}
+	}
+	return Decision{Action: Hold}
+}